@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// config — настройки приложения. Значения читаются из файла конфигурации,
+// затем из переменных окружения, затем из флагов командной строки; каждый
+// следующий источник переопределяет предыдущий.
+type config struct {
+	Addr              string
+	DSN               string
+	GitLabBaseURL     string
+	OAuthClientID     string
+	OAuthClientSecret string
+	OAuthRedirectURI  string
+	CORSOrigins       []string
+	FrontendURL       string   // адрес SPA, куда пользователь возвращается после входа
+	RedirectAllowlist []string // источники, на которые разрешен возврат после входа
+	SyncToken         string
+	SyncInterval      time.Duration
+	SyncConcurrency   int
+	WebhookURL        string
+	WebhookSecret     string // секрет X-Gitlab-Token для вебхуков, установленных вручную
+	PollInterval      time.Duration
+	SessionKey        []byte // ключ AES-256 для шифрования токенов GitLab в сессиях
+	SessionTTL        time.Duration
+	CookieSecure      bool
+	JWTSigningKey     string // PEM-ключ ECDSA P-256 для подписи JWT; пустой — ключ создается при запуске
+	JWTIssuer         string
+	JWTAccessTTL      time.Duration
+	RolesFromGitLab   bool     // Maintainer и Owner проекта в GitLab получают роль project_manager в проекте
+	LocalLogin        bool     // вход по логину и паролю из таблицы users
+	TrustedProxies    []string // адреса и подсети прокси, чьим X-Forwarded-For можно верить
+}
+
+// configOption описывает параметр конфигурации. Имя флага совпадает с ключом файла
+// (через "-" вместо "_"). Секретные параметры можно передать файлом: ключ <name>_file,
+// переменная <ENV>_FILE или флаг -<name>-file (Docker/Kubernetes secrets).
+type configOption struct {
+	name   string
+	env    string
+	def    string
+	secret bool
+	usage  string
+}
+
+var configOptions = []configOption{
+	{name: "addr", env: "PLAGINAGILE_ADDR", def: ":4000", usage: "HTTP network address"},
+	{name: "dsn", env: "PLAGINAGILE_DSN", secret: true, usage: "Название PgSQL источника данных"},
+	{name: "gitlab-base-url", env: "GITLAB_BASE_URL", usage: "Базовый URL GitLab"},
+	{name: "oauth-client-id", env: "GITLAB_CLIENT_ID", usage: "Application ID приложения OAuth в GitLab"},
+	{name: "oauth-client-secret", env: "GITLAB_CLIENT_SECRET", secret: true, usage: "Secret приложения OAuth в GitLab"},
+	{name: "oauth-redirect-uri", env: "GITLAB_REDIRECT_URI", def: "http://localhost:8080/oauth/callback", usage: "Redirect URI приложения OAuth"},
+	{name: "cors-origin", env: "PLAGINAGILE_CORS_ORIGIN", def: "http://localhost:8080", usage: "Разрешенные источники CORS через запятую"},
+	{name: "frontend-url", env: "PLAGINAGILE_FRONTEND_URL", def: "http://localhost:8080", usage: "Адрес фронтенда, куда пользователь возвращается после входа"},
+	{name: "redirect-allowlist", env: "PLAGINAGILE_REDIRECT_ALLOWLIST", usage: "Источники через запятую, на которые разрешен возврат после входа (по умолчанию — frontend-url и cors-origin)"},
+	{name: "sync-token", env: "GITLAB_SERVICE_TOKEN", secret: true, usage: "Сервисный токен GitLab для фоновой синхронизации"},
+	{name: "sync-interval", env: "GITLAB_SYNC_INTERVAL", def: "5m", usage: "Интервал фоновой синхронизации с GitLab"},
+	{name: "sync-concurrency", env: "GITLAB_SYNC_CONCURRENCY", def: "4", usage: "Количество одновременных запросов к GitLab при синхронизации"},
+	{name: "webhook-url", env: "GITLAB_WEBHOOK_URL", usage: "Публичный адрес /api/webhooks/gitlab для автоматической регистрации вебхуков"},
+	{name: "webhook-secret", env: "GITLAB_WEBHOOK_SECRET", secret: true, usage: "Секрет (X-Gitlab-Token) вебхуков, установленных в GitLab вручную; без него принимаются только вебхуки, зарегистрированные приложением"},
+	{name: "poll-interval", env: "GITLAB_POLL_INTERVAL", def: "0", usage: "Интервал опроса событий GitLab вместо вебхуков (0 — опрос выключен)"},
+	{name: "session-key", env: "PLAGINAGILE_SESSION_KEY", secret: true, usage: "Ключ шифрования сессий: 32 байта в base64 (openssl rand -base64 32)"},
+	{name: "session-ttl", env: "PLAGINAGILE_SESSION_TTL", def: "24h", usage: "Время жизни сессии пользователя"},
+	{name: "cookie-secure", env: "PLAGINAGILE_COOKIE_SECURE", def: "true", usage: "Передавать куки только по HTTPS"},
+	{name: "jwt-signing-key", env: "PLAGINAGILE_JWT_SIGNING_KEY", secret: true, usage: "PEM-ключ ECDSA P-256 для подписи JWT (openssl ecparam -name prime256v1 -genkey -noout)"},
+	{name: "jwt-issuer", env: "PLAGINAGILE_JWT_ISSUER", def: "plaginagile", usage: "Издатель (iss) JWT"},
+	{name: "jwt-access-ttl", env: "PLAGINAGILE_JWT_ACCESS_TTL", def: "15m", usage: "Время жизни JWT доступа"},
+	{name: "local-login", env: "PLAGINAGILE_LOCAL_LOGIN", def: "false", usage: "Разрешить вход по логину и паролю из таблицы users (для установок без GitLab SSO)"},
+	{name: "trusted-proxies", env: "PLAGINAGILE_TRUSTED_PROXIES", usage: "Адреса и подсети (CIDR) обратных прокси через запятую, которым разрешено передавать адрес клиента в X-Forwarded-For (по умолчанию — никому)"},
+	{name: "project-roles-from-gitlab", env: "PLAGINAGILE_PROJECT_ROLES_FROM_GITLAB", def: "true", usage: "Назначать роль project_manager в проекте участникам с доступом Maintainer в GitLab"},
+}
+
+// Источники значений конфигурации
+const (
+	configSourceDefault = "по умолчанию"
+	configSourceFile    = "файл"
+	configSourceEnv     = "окружение"
+	configSourceFlag    = "флаг"
+)
+
+// configValue — значение параметра и источник, из которого оно получено
+type configValue struct {
+	value  string
+	source string
+}
+
+// loadConfig читает и проверяет конфигурацию. Путь к файлу задается флагом -config
+// или переменной PLAGINAGILE_CONFIG. Возвращает также значения с источниками для журнала.
+func loadConfig(args []string) (*config, map[string]configValue, error) {
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("PLAGINAGILE_CONFIG"), "Путь к файлу конфигурации (JSON)")
+	for _, opt := range configOptions {
+		fs.String(opt.name, opt.def, opt.usage)
+		if opt.secret {
+			fs.String(opt.name+"-file", "", "Файл со значением -"+opt.name)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]configValue, len(configOptions))
+	for _, opt := range configOptions {
+		values[opt.name] = configValue{value: opt.def, source: configSourceDefault}
+	}
+
+	if *configPath != "" {
+		if err := applyConfigFile(values, *configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, opt := range configOptions {
+		direct, hasDirect := os.LookupEnv(opt.env)
+		if err := applyConfigValue(values, opt, direct, hasDirect, os.Getenv(opt.env+"_FILE"), configSourceEnv); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	setFlags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = f.Value.String() })
+	for _, opt := range configOptions {
+		direct, hasDirect := setFlags[opt.name]
+		if err := applyConfigValue(values, opt, direct, hasDirect, setFlags[opt.name+"-file"], configSourceFlag); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cfg, err := parseConfig(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, values, nil
+}
+
+// applyConfigFile читает параметры из JSON-файла. Неизвестные ключи считаются ошибкой,
+// чтобы опечатки в конфигурации не проходили незамеченными.
+func applyConfigFile(values map[string]configValue, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("неверный формат файла конфигурации %s: %w", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, opt := range configOptions {
+		key := strings.ReplaceAll(opt.name, "-", "_")
+		known[key] = true
+
+		direct, hasDirect := raw[key]
+		var fileRef interface{}
+		if opt.secret {
+			known[key+"_file"] = true
+			fileRef = raw[key+"_file"]
+		}
+		if err := applyConfigValue(values, opt, configString(direct), hasDirect, configString(fileRef), configSourceFile); err != nil {
+			return err
+		}
+	}
+
+	var unknown []string
+	for key := range raw {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("неизвестные параметры в файле конфигурации: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// configString приводит значение из JSON к строке; числа записываются без дробной части
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, configString(item))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// applyConfigValue переопределяет параметр значением из источника. Значение и файл
+// со значением в одном источнике взаимоисключающие.
+func applyConfigValue(values map[string]configValue, opt configOption, direct string, hasDirect bool, secretFile, source string) error {
+	if secretFile != "" {
+		if hasDirect {
+			return fmt.Errorf("%s: параметр %s задан одновременно значением и файлом", source, opt.name)
+		}
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return fmt.Errorf("%s: не удалось прочитать файл параметра %s: %w", source, opt.name, err)
+		}
+		values[opt.name] = configValue{value: strings.TrimSpace(string(data)), source: source + " (файл секрета)"}
+		return nil
+	}
+	if hasDirect {
+		values[opt.name] = configValue{value: direct, source: source}
+	}
+	return nil
+}
+
+// parseConfig преобразует значения параметров и проверяет их
+func parseConfig(values map[string]configValue) (*config, error) {
+	var errs []error
+	get := func(name string) string { return strings.TrimSpace(values[name].value) }
+
+	cfg := &config{
+		Addr:              get("addr"),
+		DSN:               get("dsn"),
+		GitLabBaseURL:     strings.TrimRight(get("gitlab-base-url"), "/"),
+		OAuthClientID:     get("oauth-client-id"),
+		OAuthClientSecret: get("oauth-client-secret"),
+		OAuthRedirectURI:  get("oauth-redirect-uri"),
+		SyncToken:         get("sync-token"),
+		WebhookURL:        get("webhook-url"),
+		WebhookSecret:     get("webhook-secret"),
+		JWTSigningKey:     get("jwt-signing-key"),
+		JWTIssuer:         get("jwt-issuer"),
+	}
+	cfg.CORSOrigins = splitConfigList(get("cors-origin"))
+	cfg.FrontendURL = strings.TrimRight(get("frontend-url"), "/")
+	cfg.RedirectAllowlist = splitConfigList(get("redirect-allowlist"))
+	if len(cfg.RedirectAllowlist) == 0 {
+		cfg.RedirectAllowlist = append([]string{cfg.FrontendURL}, cfg.CORSOrigins...)
+	}
+
+	required := map[string]string{
+		"addr":                cfg.Addr,
+		"dsn":                 cfg.DSN,
+		"gitlab-base-url":     cfg.GitLabBaseURL,
+		"oauth-client-id":     cfg.OAuthClientID,
+		"oauth-client-secret": cfg.OAuthClientSecret,
+		"oauth-redirect-uri":  cfg.OAuthRedirectURI,
+		"frontend-url":        cfg.FrontendURL,
+		"session-key":         get("session-key"),
+	}
+	for _, opt := range configOptions {
+		if value, ok := required[opt.name]; ok && value == "" {
+			errs = append(errs, fmt.Errorf("не задан обязательный параметр %s (переменная %s)", opt.name, opt.env))
+		}
+	}
+
+	if cfg.DSN != "" {
+		if _, err := pgxpool.ParseConfig(cfg.DSN); err != nil {
+			errs = append(errs, errors.New("dsn: неверный формат строки подключения"))
+		}
+	}
+
+	urls := map[string]string{
+		"gitlab-base-url":    cfg.GitLabBaseURL,
+		"oauth-redirect-uri": cfg.OAuthRedirectURI,
+		"webhook-url":        cfg.WebhookURL,
+		"frontend-url":       cfg.FrontendURL,
+	}
+	for _, opt := range configOptions {
+		if value := urls[opt.name]; value != "" {
+			if err := validateConfigURL(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", opt.name, err))
+			}
+		}
+	}
+	if len(cfg.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("cors-origin: нужно указать хотя бы один источник"))
+	}
+	for _, origin := range cfg.CORSOrigins {
+		if err := validateConfigURL(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors-origin %s: %v", origin, err))
+		}
+	}
+	cfg.TrustedProxies = splitConfigList(get("trusted-proxies"))
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("trusted-proxies %s: нужен IP-адрес или подсеть CIDR", proxy))
+			}
+		}
+	}
+	for _, origin := range cfg.RedirectAllowlist {
+		if err := validateConfigURL(origin); err != nil {
+			errs = append(errs, fmt.Errorf("redirect-allowlist %s: %v", origin, err))
+		}
+	}
+
+	var err error
+	if cfg.SyncInterval, err = time.ParseDuration(get("sync-interval")); err != nil || cfg.SyncInterval <= 0 {
+		errs = append(errs, errors.New("sync-interval: нужна положительная длительность, например 5m"))
+	}
+	if cfg.PollInterval, err = time.ParseDuration(get("poll-interval")); err != nil || cfg.PollInterval < 0 {
+		errs = append(errs, errors.New("poll-interval: нужна длительность, например 1m, или 0"))
+	}
+	if cfg.SyncConcurrency, err = strconv.Atoi(get("sync-concurrency")); err != nil || cfg.SyncConcurrency < 1 {
+		errs = append(errs, errors.New("sync-concurrency: нужно положительное число"))
+	}
+	if key := get("session-key"); key != "" {
+		if cfg.SessionKey, err = base64.StdEncoding.DecodeString(key); err != nil || len(cfg.SessionKey) != 32 {
+			errs = append(errs, errors.New("session-key: нужно 32 байта в base64"))
+		}
+	}
+	if cfg.SessionTTL, err = time.ParseDuration(get("session-ttl")); err != nil || cfg.SessionTTL <= 0 {
+		errs = append(errs, errors.New("session-ttl: нужна положительная длительность, например 24h"))
+	}
+	if cfg.CookieSecure, err = strconv.ParseBool(get("cookie-secure")); err != nil {
+		errs = append(errs, errors.New("cookie-secure: нужно true или false"))
+	}
+	if cfg.JWTAccessTTL, err = time.ParseDuration(get("jwt-access-ttl")); err != nil || cfg.JWTAccessTTL <= 0 {
+		errs = append(errs, errors.New("jwt-access-ttl: нужна положительная длительность, например 15m"))
+	}
+	if cfg.LocalLogin, err = strconv.ParseBool(get("local-login")); err != nil {
+		errs = append(errs, errors.New("local-login: нужно true или false"))
+	}
+	if cfg.RolesFromGitLab, err = strconv.ParseBool(get("project-roles-from-gitlab")); err != nil {
+		errs = append(errs, errors.New("project-roles-from-gitlab: нужно true или false"))
+	}
+	if cfg.JWTIssuer == "" {
+		errs = append(errs, errors.New("jwt-issuer: не может быть пустым"))
+	}
+	if cfg.PollInterval > 0 && cfg.SyncToken == "" {
+		errs = append(errs, errors.New("poll-interval: опрос событий GitLab требует сервисного токена (sync-token)"))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("неверная конфигурация: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// splitConfigList разбирает список значений через запятую
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.TrimRight(item, "/"))
+		}
+	}
+	return items
+}
+
+// validateConfigURL проверяет, что адрес абсолютный и использует http или https
+func validateConfigURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("нужен абсолютный адрес http(s)")
+	}
+	return nil
+}
+
+// dsnPasswordPattern находит пароль в DSN формата key=value
+var dsnPasswordPattern = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// redactConfigValue скрывает секретные значения для журнала; в DSN скрывается только пароль
+func redactConfigValue(opt configOption, value string) string {
+	if value == "" || !opt.secret {
+		return value
+	}
+	if opt.name == "dsn" {
+		if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+			return u.Redacted()
+		}
+		return dsnPasswordPattern.ReplaceAllString(value, "${1}xxxxx")
+	}
+	return "[скрыт]"
+}
+
+// logConfig выводит действующую конфигурацию с источниками значений, скрывая секреты
+func logConfig(infoLog *log.Logger, values map[string]configValue) {
+	infoLog.Printf("Конфигурация:")
+	for _, opt := range configOptions {
+		v := values[opt.name]
+		infoLog.Printf("  %s = %q (%s)", opt.name, redactConfigValue(opt, v.value), v.source)
+	}
+}
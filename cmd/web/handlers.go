@@ -3,11 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -16,16 +15,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
 	"golangify.com/plaginagile/pkg/models"
+	"golangify.com/plaginagile/pkg/models/pgsql"
 )
 
-type GitLabUser struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-}
-
 type OAuthHandler struct {
 	clientID      string
 	clientSecret  string
@@ -34,242 +28,235 @@ type OAuthHandler struct {
 	app           *application
 }
 
-func (h *OAuthHandler) GitLabAuthHandler(c *gin.Context) {
-	// Генерируем случайный state параметр для безопасности
-	state := fmt.Sprintf("%d", time.Now().UnixNano())
-	
-	// Сохраняем state в cookie
-	c.SetCookie("oauth_state", state, 3600, "/", "", false, true)
-	
-	// Формируем URL для авторизации
-	authURL := fmt.Sprintf(
-		"%s/oauth/authorize?client_id=%s&redirect_uri=%s&response_type=code&scope=api+read_api&state=%s",
-		h.gitlabBaseURL, h.clientID, url.QueryEscape(h.redirectURI), state,
-	)
-	
-	// Логируем информацию для отладки
-	fmt.Printf("GitLab Auth URL: %s\n", authURL)
-	fmt.Printf("Client ID: %s\n", h.clientID)
-	fmt.Printf("Redirect URI: %s\n", h.redirectURI)
-	fmt.Printf("GitLab Base URL: %s\n", h.gitlabBaseURL)
-	
-	c.Redirect(http.StatusTemporaryRedirect, authURL)
-}
+// defaultUserRole — роль пользователя без сохраненных настроек
+const defaultUserRole = roleDeveloper
 
-func (h *OAuthHandler) GitLabCallbackHandler(c *gin.Context) {
-	code := c.Query("code")
-	state := c.Query("state")
-	
-	if code == "" {
-		c.JSON(400, gin.H{"error": "Код авторизации отсутствует"})
-		return
+// gitlabClient возвращает клиент GitLab с токеном пользователя из сессии
+func (app *application) gitlabClient(c *gin.Context) (*gitlab.Client, bool) {
+	token, err := app.requestGitLabToken(c)
+	if errors.Is(err, errSessionExpired) {
+		app.respondReauth(c, err.Error())
+		return nil, false
 	}
-
-	// Проверяем state из куки
-	storedState, err := c.Cookie("oauth_state")
-	if err != nil || storedState != state {
-		c.JSON(400, gin.H{"error": "Неверный state параметр"})
-		return
+	if errors.Is(err, errPersonalTokenNoGitLab) || errors.Is(err, errGitLabNotLinked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
 	}
-
-	// Очищаем куки после проверки
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
-
-	// Обмениваем код на токен
-	tokenURL := fmt.Sprintf("%s/oauth/token", h.gitlabBaseURL)
-	data := url.Values{}
-	data.Set("client_id", h.clientID)
-	data.Set("client_secret", h.clientSecret)
-	data.Set("code", code)
-	data.Set("grant_type", "authorization_code")
-	data.Set("redirect_uri", h.redirectURI)
-
-	resp, err := http.PostForm(tokenURL, data)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при обмене кода на токен"})
-		return
+		app.errorLog.Printf("Ошибка получения токена сессии: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Не удалось обновить токен GitLab, повторите запрос позже"})
+		return nil, false
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при чтении ответа"})
-		return
+	if token == "" {
+		app.respondReauth(c, "Токен отсутствует")
+		return nil, false
 	}
+	return app.gitlab.WithToken(token), true
+}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		Error       string `json:"error"`
-	}
+// respondGitLabError логирует ошибку обращения к GitLab и отправляет ее клиенту
+func (app *application) respondGitLabError(c *gin.Context, err error) {
+	app.errorLog.Printf("Ошибка запроса к GitLab: %v", err)
 
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при разборе ответа"})
-		return
+	// Пока GitLab недоступен, подсказываем фронтенду, когда повторить запрос
+	var circuitErr *gitlab.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(circuitErr.RetryAfter.Seconds()))))
 	}
 
-	if tokenResp.Error != "" {
-		c.JSON(400, gin.H{"error": tokenResp.Error})
-		return
+	// Токен сессии отозван в GitLab: завершаем сессию и отправляем на повторный вход
+	if errors.Is(err, gitlab.ErrUnauthorized) {
+		if _, ok := currentSession(c); ok {
+			app.endSession(c)
+			app.respondReauth(c, "Доступ к GitLab отозван, войдите снова")
+			return
+		}
 	}
 
-	// Получаем информацию о пользователе
-	userURL := fmt.Sprintf("%s/api/v4/user", h.gitlabBaseURL)
-	req, err := http.NewRequest("GET", userURL, nil)
+	c.JSON(gitlab.HTTPStatus(err), gin.H{"error": gitlab.Message(err)})
+}
+
+// userRoles получает роли пользователей плагина; для пользователей без настроек
+// используется роль по умолчанию
+func (app *application) userRoles(userIDs []int) (map[int]string, error) {
+	roles, err := app.models.GetUserRoles(userIDs)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при создании запроса"})
-		return
+		return nil, err
+	}
+	for _, id := range userIDs {
+		if roles[id] == "" {
+			roles[id] = defaultUserRole
+		}
 	}
+	return roles, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
-	client := &http.Client{}
-	resp, err = client.Do(req)
+// GitLabAuthHandler начинает вход через GitLab: сохраняет случайный state и PKCE
+// code_verifier на сервере и перенаправляет пользователя на страницу авторизации.
+// Параметр redirect_to задает страницу SPA, куда пользователь вернется после входа.
+func (h *OAuthHandler) GitLabAuthHandler(c *gin.Context) {
+	redirectTo, err := h.app.resolveRedirectTarget(c.Query("redirect_to"))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при получении данных пользователя"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err = io.ReadAll(resp.Body)
+	// Генерируем случайный state параметр и code_verifier для PKCE
+	state, err := randomURLToken(32)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при чтении ответа"})
+		h.app.errorLog.Printf("Ошибка генерации state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось начать вход"})
 		return
 	}
-
-	var user struct {
-		ID       int    `json:"id"`
-		Username string `json:"username"`
-		Name     string `json:"name"`
-		Email    string `json:"email"`
+	verifier, err := randomURLToken(48)
+	if err != nil {
+		h.app.errorLog.Printf("Ошибка генерации code_verifier: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось начать вход"})
+		return
 	}
 
-	if err := json.Unmarshal(body, &user); err != nil {
-		c.JSON(500, gin.H{"error": "Ошибка при разборе данных пользователя"})
+	err = h.app.models.CreateOAuthState(&models.OAuthState{
+		StateHash:    hashSessionToken(state),
+		CodeVerifier: verifier,
+		RedirectTo:   redirectTo,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		h.app.errorLog.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось начать вход"})
 		return
 	}
 
-	// Устанавливаем токен в куки
-	c.SetCookie("gitlab_token", tokenResp.AccessToken, 3600, "/", "", false, true)
+	// Сохраняем state в cookie, чтобы вход завершился в том же браузере
+	h.app.setCookie(c, oauthStateCookie, state, int(oauthStateTTL.Seconds()))
 
-	// Возвращаем данные пользователя и токен
-	c.JSON(200, gin.H{
-		"user": user,
-		"token": tokenResp.AccessToken,
-	})
+	// Формируем URL для авторизации
+	query := url.Values{}
+	query.Set("client_id", h.clientID)
+	query.Set("redirect_uri", h.redirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", "api read_api")
+	query.Set("state", state)
+	query.Set("code_challenge", pkceChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	authURL := h.gitlabBaseURL + "/oauth/authorize?" + query.Encode()
+
+	h.app.infoLog.Printf("Вход через GitLab: redirect_uri %s, возврат на %s", h.redirectURI, redirectTo)
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
 }
 
-func (h *OAuthHandler) authenticateWithGitLab(token string) (*GitLabUser, error) {
-	url := fmt.Sprintf("%s/api/v4/user", h.gitlabBaseURL)
+// GitLabCallbackHandler завершает вход: проверяет state, обменивает код на токены
+// с PKCE code_verifier, создает сессию и возвращает пользователя в SPA. Ошибки
+// также возвращают пользователя в SPA с параметром auth_error.
+func (h *OAuthHandler) GitLabCallbackHandler(c *gin.Context) {
+	code := c.Query("code")
+	state := c.Query("state")
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	// Проверяем state из куки и из записи на сервере
+	storedState, err := c.Cookie(oauthStateCookie)
+	h.app.setCookie(c, oauthStateCookie, "", -1)
+	if err != nil || state == "" || storedState != state {
+		h.app.errorLog.Printf("Вход через GitLab: state не совпадает с кукой")
+		h.app.redirectAuthError(c, "", authErrorInvalidState)
+		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	record, err := h.app.models.ConsumeOAuthState(hashSessionToken(state))
 	if err != nil {
-		return nil, err
+		if !errors.Is(err, models.ErrNoRecord) {
+			h.app.errorLog.Printf("%v", err)
+		}
+		h.app.redirectAuthError(c, "", authErrorInvalidState)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ошибка аутентификации: %s", resp.Status)
+	// Пользователь отказал в доступе или GitLab вернул ошибку
+	if gitlabErr := c.Query("error"); gitlabErr != "" {
+		h.app.infoLog.Printf("Вход через GitLab отклонен: %s %s", gitlabErr, c.Query("error_description"))
+		h.app.redirectAuthError(c, record.RedirectTo, authErrorDenied)
+		return
 	}
+	if code == "" {
+		h.app.redirectAuthError(c, record.RedirectTo, authErrorMissingCode)
+		return
+	}
+
+	// Обмениваем код на токен
+	data := url.Values{}
+	data.Set("client_id", h.clientID)
+	data.Set("client_secret", h.clientSecret)
+	data.Set("code", code)
+	data.Set("grant_type", "authorization_code")
+	data.Set("redirect_uri", h.redirectURI)
+	data.Set("code_verifier", record.CodeVerifier)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	tokenResp, err := h.app.gitlab.ExchangeToken(c.Request.Context(), data)
 	if err != nil {
-		return nil, err
+		h.app.errorLog.Printf("Ошибка при обмене кода на токен: %v", err)
+		h.app.redirectAuthError(c, record.RedirectTo, authErrorTokenExchange)
+		return
 	}
 
-	var user GitLabUser
-	err = json.Unmarshal(body, &user)
+	// Получаем информацию о пользователе
+	user, err := h.app.gitlab.WithToken(tokenResp.AccessToken).CurrentUser(c.Request.Context())
 	if err != nil {
-		return nil, err
+		h.app.errorLog.Printf("Ошибка при получении данных пользователя: %v", err)
+		h.app.redirectAuthError(c, record.RedirectTo, authErrorUser)
+		return
 	}
 
-	return &user, nil
-}
-
-func (h *OAuthHandler) GitLabProjectsHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	// Токены GitLab остаются на сервере, браузер получает только куку сессии
+	if err := h.app.startSession(c, user, tokenResp); err != nil {
+		h.app.errorLog.Printf("Ошибка при создании сессии: %v", err)
+		h.app.redirectAuthError(c, record.RedirectTo, authErrorSession)
 		return
 	}
 
-	url := fmt.Sprintf("%s/api/v4/projects", h.gitlabBaseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", token)
+	h.app.infoLog.Printf("Пользователь %s вошел через GitLab", user.Username)
+	c.Redirect(http.StatusSeeOther, record.RedirectTo)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (h *OAuthHandler) authenticateWithGitLab(token string) (*gitlab.User, error) {
+	user, err := h.app.gitlab.WithToken(token).CurrentUser(context.Background())
 	if err != nil {
-		fmt.Println("Ошибка запроса к GitLab:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
+		return nil, fmt.Errorf("ошибка аутентификации: %w", err)
 	}
-	defer resp.Body.Close()
+	return user, nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Ошибка чтения ответа:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
+func (h *OAuthHandler) GitLabProjectsHandler(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
-	// Проверяем, является ли ответ ошибкой
-	var errorResponse map[string]interface{}
-	if err := json.Unmarshal(body, &errorResponse); err == nil {
-		if _, exists := errorResponse["error"]; exists {
-			fmt.Println("Ошибка от GitLab:", errorResponse)
-			c.JSON(http.StatusForbidden, gin.H{"error": errorResponse["error"]})
-			return
-		}
+	query, err := parseListQuery(c, projectListQuery)
+	if err != nil {
+		respondInvalidQuery(c, err)
+		return
 	}
 
-	// Парсим данные как массив проектов
-	var projects []map[string]interface{}
-	if err := json.Unmarshal(body, &projects); err != nil {
-		fmt.Println("Ошибка парсинга данных:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных"})
+	// Отдаем проекты со всеми полями GitLab, которые использует фронтенд
+	projects, resp, ok := listGitLab[map[string]interface{}](h.app, c, client, "/projects", query)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, projects)
+	c.JSON(http.StatusOK, listResponse(projects, resp))
 }
 
 func (h *OAuthHandler) GitLabProjectHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
 	projectID := c.Param("id") // Получаем ID проекта из URL
-	url := fmt.Sprintf("%s/api/v4/projects/%s", h.gitlabBaseURL, projectID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
-		return
-	}
 
 	var project map[string]interface{}
-	if err := json.Unmarshal(body, &project); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных"})
+	_, err := client.Do(c.Request.Context(), http.MethodGet, "/projects/"+url.PathEscape(projectID), nil, nil, &project)
+	if err != nil {
+		h.app.respondGitLabError(c, err)
 		return
 	}
 
@@ -277,141 +264,85 @@ func (h *OAuthHandler) GitLabProjectHandler(c *gin.Context) {
 }
 
 func (h *OAuthHandler) GitLabProjectIssuesHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
-	projectID := c.Param("id") // Получаем ID проекта из URL
-	url := fmt.Sprintf("%s/api/v4/projects/%s/issues", h.gitlabBaseURL, projectID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	query, err := parseListQuery(c, issueListQuery)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
+		respondInvalidQuery(c, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
-		return
-	}
+	projectID := c.Param("id") // Получаем ID проекта из URL
+	path := fmt.Sprintf("/projects/%s/issues", url.PathEscape(projectID))
 
-	var issues []map[string]interface{}
-	if err := json.Unmarshal(body, &issues); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных"})
+	issues, resp, ok := listGitLab[map[string]interface{}](h.app, c, client, path, query)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, issues)
+	c.JSON(http.StatusOK, listResponse(issues, resp))
 }
 
 func (h *OAuthHandler) GitLabMembersHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
-		return
-	}
-
-	// Получаем список пользователей
-	usersURL := fmt.Sprintf("%s/api/v4/users", h.gitlabBaseURL)
-	req, err := http.NewRequest("GET", usersURL, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
-	req.Header.Set("Authorization", token)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	query, err := parseListQuery(c, userListQuery)
 	if err != nil {
-		fmt.Println("Ошибка запроса к GitLab:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
+		respondInvalidQuery(c, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Ошибка чтения ответа:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
+	// Получаем страницу списка пользователей
+	users, resp, ok := listGitLab[gitlab.User](h.app, c, client, "/users", query)
+	if !ok {
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		}
-		return
+	userIDs := make([]int, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
 	}
 
-	var users []map[string]interface{}
-	if err := json.Unmarshal(body, &users); err != nil {
-		fmt.Println("Ошибка парсинга данных пользователей:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных пользователей"})
+	// Получаем роли пользователей из нашей БД
+	roles, err := h.app.userRoles(userIDs)
+	if err != nil {
+		h.app.errorLog.Printf("Ошибка получения настроек пользователей: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
 		return
 	}
 
-	// Получаем роли для каждого пользователя из нашей БД
-	var members []map[string]interface{}
+	members := []map[string]interface{}{}
 	for _, user := range users {
-		userID := int(user["id"].(float64))
-		
-		// Получаем настройки пользователя из нашей БД
-		var settings struct {
-			Role string `json:"role"`
-		}
-		
-		err := h.app.db.QueryRow(context.Background(), `
-			SELECT us_role 
-			FROM user_settings 
-			WHERE us_user_id = $1
-		`, userID).Scan(&settings.Role)
-		
-		// Если настройки не найдены, используем значение по умолчанию
-		if err != nil {
-			if err == sql.ErrNoRows {
-				settings.Role = "developer"
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
-				return
-			}
-		}
-		
 		// Объединяем данные из GitLab и нашей БД
 		memberWithSettings := map[string]interface{}{
-			"id":         user["id"],
-			"name":       user["name"],
-			"email":      user["email"],
-			"avatar_url": user["avatar_url"],
-			"username":   user["username"],
-			"state":      user["state"],
+			"id":         user.ID,
+			"name":       user.Name,
+			"email":      user.Email,
+			"avatar_url": user.AvatarURL,
+			"username":   user.Username,
+			"state":      user.State,
 			"userSettings": map[string]interface{}{
-				"us_role": settings.Role,
+				"us_role": roles[user.ID],
 			},
 		}
-		
+
 		members = append(members, memberWithSettings)
 	}
 
-	c.JSON(http.StatusOK, members)
+	c.JSON(http.StatusOK, listResponse(members, resp))
 }
 
 func (h *OAuthHandler) CreateGitLabIssue(c *gin.Context) {
-	projectId := c.Param("id")            // ID проекта из URL
-	token := c.GetHeader("Authorization") // Токен авторизации
+	projectId := c.Param("id") // ID проекта из URL
 
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
@@ -427,61 +358,37 @@ func (h *OAuthHandler) CreateGitLabIssue(c *gin.Context) {
 		return
 	}
 
-	// Формируем URL для GitLab API
-	url := fmt.Sprintf("%s/api/v4/projects/%s/issues", h.gitlabBaseURL, projectId)
-
-	// Создаем запрос
-	reqBody, err := json.Marshal(map[string]string{
+	payload := map[string]string{
 		"title":       issueData.Title,
 		"description": issueData.Description,
 		"labels":      issueData.Labels,
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-
-	req.Header.Set("Authorization", token)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Отправляем запрос к GitLab API
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Обрабатываем ответ
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := ioutil.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		return
 	}
 
 	var createdIssue map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&createdIssue); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга ответа"})
+	path := fmt.Sprintf("/projects/%s/issues", url.PathEscape(projectId))
+	if _, err := client.Do(c.Request.Context(), http.MethodPost, path, nil, payload, &createdIssue); err != nil {
+		h.app.respondGitLabError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, createdIssue)
 }
 
+// SaveProjectMetadata создает проект в GitLab и сохраняет его метаданные
+// (даты, владельца, участников) в локальной базе
 func (h *OAuthHandler) SaveProjectMetadata(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
+		return
+	}
+
 	var projectData struct {
-		Title        string `json:"title"`
+		Title        string `json:"title" binding:"required"`
 		Description  string `json:"description"`
-		StartDate    string `json:"start_date"`
-		EndDate      string `json:"end_date"`
+		StartDate    string `json:"start_date" binding:"required"`
+		EndDate      string `json:"end_date" binding:"required"`
+		Visibility   string `json:"visibility"`
+		OwnerID      *int   `json:"owner_id"`
 		Participants []int  `json:"participants"`
 	}
 
@@ -489,44 +396,45 @@ func (h *OAuthHandler) SaveProjectMetadata(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные запроса"})
 		return
 	}
-
-	// Шаг 1: Создаем проект в GitLab
-	gitlabURL := fmt.Sprintf("%s/api/v4/projects", h.gitlabBaseURL)
-	gitlabResponse, err := http.PostForm(gitlabURL, url.Values{
-		"name":        {projectData.Title},
-		"description": {projectData.Description},
-		"visibility":  {"private"},
-	})
-
-	if err != nil || gitlabResponse.StatusCode != http.StatusCreated {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании проекта в GitLab"})
+	if err := validateProjectDates(projectData.StartDate, projectData.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if projectData.Visibility == "" {
+		projectData.Visibility = "private"
+	}
 
-	defer gitlabResponse.Body.Close()
-
-	// Парсим ответ от GitLab
-	var gitlabProject map[string]interface{}
-	if err := json.NewDecoder(gitlabResponse.Body).Decode(&gitlabProject); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга ответа от GitLab"})
+	prjID, gitlabProject, err := h.app.createProject(c.Request.Context(), client, newProject{
+		Title:        projectData.Title,
+		Description:  projectData.Description,
+		StartDate:    projectData.StartDate,
+		EndDate:      projectData.EndDate,
+		OwnerID:      projectData.OwnerID,
+		Participants: projectData.Participants,
+		GitLabOptions: map[string]interface{}{
+			"name":        projectData.Title,
+			"description": projectData.Description,
+			"visibility":  projectData.Visibility,
+		},
+	})
+	if err != nil {
+		h.app.respondError(c, err, "Ошибка при создании проекта")
 		return
 	}
 
-	gitlabProjectID := gitlabProject["id"].(float64) // ID проекта в GitLab
-
-	// Шаг 2: Сохраняем метаданные в вашей базе данных
-	// Здесь добавьте логику сохранения данных в вашу базу данных
-	// Например, используйте ORM или SQL-запросы
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Проект успешно создан",
 		"project": gin.H{
-			"gitlab_project_id": gitlabProjectID,
+			"id":                prjID,
+			"gitlab_project_id": gitlabProject.ID,
 			"title":             projectData.Title,
 			"description":       projectData.Description,
 			"start_date":        projectData.StartDate,
 			"end_date":          projectData.EndDate,
+			"status":            models.ProjectStatusActive,
+			"owner_id":          projectData.OwnerID,
 			"participants":      projectData.Participants,
+			"web_url":           gitlabProject.WebURL,
 		},
 	})
 }
@@ -536,31 +444,67 @@ type CreateSprintRequest struct {
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
 	Goals     string    `json:"goals"`
-	ProjectID int       `json:"project_id"`
+	// CreateMilestone создает для спринта веху GitLab, через которую состав спринта виден в GitLab
+	CreateMilestone bool `json:"create_milestone"`
 }
 
+// createSprint создает спринт в проекте из параметра маршрута :id, по которому
+// проверена роль пользователя
 func (app *application) createSprint(c *gin.Context) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
 	var req CreateSprintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат данных"})
 		return
 	}
 
-	if req.Title == "" || req.ProjectID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Необходимо указать название спринта и ID проекта"})
+	if req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Необходимо указать название спринта"})
 		return
 	}
 
-	sprintID, err := app.models.CreateSprint(req.Title, req.StartDate, req.EndDate, req.Goals, req.ProjectID)
+	var client *gitlab.Client
+	if req.CreateMilestone {
+		if client, ok = app.gitlabClient(c); !ok {
+			return
+		}
+	}
+
+	sprintID, err := app.models.CreateSprint(req.Title, req.StartDate, req.EndDate, req.Goals, projectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Ошибка при создании спринта: %v", err)})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":    "success",
 		"sprint_id": sprintID,
-	})
+	}
+
+	// Ошибка создания вехи не отменяет создание спринта: веху можно подключить позже
+	if client != nil {
+		sprint := &pgsql.Sprint{
+			SptID:        sprintID,
+			SptTitle:     req.Title,
+			SptStartDate: req.StartDate,
+			SptEndDate:   req.EndDate,
+			SptGoals:     req.Goals,
+			SptProjectID: projectID,
+		}
+		milestone, err := app.attachSprintMilestone(c.Request.Context(), client, sprint)
+		if milestone != nil {
+			response["milestone_id"] = milestone.ID
+		}
+		if err != nil {
+			response["milestone_error"] = app.milestoneSyncError(sprintID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // getSprints получает список спринтов проекта
@@ -580,30 +524,51 @@ func (app *application) getSprints(c *gin.Context) {
 	c.JSON(http.StatusOK, sprints)
 }
 
-type AddIssueToSprintRequest struct {
-	SprintID    int    `json:"sprint_id"`
-	IssueID     int    `json:"issue_id"`
-	StoryPoints int    `json:"story_points"`
-	Priority    string `json:"priority"`
-	NameIssue   string `json:"name_issue"`
-	DescriptionIssue string `json:"description_issue"`
-}
+// projectSprint получает спринт из параметра маршрута :sprintId и проверяет, что он
+// принадлежит проекту :id, по которому проверена роль пользователя. Спринт другого
+// проекта считается ненайденным.
+func (app *application) projectSprint(c *gin.Context) (pgsql.Sprint, bool) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return pgsql.Sprint{}, false
+	}
 
-func (app *application) addIssueToSprint(c *gin.Context) {
 	sprintID, err := strconv.Atoi(c.Param("sprintId"))
 	if err != nil {
 		app.errorLog.Printf("Неверный формат ID спринта: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
-		return
+		return pgsql.Sprint{}, false
 	}
 
-	// Проверяем существование спринта
-	_, err = app.models.GetSprint(sprintID)
+	sprint, err := app.models.GetSprint(sprintID)
 	if err != nil {
 		app.errorLog.Printf("Ошибка получения спринта: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+		return pgsql.Sprint{}, false
+	}
+	if sprint.SptProjectID != projectID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+		return pgsql.Sprint{}, false
+	}
+	return sprint, true
+}
+
+type AddIssueToSprintRequest struct {
+	SprintID    int    `json:"sprint_id"`
+	IssueID     int    `json:"issue_id"`
+	StoryPoints int    `json:"story_points"`
+	Priority    string `json:"priority"`
+	NameIssue   string `json:"name_issue"`
+	DescriptionIssue string `json:"description_issue"`
+}
+
+func (app *application) addIssueToSprint(c *gin.Context) {
+	// Проверяем существование спринта в проекте
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
 	var req AddIssueToSprintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -637,7 +602,21 @@ func (app *application) addIssueToSprint(c *gin.Context) {
 	}
 
 	app.infoLog.Printf("Задача %d успешно добавлена в спринт %d", req.IssueID, sprintID)
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	response := gin.H{"status": "success"}
+
+	// Проставляем задаче веху спринта, чтобы состав спринта был виден в GitLab
+	if sprint.SptMilestoneID != nil {
+		client := app.optionalGitLabClient(c)
+		err := errMilestoneNoToken
+		if client != nil {
+			err = setIssueMilestone(c.Request.Context(), client, sprint.SptProjectID, req.IssueID, *sprint.SptMilestoneID)
+		}
+		if err != nil {
+			response["milestone_error"] = app.milestoneSyncError(sprintID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // getSprint получает данные конкретного спринта
@@ -657,7 +636,71 @@ func (app *application) getSprint(c *gin.Context) {
 	c.JSON(http.StatusOK, sprint)
 }
 
-// getSprintIssues получает задачи спринта и синхронизирует их статусы с GitLab
+// getSyncReport возвращает расхождения задач спринта с GitLab, найденные фоновой сверкой.
+// Параметр since (RFC3339) ограничивает период, по умолчанию — последние 7 дней.
+func (app *application) getSyncReport(c *gin.Context) {
+	sprintID, err := strconv.Atoi(c.Param("sprintId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -7)
+	if raw := c.Query("since"); raw != "" {
+		since, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр since должен быть в формате RFC3339"})
+			return
+		}
+	}
+
+	issues, err := app.models.GetSprintIssues(sprintID)
+	if err != nil {
+		if err == models.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+			return
+		}
+		app.errorLog.Printf("Ошибка получения задач спринта %d: %v", sprintID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить задачи спринта"})
+		return
+	}
+
+	discrepancies, err := app.models.GetSyncDiscrepancies(sprintID, since)
+	if err != nil {
+		app.errorLog.Printf("Ошибка получения отчета синхронизации спринта %d: %v", sprintID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить отчет синхронизации"})
+		return
+	}
+
+	// Задачи, которых больше нет в проекте GitLab, требуют решения менеджера
+	missing := []models.SprintIssue{}
+	var lastSyncedAt *time.Time
+	for _, issue := range issues {
+		if issue.GitLabState == gitlabStateDeleted || issue.GitLabState == gitlabStateMoved {
+			missing = append(missing, issue)
+		}
+		if issue.LastSyncedAt != nil && (lastSyncedAt == nil || issue.LastSyncedAt.After(*lastSyncedAt)) {
+			lastSyncedAt = issue.LastSyncedAt
+		}
+	}
+
+	summary := map[string]int{}
+	for _, d := range discrepancies {
+		summary[d.Kind]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sprint_id":      sprintID,
+		"since":          since,
+		"last_synced_at": lastSyncedAt,
+		"summary":        summary,
+		"missing_issues": missing,
+		"discrepancies":  discrepancies,
+	})
+}
+
+// getSprintIssues получает задачи спринта из базы данных.
+// Статусы синхронизируются с GitLab фоновым обработчиком (см. syncWorker).
 func (app *application) getSprintIssues(c *gin.Context) {
     sprintID, err := strconv.Atoi(c.Param("sprintId"))
     if err != nil {
@@ -665,27 +708,7 @@ func (app *application) getSprintIssues(c *gin.Context) {
         return
     }
 
-    // Получаем токен из заголовка
-    token := c.GetHeader("Authorization")
-    if token == "" {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
-        return
-    }
-
-    // Получаем ID проекта из параметров запроса
-    projectID, err := strconv.Atoi(c.Param("id"))
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID проекта"})
-        return
-    }
-
-    // Синхронизируем статусы задач с GitLab
-    if err := app.syncAllIssuesWithGitLab(sprintID, projectID, token); err != nil {
-        app.errorLog.Printf("Ошибка синхронизации задач с GitLab: %v", err)
-        // Продолжаем выполнение даже при ошибке синхронизации
-    }
-
-    // Получаем обновленные задачи
+    // Получаем задачи
     issues, err := app.models.GetSprintIssues(sprintID)
     if err != nil {
         if err == models.ErrNoRecord {
@@ -713,12 +736,11 @@ type UpdateIssueAssigneeRequest struct {
 }
 
 func (app *application) updateIssueAssignee(c *gin.Context) {
-	sprintID, err := strconv.Atoi(c.Param("sprintId"))
-	if err != nil {
-		app.errorLog.Printf("Неверный формат ID спринта: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
 	var req UpdateIssueAssigneeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -736,7 +758,7 @@ func (app *application) updateIssueAssignee(c *gin.Context) {
 		return
 	}
 
-	err = app.models.UpdateSprintIssueAssignee(sprintID, req.IssueID, req.AssigneeID)
+	err := app.models.UpdateSprintIssueAssignee(sprintID, req.IssueID, req.AssigneeID)
 	if err != nil {
 		app.errorLog.Printf("Ошибка обновления участника задачи: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -757,43 +779,102 @@ type GitLabWebhookRequest struct {
 		ID   int    `json:"id"`
 		Name string `json:"name"`
 	} `json:"project"`
-	Commits []struct {
-		ID        string    `json:"id"`
-		Message   string    `json:"message"`
-		Title     string    `json:"title"`
-		Timestamp string    `json:"timestamp"`
-		Author    struct {
-			Name  string `json:"name"`
-			Email string `json:"email"`
-		} `json:"author"`
-	} `json:"commits"`
+	Commits []GitLabWebhookCommit `json:"commits"`
 	Repository struct {
 		Name        string `json:"name"`
 		URL         string `json:"url"`
 		Description string `json:"description"`
 	} `json:"repository"`
 	// Добавляем поля для Merge Request
-	ObjectAttributes struct {
-		ID          int       `json:"id"`
-		IID         int       `json:"iid"`
-		Title       string    `json:"title"`
-		Description string    `json:"description"`
-		State       string    `json:"state"`
-		CreatedAt   string    `json:"created_at"`
-		UpdatedAt   string    `json:"updated_at"`
-		SourceBranch string   `json:"source_branch"`
-		TargetBranch string   `json:"target_branch"`
-		LastCommit struct {
-			ID string `json:"id"`
-		} `json:"last_commit"`
-	} `json:"object_attributes"`
+	ObjectAttributes GitLabMergeRequestAttributes `json:"object_attributes"`
+	// Пользователь, совершивший действие (для merge_request событий)
+	User      GitLabWebhookUser   `json:"user"`
+	Reviewers []GitLabWebhookUser `json:"reviewers"`
+	Assignees []GitLabWebhookUser `json:"assignees"`
+	// Измененные атрибуты (для issue событий)
+	Changes GitLabWebhookChanges `json:"changes"`
+}
+
+// GitLabWebhookChanges описывает изменения атрибутов задачи в вебхуке GitLab
+type GitLabWebhookChanges struct {
+	MilestoneID *struct {
+		Previous *int `json:"previous"`
+		Current  *int `json:"current"`
+	} `json:"milestone_id"`
+}
+
+// GitLabWebhookCommit описывает коммит в push-вебхуке GitLab
+type GitLabWebhookCommit struct {
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+	Title     string `json:"title"`
+	Timestamp string `json:"timestamp"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+// GitLabMergeRequestAttributes описывает мердж-реквест в вебхуке GitLab
+type GitLabMergeRequestAttributes struct {
+	ID              int    `json:"id"`
+	IID             int    `json:"iid"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	State           string `json:"state"`
+	Action          string `json:"action"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+	MergedAt        string `json:"merged_at"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	SourceProjectID int    `json:"source_project_id"`
+	TargetProjectID int    `json:"target_project_id"`
+	AuthorID        int    `json:"author_id"`
+	AssigneeIDs     []int  `json:"assignee_ids"`
+	ReviewerIDs     []int  `json:"reviewer_ids"`
+	MergeUserID     int    `json:"merge_user_id"`
+	MergeStatus     string `json:"merge_status"`
+	MergeCommitSHA  string `json:"merge_commit_sha"`
+	Draft           bool   `json:"draft"`
+	URL             string `json:"url"`
+	Weight          *int   `json:"weight"` // вес задачи (для issue событий)
+	LastCommit      struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"last_commit"`
+}
+
+// GitLabWebhookUser описывает пользователя в теле вебхука GitLab
+type GitLabWebhookUser struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// parseGitLabTime разбирает время из вебхука GitLab.
+// В зависимости от версии GitLab присылает RFC3339 или формат "2006-01-02 15:04:05 UTC".
+func parseGitLabTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("неизвестный формат времени: %s", value)
 }
 
 // HandleGitLabWebhook обрабатывает вебхуки от GitLab
 func (app *application) HandleGitLabWebhook(c *gin.Context) {
 	eventType := c.Request.Header.Get("X-Gitlab-Event")
 	app.infoLog.Printf("Получен вебхук от GitLab: %s", eventType)
-	app.infoLog.Printf("Заголовки запроса: %v", c.Request.Header)
 
 	// Читаем тело запроса для логирования
 	body, err := ioutil.ReadAll(c.Request.Body)
@@ -804,8 +885,6 @@ func (app *application) HandleGitLabWebhook(c *gin.Context) {
 	}
 	// Восстанавливаем тело запроса для дальнейшей обработки
 	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-	
-	app.infoLog.Printf("Тело вебхука: %s", string(body))
 
 	var webhook GitLabWebhookRequest
 	if err := c.ShouldBindJSON(&webhook); err != nil {
@@ -814,6 +893,26 @@ func (app *application) HandleGitLabWebhook(c *gin.Context) {
 		return
 	}
 
+	// Вебхуки, зарегистрированные приложением, подписаны секретом проекта,
+	// установленные вручную — общим секретом webhook-secret
+	valid, err := app.verifyWebhookToken(webhook.Project.ID, c.GetHeader("X-Gitlab-Token"))
+	if err != nil {
+		app.errorLog.Printf("Ошибка проверки секрета вебхука: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки вебхука"})
+		return
+	}
+	if !valid {
+		app.errorLog.Printf("Неверный секрет вебхука для проекта %d", webhook.Project.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен вебхука"})
+		return
+	}
+
+	// Заголовки и тело попадают в журнал только после проверки секрета; сам секрет скрыт
+	headers := c.Request.Header.Clone()
+	headers.Set("X-Gitlab-Token", "[скрыт]")
+	app.infoLog.Printf("Заголовки запроса: %v", headers)
+	app.infoLog.Printf("Тело вебхука: %s", string(body))
+
 	app.infoLog.Printf("Тип события: %s, Project ID: %d, Project Name: %s", 
 		webhook.EventName, webhook.Project.ID, webhook.Project.Name)
 	app.infoLog.Printf("ObjectKind: %s, State: %s", 
@@ -837,6 +936,12 @@ func (app *application) HandleGitLabWebhook(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+	case "issue":
+		if err := app.handleGitLabIssueMilestone(webhook); err != nil {
+			app.errorLog.Printf("Ошибка обработки issue события: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	default:
 		app.infoLog.Printf("Получено событие: %s", webhook.ObjectKind)
 	}
@@ -883,6 +988,24 @@ func extractIssueIDFromCommitMessage(message string) int {
 	return 0
 }
 
+// revertedMergeRequestPattern находит ссылку на отмененный мердж-реквест в revert-коммите
+var revertedMergeRequestPattern = regexp.MustCompile(`This reverts merge request !(\d+)`)
+
+// isRevertCommit проверяет, является ли коммит revert-коммитом GitLab/git
+func isRevertCommit(message string) bool {
+	return strings.HasPrefix(message, "Revert \"") || strings.Contains(message, "This reverts commit ") ||
+		revertedMergeRequestPattern.MatchString(message)
+}
+
+// extractIssueIDFromRevertCommit извлекает ID задачи из заголовка отмененного коммита,
+// который git помещает в кавычки: Revert "Fix #123 ..."
+func extractIssueIDFromRevertCommit(message string) int {
+	title := strings.SplitN(message, "\n", 2)[0]
+	title = strings.TrimPrefix(title, "Revert \"")
+	title = strings.TrimSuffix(title, "\"")
+	return extractIssueIDFromCommitMessage(title)
+}
+
 // handleGitLabPush обрабатывает события push (коммиты)
 func (app *application) handleGitLabPush(webhook GitLabWebhookRequest) error {
 	if len(webhook.Commits) == 0 {
@@ -893,6 +1016,12 @@ func (app *application) handleGitLabPush(webhook GitLabWebhookRequest) error {
 	for _, commit := range webhook.Commits {
 		app.infoLog.Printf("Обработка коммита: %s, сообщение: %s", commit.ID, commit.Message)
 
+		// Revert-коммиты переоткрывают задачу
+		if isRevertCommit(commit.Message) {
+			app.handleRevertCommit(webhook.Project.ID, commit.Message)
+			continue
+		}
+
 		// Пропускаем мердж-коммиты, они обрабатываются в handleGitLabMergeRequest
 		if strings.HasPrefix(commit.Message, "Merge branch ") {
 			app.infoLog.Printf("Пропускаем мердж-коммит, он будет обработан в handleGitLabMergeRequest")
@@ -908,8 +1037,12 @@ func (app *application) handleGitLabPush(webhook GitLabWebhookRequest) error {
 
 		app.infoLog.Printf("Найдена ссылка на задачу #%d в коммите", issueID)
 
-		// Получаем спринт, в котором находится задача
-		sprintID, err := app.models.GetSprintIDByIssueID(issueID)
+		// Получаем спринт проекта, в котором находится задача
+		sprintID, err := app.models.GetProjectSprintIDByIssueID(webhook.Project.ID, issueID)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.infoLog.Printf("Задача #%d проекта %d не входит в спринты", issueID, webhook.Project.ID)
+			continue
+		}
 		if err != nil {
 			app.errorLog.Printf("Ошибка получения спринта для задачи %d: %v", issueID, err)
 			continue
@@ -917,19 +1050,20 @@ func (app *application) handleGitLabPush(webhook GitLabWebhookRequest) error {
 
 		// Получаем текущий статус задачи
 		issue, err := app.models.GetSprintIssue(sprintID, issueID)
-		if err != nil {
+		if err != nil || issue == nil {
 			app.errorLog.Printf("Ошибка получения статуса задачи %d: %v", issueID, err)
 			continue
 		}
 
-		// Если задача уже в статусе "Готово", не меняем статус
-		if issue.Status == "Готово" {
-			app.infoLog.Printf("Задача %d уже в статусе 'Готово', пропускаем обновление", issueID)
+		newStatus := models.NextIssueStatus(models.IssueState{
+			Status:   issue.Status,
+			Assigned: issue.AssignedTo != nil,
+		}, models.EventCommit)
+		if newStatus == issue.Status {
+			app.infoLog.Printf("Задача %d остается в статусе '%s'", issueID, issue.Status)
 			continue
 		}
 
-		// Устанавливаем статус "На проверке" для обычных коммитов
-		newStatus := "На проверке"
 		app.infoLog.Printf("Обновление статуса задачи %d на '%s'", issueID, newStatus)
 
 		// Парсим время коммита из строки ISO 8601
@@ -960,119 +1094,275 @@ func (app *application) handleGitLabPush(webhook GitLabWebhookRequest) error {
 	return nil
 }
 
-// extractIssueIDFromMergeRequest извлекает ID задачи из названия или описания мердж-реквеста
-func extractIssueIDFromMergeRequest(title, description string) int {
-	// Разбиваем описание на строки
-	lines := strings.Split(description, "\n")
-	
-	// Проверяем каждую строку на наличие ключевых слов
-	for _, line := range lines {
+// handleRevertCommit переоткрывает задачу, на которую ссылается revert-коммит
+func (app *application) handleRevertCommit(projectID int, message string) {
+	var sprintID int
+
+	issueID := extractIssueIDFromRevertCommit(message)
+	if issueID != 0 {
+		id, err := app.models.GetProjectSprintIDByIssueID(projectID, issueID)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.infoLog.Printf("Задача #%d проекта %d не входит в спринты", issueID, projectID)
+			return
+		}
+		if err != nil {
+			app.errorLog.Printf("Ошибка получения спринта для задачи %d: %v", issueID, err)
+			return
+		}
+		sprintID = id
+	} else if match := revertedMergeRequestPattern.FindStringSubmatch(message); match != nil {
+		// Revert мердж-коммита ссылается на MR, по нему находим задачу
+		mrIID, _ := strconv.Atoi(match[1])
+		id, issue, err := app.models.GetMergeRequestIssue(projectID, mrIID)
+		if err != nil {
+			app.infoLog.Printf("Отмененный мердж-реквест !%d не связан с задачей спринта: %v", mrIID, err)
+			return
+		}
+		sprintID, issueID = id, issue
+	} else {
+		app.infoLog.Printf("Revert-коммит не содержит ссылки на задачу: %s", message)
+		return
+	}
+
+	issue, err := app.models.GetSprintIssue(sprintID, issueID)
+	if err != nil || issue == nil {
+		app.errorLog.Printf("Ошибка получения статуса задачи %d: %v", issueID, err)
+		return
+	}
+
+	newStatus := models.NextIssueStatus(models.IssueState{
+		Status:   issue.Status,
+		Assigned: issue.AssignedTo != nil,
+	}, models.EventRevert)
+	if newStatus == issue.Status {
+		return
+	}
+
+	app.infoLog.Printf("Revert-коммит: задача %d переоткрыта, статус '%s'", issueID, newStatus)
+	if err := app.models.UpdateSprintIssueStatus(sprintID, issueID, newStatus, nil, nil, "", nil); err != nil {
+		app.errorLog.Printf("Ошибка обновления статуса задачи %d: %v", issueID, err)
+	}
+}
+
+// extractIssueIDsFromMergeRequest извлекает номера всех задач, на которые ссылается
+// мердж-реквест: строки описания вида "Closes #123", "Fixes #123", "Resolves #123"
+// или "#123", а также "Fix #123" и "#123" в начале названия. Номера не повторяются.
+func extractIssueIDsFromMergeRequest(title, description string) []int {
+	var issueIDs []int
+	add := func(issueID int) {
+		for _, id := range issueIDs {
+			if id == issueID {
+				return
+			}
+		}
+		issueIDs = append(issueIDs, issueID)
+	}
+
+	// Форматы: "Closes #123", "Fixes #123", "Resolves #123", "#123"
+	keywords := []string{"Closes #%d", "Fixes #%d", "Resolves #%d", "#%d"}
+	for _, line := range strings.Split(description, "\n") {
 		line = strings.TrimSpace(line)
-		
-		// Пробуем разные форматы с разными ключевыми словами
-		var issueID int
-		
-		// Форматы: "Closes #123", "Fixes #123", "Resolves #123"
-		keywords := []string{"Closes", "Fixes", "Resolves"}
-		for _, keyword := range keywords {
-			pattern := fmt.Sprintf("%s #%%d", keyword)
+		for _, pattern := range keywords {
+			var issueID int
 			if _, err := fmt.Sscanf(line, pattern, &issueID); err == nil {
-				return issueID
+				add(issueID)
+				break
 			}
 		}
-		
-		// Формат "Fix #123" в названии
-		if _, err := fmt.Sscanf(title, "Fix #%d", &issueID); err == nil {
-			return issueID
-		}
-		
-		// Формат "#123" в названии или описании
-		if _, err := fmt.Sscanf(line, "#%d", &issueID); err == nil {
-			return issueID
-		}
 	}
-	
-	// Если в описании не нашли, проверяем название
-	var issueID int
-	if _, err := fmt.Sscanf(title, "#%d", &issueID); err == nil {
-		return issueID
+
+	// Форматы "Fix #123" и "#123" в названии
+	for _, pattern := range []string{"Fix #%d", "#%d"} {
+		var issueID int
+		if _, err := fmt.Sscanf(title, pattern, &issueID); err == nil {
+			add(issueID)
+		}
 	}
-	
-	return 0
+
+	return issueIDs
 }
 
-// handleGitLabMergeRequest обрабатывает события мердж-реквеста
+// handleGitLabMergeRequest обрабатывает события мердж-реквеста для каждой задачи,
+// на которую он ссылается
 func (app *application) handleGitLabMergeRequest(webhook GitLabWebhookRequest) error {
-	app.infoLog.Printf("Обработка мердж-реквеста #%d: %s (состояние: %s)", 
-		webhook.ObjectAttributes.IID, 
+	app.infoLog.Printf("Обработка мердж-реквеста #%d: %s (состояние: %s)",
+		webhook.ObjectAttributes.IID,
 		webhook.ObjectAttributes.Title,
 		webhook.ObjectAttributes.State)
-	
+
 	app.infoLog.Printf("Описание мердж-реквеста: %s", webhook.ObjectAttributes.Description)
 
-	// Извлекаем номер задачи из названия или описания мердж-реквеста
-	issueID := extractIssueIDFromMergeRequest(webhook.ObjectAttributes.Title, webhook.ObjectAttributes.Description)
-	if issueID == 0 {
+	// Извлекаем номера задач из названия или описания мердж-реквеста
+	issueIDs := extractIssueIDsFromMergeRequest(webhook.ObjectAttributes.Title, webhook.ObjectAttributes.Description)
+	if len(issueIDs) == 0 {
 		app.infoLog.Printf("Мердж-реквест не содержит ссылки на задачу: %s", webhook.ObjectAttributes.Title)
 		return nil
 	}
 
+	var errs []error
+	for _, issueID := range issueIDs {
+		if err := app.handleMergeRequestIssue(webhook, issueID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleMergeRequestIssue сохраняет мердж-реквест для задачи спринта и обновляет ее статус
+func (app *application) handleMergeRequestIssue(webhook GitLabWebhookRequest, issueID int) error {
 	app.infoLog.Printf("Найдена ссылка на задачу #%d в мердж-реквесте", issueID)
 
-	// Получаем спринт, в котором находится задача
-	sprintID, err := app.models.GetSprintIDByIssueID(issueID)
+	// Получаем спринт проекта, в котором находится задача
+	sprintID, err := app.models.GetProjectSprintIDByIssueID(webhook.Project.ID, issueID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.infoLog.Printf("Задача #%d проекта %d не входит в спринты", issueID, webhook.Project.ID)
+		return nil
+	}
 	if err != nil {
 		app.errorLog.Printf("Ошибка получения спринта для задачи %d: %v", issueID, err)
 		return err
 	}
 
-	app.infoLog.Printf("Обновление статуса задачи %d в спринте %d (состояние MR: %s)", 
+	// Сохраняем актуальные сведения о мердж-реквесте
+	if err := app.recordMergeRequest(webhook, sprintID, issueID); err != nil {
+		app.errorLog.Printf("Ошибка сохранения мердж-реквеста !%d: %v", webhook.ObjectAttributes.IID, err)
+		return err
+	}
+
+	app.infoLog.Printf("Обновление статуса задачи %d в спринте %d (состояние MR: %s)",
 		issueID, sprintID, webhook.ObjectAttributes.State)
 
-	// Проверяем состояние мердж-реквеста
+	issue, err := app.models.GetSprintIssue(sprintID, issueID)
+	if err != nil || issue == nil {
+		app.errorLog.Printf("Ошибка получения статуса задачи %d: %v", issueID, err)
+		return fmt.Errorf("задача %d не найдена в спринте %d", issueID, sprintID)
+	}
+
+	state := models.IssueState{
+		Status:   issue.Status,
+		Assigned: issue.AssignedTo != nil,
+	}
+
+	// Определяем событие по состоянию мердж-реквеста
+	var event models.IssueEvent
+	var lastMerge *time.Time
 	switch webhook.ObjectAttributes.State {
 	case "merged":
-		updatedAt := time.Now()
-
-		app.infoLog.Printf("Мердж-реквест слит, обновляем статус задачи на 'Готово' (время: %v)", updatedAt)
-		err = app.models.UpdateSprintIssueStatus(
-			sprintID,
-			issueID,
-			"Готово",
-			nil,
-			&updatedAt,
-			webhook.ObjectAttributes.SourceBranch,
-			&webhook.ObjectAttributes.IID,
-		)
-		if err != nil {
-			app.errorLog.Printf("Ошибка обновления статуса задачи: %v", err)
-			return err
+		// Время слияния берем из события: повторно доставленный или задержанный
+		// вебхук не должен сдвигать его на момент обработки
+		lastMerge = mergeRequestMergedAt(webhook.ObjectAttributes)
+		if lastMerge == nil {
+			now := time.Now()
+			lastMerge = &now
 		}
-		app.infoLog.Printf("Статус задачи %d успешно обновлен после мерджа", issueID)
+		event = models.EventMRMerged
+		app.infoLog.Printf("Мердж-реквест слит (время: %v)", *lastMerge)
 
 	case "opened", "reopened":
-		app.infoLog.Printf("Мердж-реквест открыт/переоткрыт, обновляем статус задачи на 'На проверке'")
-		err = app.models.UpdateSprintIssueStatus(
-			sprintID,
-			issueID,
-			"На проверке",
-			nil,
-			nil,
-			webhook.ObjectAttributes.SourceBranch,
-			&webhook.ObjectAttributes.IID,
-		)
-		if err != nil {
-			app.errorLog.Printf("Ошибка обновления статуса задачи: %v", err)
-			return err
+		// Обновления и одобрения открытого MR не меняют статус задачи
+		switch webhook.ObjectAttributes.Action {
+		case "update", "approved", "unapproved", "approval", "unapproval":
+			app.infoLog.Printf("Мердж-реквест обновлен (%s), статус задачи не меняется", webhook.ObjectAttributes.Action)
+			return nil
 		}
-		app.infoLog.Printf("Статус задачи %d обновлен на 'На проверке'", issueID)
+		event = models.EventMROpened
+		if webhook.ObjectAttributes.Draft {
+			// Черновик MR означает, что работа над задачей только началась
+			event = models.EventWorkStarted
+		}
+		app.infoLog.Printf("Мердж-реквест открыт/переоткрыт (черновик: %t)", webhook.ObjectAttributes.Draft)
 
 	case "closed":
-		app.infoLog.Printf("Мердж-реквест закрыт без слияния")
-		// Можно добавить логику для обработки закрытого без слияния MR
+		// Закрываемый MR не учитываем, даже если его состояние еще не сохранено
+		openMRs, err := app.models.CountOpenMergeRequests(sprintID, issueID, webhook.Project.ID, webhook.ObjectAttributes.IID)
+		if err != nil {
+			app.errorLog.Printf("Ошибка подсчета открытых мердж-реквестов: %v", err)
+			return err
+		}
+		state.OpenMRs = openMRs
+		event = models.EventMRClosed
+		app.infoLog.Printf("Мердж-реквест закрыт без слияния, открытых MR задачи: %d", openMRs)
 
 	default:
 		app.infoLog.Printf("Неизвестное состояние мердж-реквеста: %s", webhook.ObjectAttributes.State)
+		return nil
+	}
+
+	newStatus := models.NextIssueStatus(state, event)
+	err = app.models.UpdateSprintIssueStatus(
+		sprintID,
+		issueID,
+		newStatus,
+		nil,
+		lastMerge,
+		webhook.ObjectAttributes.SourceBranch,
+		&webhook.ObjectAttributes.IID,
+	)
+	if err != nil {
+		app.errorLog.Printf("Ошибка обновления статуса задачи: %v", err)
+		return err
+	}
+	app.infoLog.Printf("Статус задачи %d обновлен: '%s' -> '%s'", issueID, state.Status, newStatus)
+
+	return nil
+}
+
+// mergeRequestMergedAt возвращает время слияния MR из вебхука. В старых версиях
+// GitLab merged_at отсутствует, тогда слитый MR датируется временем обновления.
+func mergeRequestMergedAt(attrs GitLabMergeRequestAttributes) *time.Time {
+	mergedAt, err := parseGitLabTime(attrs.MergedAt)
+	if (err != nil || mergedAt == nil) && attrs.State == "merged" {
+		mergedAt, _ = parseGitLabTime(attrs.UpdatedAt)
+	}
+	return mergedAt
+}
+
+// recordMergeRequest сохраняет данные мердж-реквеста из вебхука для задачи спринта
+func (app *application) recordMergeRequest(webhook GitLabWebhookRequest, sprintID, issueID int) error {
+	attrs := webhook.ObjectAttributes
+
+	mr := &models.MergeRequest{
+		SprintID:     sprintID,
+		IssueID:      issueID,
+		ProjectID:    webhook.Project.ID,
+		MRID:         attrs.ID,
+		MRIID:        attrs.IID,
+		Title:        attrs.Title,
+		State:        attrs.State,
+		AuthorID:     attrs.AuthorID,
+		SourceBranch: attrs.SourceBranch,
+		TargetBranch: attrs.TargetBranch,
+		WebURL:       attrs.URL,
+		Reviewers:    []string{},
+	}
+
+	// Автор MR присутствует в поле user только при открытии
+	if attrs.Action == "open" || webhook.User.ID == attrs.AuthorID {
+		mr.AuthorUsername = webhook.User.Username
+	}
+
+	for _, reviewer := range webhook.Reviewers {
+		mr.Reviewers = append(mr.Reviewers, reviewer.Username)
+	}
+
+	createdAt, err := parseGitLabTime(attrs.CreatedAt)
+	if err != nil {
+		app.errorLog.Printf("Ошибка парсинга времени создания MR: %v", err)
+	}
+	mr.CreatedAt = createdAt
+
+	mr.MergedAt = mergeRequestMergedAt(attrs)
+
+	if err := app.models.UpsertMergeRequest(mr); err != nil {
+		return err
+	}
+
+	// Одобрения приходят отдельными действиями от конкретного пользователя
+	switch attrs.Action {
+	case "approved", "approval":
+		return app.models.AddMergeRequestApproval(mr.ProjectID, mr.MRIID, webhook.User.Username)
+	case "unapproved", "unapproval":
+		return app.models.RemoveMergeRequestApproval(mr.ProjectID, mr.MRIID, webhook.User.Username)
 	}
 
 	return nil
@@ -1106,36 +1396,25 @@ func (app *application) getSprintIssue(c *gin.Context) {
 		return
 	}
 
-	// Получаем дополнительную информацию из GitLab
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
-		return
-	}
-
-	// Получаем информацию о коммитах и мердж-реквестах из GitLab
-	gitlabURL := fmt.Sprintf("%s/api/v4/projects/%s/issues/%d", app.oauthHandler.gitlabBaseURL, c.Param("id"), issueID)
-	req, err := http.NewRequest("GET", gitlabURL, nil)
+	// Получаем все мердж-реквесты, ссылающиеся на задачу
+	mergeRequests, err := app.models.GetSprintIssueMergeRequests(sprintID, issueID)
 	if err != nil {
-		app.errorLog.Printf("Ошибка создания запроса к GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
+		app.errorLog.Printf("Ошибка при получении мердж-реквестов задачи: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить мердж-реквесты задачи"})
 		return
 	}
 
-	req.Header.Set("Authorization", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		app.errorLog.Printf("Ошибка запроса к GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
+	// Получаем дополнительную информацию из GitLab
+	client, ok := app.gitlabClient(c)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
 
+	// Получаем информацию о коммитах и мердж-реквестах из GitLab
 	var gitlabIssue map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&gitlabIssue); err != nil {
-		app.errorLog.Printf("Ошибка парсинга ответа от GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обработки ответа от GitLab"})
+	gitlabPath := fmt.Sprintf("/projects/%s/issues/%d", url.PathEscape(c.Param("id")), issueID)
+	if _, err := client.Do(c.Request.Context(), http.MethodGet, gitlabPath, nil, nil, &gitlabIssue); err != nil {
+		app.respondGitLabError(c, err)
 		return
 	}
 
@@ -1150,107 +1429,21 @@ func (app *application) getSprintIssue(c *gin.Context) {
 		"si_last_commit": issue.LastCommit,
 		"si_last_merge": issue.LastMerge,
 		"si_branch_name": issue.BranchName,
-		"si_mr_id": issue.MRID,
+		"merge_requests": mergeRequests,
 		"gitlab_data": gitlabIssue,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// syncIssueStatusWithGitLab синхронизирует статус задачи с GitLab
-func (app *application) syncIssueStatusWithGitLab(projectID int, issueID int, token string) error {
-	// Получаем информацию о задаче из GitLab
-	gitlabURL := fmt.Sprintf("%s/api/v4/projects/%d/issues/%d", app.oauthHandler.gitlabBaseURL, projectID, issueID)
-	req, err := http.NewRequest("GET", gitlabURL, nil)
-	if err != nil {
-		return fmt.Errorf("ошибка создания запроса к GitLab: %v", err)
-	}
-
-	req.Header.Set("Authorization", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка запроса к GitLab: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var gitlabIssue struct {
-		State string `json:"state"`
-		IID   int    `json:"iid"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&gitlabIssue); err != nil {
-		return fmt.Errorf("ошибка парсинга ответа от GitLab: %v", err)
-	}
-
-	// Если задача в GitLab закрыта, обновляем статус в нашей системе
-	if gitlabIssue.State == "closed" {
-		// Получаем спринт, в котором находится задача
-		sprintID, err := app.models.GetSprintIDByIssueID(issueID)
-		if err != nil {
-			return fmt.Errorf("ошибка получения спринта для задачи %d: %v", issueID, err)
-		}
-
-		// Получаем текущий статус задачи
-		issue, err := app.models.GetSprintIssue(sprintID, issueID)
-		if err != nil {
-			return fmt.Errorf("ошибка получения задачи %d: %v", issueID, err)
-		}
-
-		// Если статус не "Готово", обновляем его
-		if issue.Status != "Готово" {
-			app.infoLog.Printf("Синхронизация: задача #%d в GitLab закрыта, обновляем статус на 'Готово'", issueID)
-			err = app.models.UpdateSprintIssueStatus(
-				sprintID,
-				issueID,
-				"Готово",
-				nil,
-				nil,
-				issue.BranchName,
-				&gitlabIssue.IID,
-			)
-			if err != nil {
-				return fmt.Errorf("ошибка обновления статуса задачи: %v", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// syncAllIssuesWithGitLab синхронизирует статусы всех задач в спринте с GitLab
-func (app *application) syncAllIssuesWithGitLab(sprintID int, projectID int, token string) error {
-	// Получаем все задачи спринта
-	issues, err := app.models.GetSprintIssues(sprintID)
-	if err != nil {
-		return fmt.Errorf("ошибка получения задач спринта: %v", err)
-	}
-
-	// Синхронизируем каждую задачу
-	for _, issue := range issues {
-		if err := app.syncIssueStatusWithGitLab(projectID, issue.IssueID, token); err != nil {
-			app.errorLog.Printf("Ошибка синхронизации задачи #%d: %v", issue.IssueID, err)
-			// Продолжаем с другими задачами даже если одна не удалась
-			continue
-		}
-	}
-
-	return nil
-}
-
 // completeSprint обрабатывает запрос на завершение спринта
 func (app *application) completeSprint(c *gin.Context) {
-	sprintID, err := strconv.Atoi(c.Param("sprintId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
-		return
-	}
-
-	// Проверяем существование спринта
-	sprint, err := app.models.GetSprint(sprintID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+	// Проверяем существование спринта в проекте
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
 	// Проверяем, не завершен ли уже спринт
 	if sprint.SptStatus == "completed" {
@@ -1259,105 +1452,74 @@ func (app *application) completeSprint(c *gin.Context) {
 	}
 
 	// Завершаем спринт
-	err = app.models.CompleteSprint(sprintID)
+	err := app.models.CompleteSprint(sprintID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Ошибка при завершении спринта: %v", err)})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status": "success",
 		"message": "Спринт успешно завершен",
-	})
+	}
+
+	// Закрываем связанную веху GitLab
+	if sprint.SptMilestoneID != nil {
+		client := app.optionalGitLabClient(c)
+		err := errMilestoneNoToken
+		if client != nil {
+			_, err = client.UpdateMilestone(c.Request.Context(), strconv.Itoa(sprint.SptProjectID), *sprint.SptMilestoneID,
+				map[string]interface{}{"state_event": "close"})
+		}
+		if err != nil {
+			response["milestone_error"] = app.milestoneSyncError(sprintID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *OAuthHandler) GitLabProjectMembersHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
 	projectID := c.Param("id")
-	url := fmt.Sprintf("%s/api/v4/projects/%s/members", h.gitlabBaseURL, projectID)
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-	req.Header.Set("Authorization", token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	members, err := client.ListProjectMembers(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
+		h.app.respondGitLabError(c, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	// Роли из локальной БД: глобальные и назначенные в проекте (проект, заданный
+	// путем, а не ID, назначенных ролей не имеет)
+	numericID, _ := strconv.Atoi(projectID)
+	roles, err := h.app.memberProjectRoles(numericID, members)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		}
+		h.app.errorLog.Printf("Ошибка получения настроек пользователей: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
 		return
 	}
 
-	var members []map[string]interface{}
-	if err := json.Unmarshal(body, &members); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных"})
-		return
-	}
-
-	// Получаем настройки пользователей из локальной БД
 	var membersWithSettings []map[string]interface{}
 	for _, member := range members {
-		userID := int(member["id"].(float64))
-		
-		// Получаем настройки пользователя из локальной БД
-		var settings struct {
-			Role string `json:"role"`
-		}
-		
-		// Получаем настройки пользователя из БД
-		err := h.app.db.QueryRow(context.Background(), `
-			SELECT us_role 
-			FROM user_settings 
-			WHERE us_user_id = $1
-		`, userID).Scan(&settings.Role)
-		
-		// Если настройки не найдены, используем значение по умолчанию
-		if err != nil {
-			if err == sql.ErrNoRows {
-				settings.Role = "developer"
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
-				return
-			}
-		}
-		
 		// Объединяем данные из GitLab и локальной БД
 		memberWithSettings := map[string]interface{}{
-			"id": member["id"],
-			"name": member["name"],
-			"username": member["username"],
-			"email": member["email"],
-			"avatar_url": member["avatar_url"],
-			"created_at": member["created_at"],
+			"id":                  member.ID,
+			"name":                member.Name,
+			"username":            member.Username,
+			"email":               member.Email,
+			"avatar_url":          member.AvatarURL,
+			"created_at":          member.CreatedAt,
+			"access_level":        member.AccessLevel,
+			"project_role":        roles[member.ID].Project,
+			"project_role_source": roles[member.ID].Source,
 			"userSettings": map[string]interface{}{
-				"us_role": settings.Role,
+				"us_role": roles[member.ID].Global,
 			},
 		}
-		
+
 		membersWithSettings = append(membersWithSettings, memberWithSettings)
 	}
 
@@ -1382,15 +1544,9 @@ func (h *OAuthHandler) UpdateUserRoleHandler(c *gin.Context) {
 	}
 
 	// Проверяем валидность роли
-	validRoles := map[string]bool{
-		"administrator":   true,
-		"project_manager": true,
-		"developer":      true,
-	}
-
-	if !validRoles[req.Role] {
+	if !containsString(validRoles(), req.Role) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Неверная роль пользователя. Допустимые роли: %v", getValidRoles()),
+			"error": fmt.Sprintf("Неверная роль пользователя. Допустимые роли: %v", validRoles()),
 		})
 		return
 	}
@@ -1414,16 +1570,16 @@ func (h *OAuthHandler) UpdateUserRoleHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Роль пользователя успешно обновлена"})
 }
 
-func getValidRoles() []string {
-	return []string{"project_manager", "developer"}
-}
-
 type CreateGitLabProjectRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
 	Visibility  string `json:"visibility" binding:"required"`
 	StartDate   string `json:"start_date" binding:"required"`
 	EndDate     string `json:"end_date" binding:"required"`
+	TemplateID  *int   `json:"template_id"` // необязательный шаблон начальной настройки проекта
+	// Владелец и участники из локальной таблицы users
+	OwnerID      *int  `json:"owner_id"`
+	Participants []int `json:"participants"`
 }
 
 // transliterate преобразует кириллицу в латиницу
@@ -1452,11 +1608,25 @@ func transliterate(s string) string {
 	return result.String()
 }
 
+// slugify преобразует строку в безопасный для GitLab путь (проекта, ветки)
+func slugify(s string) string {
+	slug := strings.ToLower(transliterate(s))
+	// Заменяем пробелы и специальные символы на дефисы
+	slug = strings.ReplaceAll(slug, " ", "-")
+	slug = strings.ReplaceAll(slug, "_", "-")
+	// Удаляем все символы кроме букв, цифр и дефисов
+	reg := regexp.MustCompile(`[^a-z0-9-]`)
+	slug = reg.ReplaceAllString(slug, "")
+	// Удаляем множественные дефисы
+	slug = strings.ReplaceAll(slug, "--", "-")
+	// Удаляем дефисы в начале и конце
+	return strings.Trim(slug, "-")
+}
+
 // CreateGitLabProject создает новый проект в GitLab
 func (h *OAuthHandler) CreateGitLabProject(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
@@ -1470,109 +1640,89 @@ func (h *OAuthHandler) CreateGitLabProject(c *gin.Context) {
 	// Логируем полученные данные
 	h.app.infoLog.Printf("Получен запрос на создание проекта: %+v", req)
 
-	// Формируем URL для создания проекта в GitLab
-	url := fmt.Sprintf("%s/api/v4/projects", h.gitlabBaseURL)
+	if err := validateProjectDates(req.StartDate, req.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Шаблон проверяем до создания проекта, чтобы не оставить проект без настройки
+	var template *models.ProjectTemplate
+	if req.TemplateID != nil {
+		var err error
+		template, err = h.app.models.GetProjectTemplate(*req.TemplateID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Шаблон проекта не найден"})
+				return
+			}
+			h.app.errorLog.Printf("Ошибка получения шаблона %d: %v", *req.TemplateID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить шаблон проекта"})
+			return
+		}
+	}
 
 	// Генерируем безопасный путь для проекта
-	safePath := strings.ToLower(transliterate(req.Name))
-	// Заменяем пробелы и специальные символы на дефисы
-	safePath = strings.ReplaceAll(safePath, " ", "-")
-	safePath = strings.ReplaceAll(safePath, "_", "-")
-	// Удаляем все символы кроме букв, цифр и дефисов
-	reg := regexp.MustCompile(`[^a-z0-9-]`)
-	safePath = reg.ReplaceAllString(safePath, "")
-	// Удаляем множественные дефисы
-	safePath = strings.ReplaceAll(safePath, "--", "-")
-	// Удаляем дефисы в начале и конце
-	safePath = strings.Trim(safePath, "-")
+	safePath := slugify(req.Name)
 	// Добавляем временную метку для уникальности
 	safePath = fmt.Sprintf("%s-%d", safePath, time.Now().Unix())
 
 	// Создаем тело запроса
 	projectData := map[string]interface{}{
-		"name":                 req.Name,
-		"description":          req.Description,
-		"visibility":           req.Visibility,
+		"name":                   req.Name,
+		"description":            req.Description,
+		"visibility":             req.Visibility,
 		"initialize_with_readme": true,
-		"default_branch":       "main",
-		"path":                safePath,
-	}
-
-	jsonData, err := json.Marshal(projectData)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка маршалинга данных: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
+		"default_branch":         "main",
+		"path":                   safePath,
 	}
 
 	// Логируем данные, отправляемые в GitLab
-	h.app.infoLog.Printf("Отправляем данные в GitLab: %s", string(jsonData))
-
-	// Создаем HTTP запрос
-	request, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка создания HTTP запроса: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-
-	request.Header.Set("Authorization", token)
-	request.Header.Set("Content-Type", "application/json")
-
-	// Отправляем запрос
-	client := &http.Client{}
-	resp, err := client.Do(request)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка отправки запроса в GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Читаем ответ
-	body, err := ioutil.ReadAll(resp.Body)
+	h.app.infoLog.Printf("Отправляем данные в GitLab: %+v", projectData)
+
+	prjID, gitlabProject, err := h.app.createProject(c.Request.Context(), client, newProject{
+		Title:         req.Name,
+		Description:   req.Description,
+		StartDate:     req.StartDate,
+		EndDate:       req.EndDate,
+		OwnerID:       req.OwnerID,
+		Participants:  req.Participants,
+		GitLabOptions: projectData,
+	})
 	if err != nil {
-		h.app.errorLog.Printf("Ошибка чтения ответа от GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
-		return
-	}
-
-	// Логируем ответ от GitLab
-	h.app.infoLog.Printf("Ответ от GitLab (статус %d): %s", resp.StatusCode, string(body))
-
-	if resp.StatusCode != http.StatusCreated {
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			h.app.errorLog.Printf("Ошибка от GitLab: %v", errorResponse)
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			h.app.errorLog.Printf("Неизвестная ошибка от GitLab: %s", string(body))
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		}
-		return
-	}
-
-	// Парсим ответ от GitLab
-	var gitlabProject map[string]interface{}
-	if err := json.Unmarshal(body, &gitlabProject); err != nil {
-		h.app.errorLog.Printf("Ошибка парсинга ответа от GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга ответа"})
+		h.app.respondError(c, err, "Ошибка при создании проекта")
 		return
 	}
 
-	// Возвращаем успешный ответ
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "Проект успешно создан",
 		"project": gin.H{
-			"gitlab_id":   gitlabProject["id"],
+			"id":          prjID,
+			"gitlab_id":   gitlabProject.ID,
 			"name":        req.Name,
 			"description": req.Description,
 			"start_date":  req.StartDate,
 			"end_date":    req.EndDate,
 			"visibility":  req.Visibility,
-			"web_url":     gitlabProject["web_url"],
+			"web_url":     gitlabProject.WebURL,
 		},
-	})
+	}
+
+	// Регистрируем вебхук; проект уже создан, поэтому ошибка не отменяет создание,
+	// вебхук можно подключить позже через POST /api/gitlab/projects/:id/webhook
+	webhook, err := h.app.registerProjectWebhook(c.Request.Context(), client, gitlabProject.ID)
+	if err != nil {
+		h.app.errorLog.Printf("Не удалось зарегистрировать вебхук проекта %d: %v", gitlabProject.ID, err)
+		response["webhook_error"] = err.Error()
+	} else {
+		response["webhook"] = webhook
+	}
+
+	if template != nil {
+		response["template"] = h.app.applyProjectTemplate(c.Request.Context(), client, gitlabProject.ID, "main", template, req.StartDate, req.EndDate)
+	}
+
+	// Возвращаем успешный ответ
+	c.JSON(http.StatusCreated, response)
 }
 
 type UpdateProjectRequest struct {
@@ -1580,9 +1730,8 @@ type UpdateProjectRequest struct {
 }
 
 func (h *OAuthHandler) UpdateGitLabProject(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
@@ -1599,85 +1748,39 @@ func (h *OAuthHandler) UpdateGitLabProject(c *gin.Context) {
 		return
 	}
 
-	// Формируем URL для обновления проекта в GitLab
-	url := fmt.Sprintf("%s/api/v4/projects/%s", h.gitlabBaseURL, projectID)
-
-	// Создаем тело запроса
-	projectData := map[string]interface{}{
+	gitlabProject, err := client.UpdateProject(c.Request.Context(), projectID, map[string]interface{}{
 		"description": req.Description,
-	}
-
-	jsonData, err := json.Marshal(projectData)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка маршалинга данных: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-
-	// Создаем HTTP запрос
-	request, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка создания HTTP запроса: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
-		return
-	}
-
-	request.Header.Set("Authorization", token)
-	request.Header.Set("Content-Type", "application/json")
-
-	// Отправляем запрос
-	client := &http.Client{}
-	resp, err := client.Do(request)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка отправки запроса в GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Читаем ответ
-	body, err := ioutil.ReadAll(resp.Body)
+	})
 	if err != nil {
-		h.app.errorLog.Printf("Ошибка чтения ответа от GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
+		h.app.respondGitLabError(c, err)
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			h.app.errorLog.Printf("Ошибка от GitLab: %v", errorResponse)
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			h.app.errorLog.Printf("Неизвестная ошибка от GitLab: %s", string(body))
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
+	// Описание дублируется в локальной записи проекта, если она есть
+	if project, err := h.app.models.GetProjectByGitLabID(gitlabProject.ID); err == nil {
+		err = h.app.models.UpdateProject(project.PrjTitle, gitlabProject.Description,
+			project.PrjStartDate.Format("2006-01-02"), project.PrjEndDate.Format("2006-01-02"), project.PrjID)
+		if err != nil {
+			h.app.errorLog.Printf("Ошибка обновления локального проекта %d: %v", project.PrjID, err)
 		}
-		return
-	}
-
-	// Парсим ответ от GitLab
-	var gitlabProject map[string]interface{}
-	if err := json.Unmarshal(body, &gitlabProject); err != nil {
-		h.app.errorLog.Printf("Ошибка парсинга ответа от GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга ответа"})
-		return
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		h.app.errorLog.Printf("Ошибка получения локального проекта для GitLab %d: %v", gitlabProject.ID, err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Описание проекта успешно обновлено",
 		"project": gin.H{
-			"gitlab_id":   gitlabProject["id"],
-			"name":        gitlabProject["name"],
-			"description": gitlabProject["description"],
-			"web_url":     gitlabProject["web_url"],
+			"gitlab_id":   gitlabProject.ID,
+			"name":        gitlabProject.Name,
+			"description": gitlabProject.Description,
+			"web_url":     gitlabProject.WebURL,
 		},
 	})
 }
 
 func (h *OAuthHandler) DeleteGitLabProject(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
@@ -1687,40 +1790,16 @@ func (h *OAuthHandler) DeleteGitLabProject(c *gin.Context) {
 		return
 	}
 
-	// Формируем URL для удаления проекта в GitLab
-	url := fmt.Sprintf("%s/api/v4/projects/%s", h.gitlabBaseURL, projectID)
-
-	// Создаем HTTP запрос
-	request, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка создания HTTP запроса: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
+	if err := client.DeleteProject(c.Request.Context(), projectID); err != nil {
+		h.app.respondGitLabError(c, err)
 		return
 	}
 
-	request.Header.Set("Authorization", token)
-
-	// Отправляем запрос
-	client := &http.Client{}
-	resp, err := client.Do(request)
-	if err != nil {
-		h.app.errorLog.Printf("Ошибка отправки запроса в GitLab: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			h.app.errorLog.Printf("Ошибка от GitLab: %v", errorResponse)
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			h.app.errorLog.Printf("Неизвестная ошибка от GitLab: %s", string(body))
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
+	// Данные плагина о проекте больше не нужны
+	if gitlabID, err := strconv.Atoi(projectID); err == nil {
+		if err := h.app.models.UnlinkProject(gitlabID); err != nil && !errors.Is(err, models.ErrNoRecord) {
+			h.app.errorLog.Printf("Ошибка удаления локальных данных проекта GitLab %d: %v", gitlabID, err)
 		}
-		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -1731,143 +1810,88 @@ func (h *OAuthHandler) DeleteGitLabProject(c *gin.Context) {
 // DeleteGitLabIssue удаляет задачу через GitLab API
 func (h *OAuthHandler) DeleteGitLabIssue(c *gin.Context) {
 	projectID := c.Param("id")
-	issueID := c.Param("issueId")
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Отсутствует токен авторизации"})
-		return
-	}
-
-	// Удаляем префикс "Bearer " из токена, если он есть
-	token = strings.TrimPrefix(token, "Bearer ")
 
-	url := fmt.Sprintf("%s/api/v4/projects/%s/issues/%s", h.gitlabBaseURL, projectID, issueID)
-	req, err := http.NewRequest("DELETE", url, nil)
+	issueID, err := strconv.Atoi(c.Param("issueId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании запроса"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID задачи"})
 		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при выполнении запроса"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{"error": fmt.Sprintf("Ошибка при удалении задачи: %s", string(body))})
+	if err := client.DeleteIssue(c.Request.Context(), projectID, issueID); err != nil {
+		h.app.errorLog.Printf("Ошибка при удалении задачи: %v", err)
+		c.JSON(gitlab.HTTPStatus(err), gin.H{"error": fmt.Sprintf("Ошибка при удалении задачи: %s", gitlab.Message(err))})
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-// GetUsersHandler возвращает список всех пользователей
+// GetUsersHandler возвращает список пользователей с поддержкой поиска и пагинации
 func (h *OAuthHandler) GetUsersHandler(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
 		return
 	}
 
-	// Получаем список пользователей из GitLab
-	url := fmt.Sprintf("%s/api/v4/users", h.gitlabBaseURL)
-	req, err := http.NewRequest("GET", url, nil)
+	query, err := parseListQuery(c, userListQuery)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания запроса"})
+		respondInvalidQuery(c, err)
 		return
 	}
-	req.Header.Set("Authorization", token)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка запроса к GitLab"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения ответа"})
+	// Получаем список пользователей из GitLab
+	users, resp, ok := listGitLab[gitlab.User](h.app, c, client, "/users", query)
+	if !ok {
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		if err := json.Unmarshal(body, &errorResponse); err == nil {
-			c.JSON(resp.StatusCode, gin.H{"error": errorResponse["message"]})
-		} else {
-			c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		}
-		return
+	userIDs := make([]int, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
 	}
 
-	var users []map[string]interface{}
-	if err := json.Unmarshal(body, &users); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка парсинга данных"})
+	// Получаем настройки пользователей из локальной БД
+	roles, err := h.app.userRoles(userIDs)
+	if err != nil {
+		h.app.errorLog.Printf("Ошибка получения настроек пользователей: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
 		return
 	}
 
-	// Получаем настройки пользователей из локальной БД
-	var usersWithSettings []map[string]interface{}
+	usersWithSettings := []map[string]interface{}{}
 	for _, user := range users {
-		userID := int(user["id"].(float64))
-		
-		// Получаем настройки пользователя из локальной БД
-		var settings struct {
-			Role string `json:"role"`
-		}
-		
-		// Получаем настройки пользователя из БД
-		err := h.app.db.QueryRow(context.Background(), `
-			SELECT us_role 
-			FROM user_settings 
-			WHERE us_user_id = $1
-		`, userID).Scan(&settings.Role)
-		
-		// Если настройки не найдены, используем значение по умолчанию
-		if err != nil {
-			if err == sql.ErrNoRows {
-				settings.Role = "developer"
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек пользователя"})
-				return
-			}
-		}
-		
 		// Объединяем данные из GitLab и локальной БД
 		userWithSettings := map[string]interface{}{
-			"id": user["id"],
-			"name": user["name"],
-			"username": user["username"],
-			"email": user["email"],
-			"avatar_url": user["avatar_url"],
-			"created_at": user["created_at"],
+			"id":         user.ID,
+			"name":       user.Name,
+			"username":   user.Username,
+			"email":      user.Email,
+			"avatar_url": user.AvatarURL,
+			"created_at": user.CreatedAt,
 			"userSettings": map[string]interface{}{
-				"us_role": settings.Role,
+				"us_role": roles[user.ID],
 			},
 		}
-		
+
 		usersWithSettings = append(usersWithSettings, userWithSettings)
 	}
 
-	c.JSON(http.StatusOK, usersWithSettings)
+	c.JSON(http.StatusOK, listResponse(usersWithSettings, resp))
 }
 
 func (app *application) updateIssueStatus(c *gin.Context) {
-	sprintID, err := strconv.Atoi(c.Param("sprintId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
-	issueID, err := strconv.Atoi(c.Param("issueId"))
+	issueID, err := strconv.Atoi(c.Param("taskId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID задачи"})
 		return
@@ -1883,9 +1907,8 @@ func (app *application) updateIssueStatus(c *gin.Context) {
 	}
 
 	// Проверяем валидность статуса
-	validStatuses := []string{"К выполнению", "В работе", "На проверке", "Готово", "Заблокировано"}
 	isValid := false
-	for _, status := range validStatuses {
+	for _, status := range models.IssueStatuses {
 		if status == req.Status {
 			isValid = true
 			break
@@ -1908,16 +1931,13 @@ func (app *application) updateIssueStatus(c *gin.Context) {
 }
 
 func (app *application) deleteSprintIssue(c *gin.Context) {
-	sprintID := c.Param("sprintID")
-	issueID := c.Param("issueID")
-
-	sprintIDInt, err := strconv.Atoi(sprintID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sprint ID"})
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintIDInt := sprint.SptID
 
-	issueIDInt, err := strconv.Atoi(issueID)
+	issueIDInt, err := strconv.Atoi(c.Param("taskId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue ID"})
 		return
@@ -1931,7 +1951,21 @@ func (app *application) deleteSprintIssue(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Sprint issue deleted successfully"})
+	response := gin.H{"message": "Sprint issue deleted successfully"}
+
+	// Снимаем с задачи веху спринта
+	if sprint.SptMilestoneID != nil {
+		client := app.optionalGitLabClient(c)
+		err := errMilestoneNoToken
+		if client != nil {
+			err = clearIssueMilestone(c.Request.Context(), client, sprint.SptProjectID, issueIDInt, *sprint.SptMilestoneID)
+		}
+		if err != nil {
+			response["milestone_error"] = app.milestoneSyncError(sprintIDInt, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 type UpdateSprintRequest struct {
@@ -1939,24 +1973,18 @@ type UpdateSprintRequest struct {
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
 	Goals     string    `json:"goals"`
+	// CreateMilestone создает веху GitLab для спринта, у которого ее еще нет
+	CreateMilestone bool `json:"create_milestone"`
 }
 
 // updateSprint обрабатывает запрос на обновление спринта
 func (app *application) updateSprint(c *gin.Context) {
-	sprintID, err := strconv.Atoi(c.Param("sprintId"))
-	if err != nil {
-		app.errorLog.Printf("Неверный формат ID спринта: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
-		return
-	}
-
-	// Проверяем существование спринта
-	_, err = app.models.GetSprint(sprintID)
-	if err != nil {
-		app.errorLog.Printf("Ошибка получения спринта: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+	// Проверяем существование спринта в проекте
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
 	var req UpdateSprintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1966,7 +1994,7 @@ func (app *application) updateSprint(c *gin.Context) {
 	}
 
 	// Обновляем спринт
-	err = app.models.UpdateSprint(sprintID, req.Title, req.StartDate, req.EndDate, req.Goals)
+	err := app.models.UpdateSprint(sprintID, req.Title, req.StartDate, req.EndDate, req.Goals)
 	if err != nil {
 		app.errorLog.Printf("Ошибка обновления спринта: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить спринт"})
@@ -1981,28 +2009,39 @@ func (app *application) updateSprint(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "Спринт успешно обновлен",
-		"sprint":  updatedSprint,
-	})
+		"sprint":  &updatedSprint,
+	}
+
+	// Переносим изменения в связанную веху или создаем ее по запросу
+	if sprint.SptMilestoneID != nil || req.CreateMilestone {
+		client := app.optionalGitLabClient(c)
+		err := errMilestoneNoToken
+		if client != nil {
+			if sprint.SptMilestoneID != nil {
+				_, err = client.UpdateMilestone(c.Request.Context(), strconv.Itoa(sprint.SptProjectID), *sprint.SptMilestoneID,
+					sprintMilestoneOptions(req.Title, req.Goals, req.StartDate, req.EndDate))
+			} else {
+				_, err = app.attachSprintMilestone(c.Request.Context(), client, &updatedSprint)
+			}
+		}
+		if err != nil {
+			response["milestone_error"] = app.milestoneSyncError(sprintID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // deleteSprint обрабатывает запрос на удаление спринта
 func (app *application) deleteSprint(c *gin.Context) {
-	sprintID, err := strconv.Atoi(c.Param("sprintId"))
-	if err != nil {
-		app.errorLog.Printf("Неверный формат ID спринта: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID спринта"})
-		return
-	}
-
-	// Проверяем существование спринта
-	sprint, err := app.models.GetSprint(sprintID)
-	if err != nil {
-		app.errorLog.Printf("Ошибка получения спринта: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Спринт не найден"})
+	// Проверяем существование спринта в проекте
+	sprint, ok := app.projectSprint(c)
+	if !ok {
 		return
 	}
+	sprintID := sprint.SptID
 
 	// Проверяем, не завершен ли спринт
 	if sprint.SptStatus == "completed" {
@@ -2011,7 +2050,7 @@ func (app *application) deleteSprint(c *gin.Context) {
 	}
 
 	// Удаляем спринт
-	err = app.models.DeleteSprint(sprintID)
+	err := app.models.DeleteSprint(sprintID)
 	if err != nil {
 		app.errorLog.Printf("Ошибка удаления спринта: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить спринт"})
@@ -2022,3 +2061,230 @@ func (app *application) deleteSprint(c *gin.Context) {
 		"message": "Спринт успешно удален",
 	})
 }
+
+// issueBranchName формирует имя ветки для задачи в формате GitLab: "<iid>-<название>"
+func issueBranchName(issueID int, title string) string {
+	name := fmt.Sprintf("%d-%s", issueID, slugify(title))
+	if len(name) > 60 {
+		name = strings.TrimRight(name[:60], "-")
+	}
+	return name
+}
+
+type CreateIssueBranchRequest struct {
+	Ref string `json:"ref"`
+}
+
+// sprintIssueFromParams разбирает параметры маршрута и получает задачу спринта
+// проекта :id
+func (app *application) sprintIssueFromParams(c *gin.Context) (string, *models.SprintIssue, bool) {
+	sprint, ok := app.projectSprint(c)
+	if !ok {
+		return "", nil, false
+	}
+
+	issueID, err := strconv.Atoi(c.Param("taskId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID задачи"})
+		return "", nil, false
+	}
+
+	issue, err := app.models.GetSprintIssue(sprint.SptID, issueID)
+	if err != nil {
+		app.errorLog.Printf("Ошибка при получении задачи: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить информацию о задаче"})
+		return "", nil, false
+	}
+	if issue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return "", nil, false
+	}
+
+	return strconv.Itoa(sprint.SptProjectID), issue, true
+}
+
+// ensureIssueBranch создает ветку задачи в GitLab, если она еще не создана,
+// и возвращает имя ветки и ветку, от которой она создана
+func (app *application) ensureIssueBranch(ctx context.Context, client *gitlab.Client, projectID string, issue *models.SprintIssue, ref string) (string, string, error) {
+	if ref == "" {
+		project, err := client.GetProject(ctx, projectID)
+		if err != nil {
+			return "", "", err
+		}
+		ref = project.DefaultBranch
+	}
+
+	if issue.BranchName != "" {
+		return issue.BranchName, ref, nil
+	}
+
+	branchName := issueBranchName(issue.IssueID, issue.Title)
+	if _, err := client.CreateBranch(ctx, projectID, branchName, ref); err != nil {
+		return "", "", err
+	}
+
+	app.infoLog.Printf("Создана ветка %s для задачи #%d от %s", branchName, issue.IssueID, ref)
+	return branchName, ref, nil
+}
+
+// createIssueBranch создает ветку GitLab для задачи спринта и переводит задачу "В работе"
+func (app *application) createIssueBranch(c *gin.Context) {
+	client, ok := app.gitlabClient(c)
+	if !ok {
+		return
+	}
+
+	projectID, issue, ok := app.sprintIssueFromParams(c)
+	if !ok {
+		return
+	}
+
+	if issue.BranchName != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ветка для задачи уже создана", "branch_name": issue.BranchName})
+		return
+	}
+
+	var req CreateIssueBranchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат данных"})
+			return
+		}
+	}
+
+	branchName, _, err := app.ensureIssueBranch(c.Request.Context(), client, projectID, issue, req.Ref)
+	if err != nil {
+		app.errorLog.Printf("Ошибка создания ветки для задачи #%d: %v", issue.IssueID, err)
+		app.respondGitLabError(c, err)
+		return
+	}
+
+	newStatus := models.NextIssueStatus(models.IssueState{
+		Status:   issue.Status,
+		Assigned: issue.AssignedTo != nil,
+	}, models.EventWorkStarted)
+	err = app.models.UpdateSprintIssueStatus(issue.SprintID, issue.IssueID, newStatus, nil, nil, branchName, nil)
+	if err != nil {
+		app.errorLog.Printf("Ошибка сохранения ветки задачи #%d: %v", issue.IssueID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ветка создана, но не удалось обновить задачу"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"branch_name": branchName,
+		"status":      newStatus,
+	})
+}
+
+// respondMergeRequestExists отвечает 409 с мердж-реквестом, уже связанным с задачей
+func (app *application) respondMergeRequestExists(c *gin.Context, issue *models.SprintIssue) {
+	response := gin.H{
+		"error":             "Мердж-реквест для задачи уже создан",
+		"branch_name":       issue.BranchName,
+		"merge_request_iid": *issue.MRID,
+	}
+
+	mergeRequests, err := app.models.GetSprintIssueMergeRequests(issue.SprintID, issue.IssueID)
+	if err != nil {
+		app.errorLog.Printf("Ошибка при получении мердж-реквестов задачи: %v", err)
+	}
+	for i := range mergeRequests {
+		if mergeRequests[i].MRIID == *issue.MRID {
+			response["merge_request"] = mergeRequests[i]
+			break
+		}
+	}
+
+	c.JSON(http.StatusConflict, response)
+}
+
+// createIssueMergeRequest создает черновик MR с "Closes #N" для задачи спринта
+func (app *application) createIssueMergeRequest(c *gin.Context) {
+	client, ok := app.gitlabClient(c)
+	if !ok {
+		return
+	}
+
+	projectID, issue, ok := app.sprintIssueFromParams(c)
+	if !ok {
+		return
+	}
+
+	if issue.MRID != nil {
+		app.respondMergeRequestExists(c, issue)
+		return
+	}
+
+	var req CreateIssueBranchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат данных"})
+			return
+		}
+	}
+
+	// Ветку создаем автоматически, если ее еще нет
+	branchName, targetBranch, err := app.ensureIssueBranch(c.Request.Context(), client, projectID, issue, req.Ref)
+	if err != nil {
+		app.errorLog.Printf("Ошибка создания ветки для задачи #%d: %v", issue.IssueID, err)
+		app.respondGitLabError(c, err)
+		return
+	}
+
+	payload := map[string]interface{}{
+		"source_branch":        branchName,
+		"target_branch":        targetBranch,
+		"title":                fmt.Sprintf("Draft: %s", issue.Title),
+		"description":          fmt.Sprintf("Closes #%d", issue.IssueID),
+		"remove_source_branch": true,
+	}
+	created, err := client.CreateMergeRequest(c.Request.Context(), projectID, payload)
+	if err != nil {
+		app.errorLog.Printf("Ошибка создания мердж-реквеста для задачи #%d: %v", issue.IssueID, err)
+		app.respondGitLabError(c, err)
+		return
+	}
+
+	// Сначала связываем MR с задачей: повторный запрос получит 409, а не второй MR
+	newStatus := models.NextIssueStatus(models.IssueState{
+		Status:   issue.Status,
+		Assigned: issue.AssignedTo != nil,
+	}, models.EventWorkStarted)
+	err = app.models.UpdateSprintIssueStatus(issue.SprintID, issue.IssueID, newStatus, nil, nil, branchName, &created.IID)
+	if err != nil {
+		app.errorLog.Printf("Ошибка сохранения мердж-реквеста задачи #%d: %v", issue.IssueID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Мердж-реквест создан, но не удалось обновить задачу"})
+		return
+	}
+
+	mr := &models.MergeRequest{
+		SprintID:     issue.SprintID,
+		IssueID:      issue.IssueID,
+		ProjectID:    created.ProjectID,
+		MRID:         created.ID,
+		MRIID:        created.IID,
+		Title:        created.Title,
+		State:        created.State,
+		Reviewers:    []string{},
+		SourceBranch: branchName,
+		TargetBranch: targetBranch,
+		WebURL:       created.WebURL,
+		CreatedAt:    created.CreatedAt,
+	}
+	if created.Author != nil {
+		mr.AuthorID = created.Author.ID
+		mr.AuthorUsername = created.Author.Username
+	}
+	if err := app.models.UpsertMergeRequest(mr); err != nil {
+		app.errorLog.Printf("Ошибка сохранения мердж-реквеста !%d: %v", created.IID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Мердж-реквест создан, но не удалось сохранить его данные"})
+		return
+	}
+
+	app.infoLog.Printf("Создан мердж-реквест !%d для задачи #%d", created.IID, issue.IssueID)
+	c.JSON(http.StatusCreated, gin.H{
+		"branch_name":   branchName,
+		"status":        newStatus,
+		"merge_request": mr,
+	})
+}
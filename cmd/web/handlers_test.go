@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseGitLabTime(t *testing.T) {
+	want := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    *time.Time
+		wantErr bool
+	}{
+		{"пустое значение", "", nil, false},
+		{"RFC3339", "2024-05-10T12:30:00Z", &want, false},
+		{"RFC3339 со смещением", "2024-05-10T15:30:00+03:00", &want, false},
+		{"формат вебхука с зоной", "2024-05-10 12:30:00 UTC", &want, false},
+		{"формат вебхука со смещением", "2024-05-10 15:30:00 +0300", &want, false},
+		{"неизвестный формат", "10.05.2024", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGitLabTime(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("parseGitLabTime(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeRequestMergedAt(t *testing.T) {
+	merged := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	updated := time.Date(2024, 5, 11, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		attrs GitLabMergeRequestAttributes
+		want  *time.Time
+	}{
+		{
+			name:  "время слияния из события",
+			attrs: GitLabMergeRequestAttributes{State: "merged", MergedAt: "2024-05-10 12:30:00 UTC", UpdatedAt: "2024-05-11 09:00:00 UTC"},
+			want:  &merged,
+		},
+		{
+			name:  "без merged_at берется время обновления",
+			attrs: GitLabMergeRequestAttributes{State: "merged", UpdatedAt: "2024-05-11 09:00:00 UTC"},
+			want:  &updated,
+		},
+		{
+			name:  "нечитаемый merged_at",
+			attrs: GitLabMergeRequestAttributes{State: "merged", MergedAt: "вчера", UpdatedAt: "2024-05-11T09:00:00Z"},
+			want:  &updated,
+		},
+		{
+			name:  "открытый MR не слит",
+			attrs: GitLabMergeRequestAttributes{State: "opened", UpdatedAt: "2024-05-11 09:00:00 UTC"},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeRequestMergedAt(tt.attrs)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("mergeRequestMergedAt() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractIssueIDsFromMergeRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		want        []int
+	}{
+		{"Closes в описании", "Draft: Новая форма", "Closes #42", []int{42}},
+		{"Fixes во второй строке", "Правки", "Описание\nFixes #7", []int{7}},
+		{"Resolves", "Правки", "Resolves #15", []int{15}},
+		{"номер в описании", "Правки", "#9 доработка", []int{9}},
+		{"Fix в названии", "Fix #3 опечатка", "без ссылки", []int{3}},
+		{"номер в названии", "#12 форма входа", "", []int{12}},
+		{"несколько задач", "Правки", "Closes #3\nCloses #4", []int{3, 4}},
+		{"разные форматы", "Fix #5 форма", "Fixes #3\n  Resolves #4\n#6 заодно", []int{3, 4, 6, 5}},
+		{"повторная ссылка", "#3 форма", "Closes #3\nCloses #3", []int{3}},
+		{"без ссылки", "Рефакторинг", "Без задачи", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractIssueIDsFromMergeRequest(tt.title, tt.description)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("extractIssueIDsFromMergeRequest(%q, %q) = %v, ожидалось %v", tt.title, tt.description, got, tt.want)
+			}
+		})
+	}
+}
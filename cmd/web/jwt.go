@@ -0,0 +1,399 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// jwtAudience — получатель (aud) JWT доступа к API плагина
+const jwtAudience = "plaginagile-api"
+
+// contextKeyClaims — ключ контекста gin для утверждений проверенного JWT
+const contextKeyClaims = "jwt_claims"
+
+// errInvalidRefreshToken возвращается для неизвестного, истекшего или отозванного refresh-токена
+var errInvalidRefreshToken = errors.New("недействительный refresh-токен")
+
+// accessClaims — утверждения JWT доступа. sub — ID пользователя в GitLab,
+// sid — сессия, из которой берется токен GitLab для обращений к GitLab.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+}
+
+// jwtIssuer подписывает и проверяет JWT доступа (ES256)
+type jwtIssuer struct {
+	key       *ecdsa.PrivateKey
+	keyID     string
+	issuer    string
+	accessTTL time.Duration
+}
+
+// newJWTIssuer создает издателя JWT. Без ключа в конфигурации создается временный ключ:
+// выданные токены перестанут действовать после перезапуска.
+func newJWTIssuer(pemKey, issuer string, accessTTL time.Duration) (*jwtIssuer, bool, error) {
+	var key *ecdsa.PrivateKey
+	generated := false
+	if pemKey == "" {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, false, err
+		}
+		generated = true
+	} else {
+		var err error
+		if key, err = parseECPrivateKey(pemKey); err != nil {
+			return nil, false, fmt.Errorf("jwt-signing-key: %w", err)
+		}
+	}
+
+	// kid — отпечаток открытого ключа, чтобы клиенты находили ключ в JWKS
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(publicDER)
+
+	return &jwtIssuer{
+		key:       key,
+		keyID:     base64.RawURLEncoding.EncodeToString(sum[:16]),
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}, generated, nil
+}
+
+// parseECPrivateKey разбирает PEM-ключ ECDSA P-256 в формате SEC 1 или PKCS #8
+func parseECPrivateKey(pemKey string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("ключ должен быть в формате PEM")
+	}
+
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsed, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = parsed
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("нужен ключ ECDSA")
+		}
+		key = ecKey
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип PEM: %s", block.Type)
+	}
+
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("нужен ключ на кривой P-256")
+	}
+	return key, nil
+}
+
+// Sign выдает JWT доступа для пользователя сессии
+func (ji *jwtIssuer) Sign(session *models.Session, role string) (string, time.Time, error) {
+	jti, err := randomURLToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ji.accessTTL)
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ji.issuer,
+			Subject:   strconv.Itoa(session.UserID),
+			Audience:  jwt.ClaimStrings{jwtAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        jti,
+		},
+		Username:  session.Username,
+		Role:      role,
+		SessionID: session.ID,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = ji.keyID
+	signed, err := token.SignedString(ji.key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse проверяет подпись, издателя, получателя и срок действия JWT
+func (ji *jwtIssuer) Parse(raw string) (*accessClaims, error) {
+	claims := &accessClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			if kid, _ := token.Header["kid"].(string); kid != ji.keyID {
+				return nil, errors.New("неизвестный ключ подписи")
+			}
+			return &ji.key.PublicKey, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithIssuer(ji.issuer),
+		jwt.WithAudience(jwtAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("в токене нет сессии")
+	}
+	return claims, nil
+}
+
+// JWKS возвращает открытый ключ подписи в формате JSON Web Key Set (RFC 7517)
+func (ji *jwtIssuer) JWKS() gin.H {
+	size := (ji.key.Curve.Params().BitSize + 7) / 8
+	x := make([]byte, size)
+	y := make([]byte, size)
+	ji.key.PublicKey.X.FillBytes(x)
+	ji.key.PublicKey.Y.FillBytes(y)
+
+	return gin.H{
+		"keys": []gin.H{{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(x),
+			"y":   base64.RawURLEncoding.EncodeToString(y),
+			"kid": ji.keyID,
+			"use": "sig",
+			"alg": jwt.SigningMethodES256.Alg(),
+		}},
+	}
+}
+
+// tokenPair — ответ с JWT доступа и refresh-токеном
+type tokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// issueTokenPair выдает JWT доступа и refresh-токен для сессии. familyID связывает
+// цепочку ротируемых refresh-токенов; пустой familyID начинает новую цепочку.
+func (app *application) issueTokenPair(session *models.Session, familyID string) (*tokenPair, error) {
+	role, err := app.sessionRole(session)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := app.jwt.Sign(session, role)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подписать токен: %w", err)
+	}
+
+	if familyID == "" {
+		if familyID, err = randomURLToken(24); err != nil {
+			return nil, err
+		}
+	}
+	refreshToken, refreshID, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	// refresh-токен не переживает сессию, из которой он выдан
+	err = app.models.CreateRefreshToken(&models.RefreshToken{
+		ID:        refreshID,
+		FamilyID:  familyID,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// issueToken выдает токены пользователю, вошедшему через GitLab (по куке сессии)
+func (app *application) issueToken(c *gin.Context) {
+	session, ok := currentSession(c)
+	if !ok {
+		app.respondReauth(c, "Требуется вход")
+		return
+	}
+
+	pair, err := app.issueTokenPair(session, "")
+	if err != nil {
+		app.errorLog.Printf("Ошибка выдачи токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выдать токен"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, pair)
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// refreshAccessToken обменивает refresh-токен на новую пару токенов. Использованный
+// refresh-токен больше не действует; его повторное предъявление отзывает всю цепочку.
+func (app *application) refreshAccessToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Необходимо указать refresh_token"})
+		return
+	}
+
+	pair, err := app.rotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) {
+			app.respondReauth(c, err.Error())
+			return
+		}
+		app.errorLog.Printf("Ошибка обновления токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить токен"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, pair)
+}
+
+// rotateRefreshToken проверяет refresh-токен и выдает новую пару в той же цепочке
+func (app *application) rotateRefreshToken(raw string) (*tokenPair, error) {
+	used, err := app.models.UseRefreshToken(hashSessionToken(raw))
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, errInvalidRefreshToken
+	}
+	if errors.Is(err, models.ErrTokenReused) {
+		// Токен предъявлен повторно — вероятно, он украден. Цепочка уже отозвана,
+		// сессию тоже завершаем.
+		app.errorLog.Printf("Повторное использование refresh-токена пользователя %d, сессия завершена", used.UserID)
+		if err := app.models.DeleteSession(used.SessionID); err != nil {
+			app.errorLog.Printf("%v", err)
+		}
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := app.models.GetSession(used.SessionID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return app.issueTokenPair(session, used.FamilyID)
+}
+
+// getJWKS отдает открытые ключи для проверки JWT плагина
+func (app *application) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, app.jwt.JWKS())
+}
+
+// publicAPIRoutes — маршруты /api, доступные без входа
+var publicAPIRoutes = map[string]bool{
+	"/api/gitlab/auth":     true, // начало входа через GitLab
+	"/api/auth/refresh":    true, // обмен refresh-токена, проверяется сам токен
+	"/api/webhooks/gitlab": true, // проверяется секрет вебхука
+
+	"/api/auth/local/login":    true, // вход по паролю, ограничен по числу попыток
+	"/api/auth/password/reset": true, // проверяется одноразовый токен сброса
+}
+
+// authenticate проверяет вход для маршрутов /api: JWT или персональный токен
+// в заголовке Authorization, или кука сессии. По JWT находится сессия с токеном
+// GitLab пользователя.
+func (app *application) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/") || publicAPIRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			if _, ok := currentSession(c); ok {
+				c.Next()
+				return
+			}
+			app.respondReauth(c, "Требуется вход")
+			c.Abort()
+			return
+		}
+
+		raw, found := strings.CutPrefix(header, "Bearer ")
+		if !found {
+			app.respondReauth(c, "Ожидается заголовок Authorization: Bearer <токен>")
+			c.Abort()
+			return
+		}
+
+		raw = strings.TrimSpace(raw)
+		if strings.HasPrefix(raw, personalTokenPrefix) {
+			if app.authenticatePersonalToken(c, raw) {
+				c.Next()
+			}
+			return
+		}
+
+		claims, err := app.jwt.Parse(raw)
+		if err != nil {
+			app.respondReauth(c, "Недействительный токен доступа")
+			c.Abort()
+			return
+		}
+
+		// Завершенная сессия делает недействительными и выданные по ней JWT
+		session, err := app.models.GetSession(claims.SessionID)
+		if err != nil {
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.errorLog.Printf("Ошибка получения сессии: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки токена"})
+				return
+			}
+			app.respondReauth(c, "Сессия завершена, войдите снова")
+			c.Abort()
+			return
+		}
+
+		c.Set(contextKeyClaims, claims)
+		c.Set(contextKeySession, session)
+		c.Set(contextKeyUser, newSessionUser(session))
+		c.Next()
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// testPEMKey создает PEM-ключ ECDSA P-256 в формате SEC 1
+func testPEMKey(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+func testJWTIssuer(t *testing.T, pemKey string) *jwtIssuer {
+	t.Helper()
+	issuer, _, err := newJWTIssuer(pemKey, "plaginagile", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+var testJWTSession = &models.Session{ID: "session-1", UserID: 42, Username: "ivanov"}
+
+func TestJWTSignParse(t *testing.T) {
+	issuer := testJWTIssuer(t, testPEMKey(t))
+
+	raw, expiresAt, err := issuer.Sign(testJWTSession, "developer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(expiresAt); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Errorf("срок действия через %s", until)
+	}
+
+	claims, err := issuer.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "42" || claims.Username != "ivanov" || claims.Role != "developer" || claims.SessionID != "session-1" {
+		t.Errorf("утверждения = %+v", claims)
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	pemKey := testPEMKey(t)
+	current := testJWTIssuer(t, pemKey)
+
+	raw, _, err := current.Sign(testJWTSession, "developer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("тот же ключ после перезапуска", func(t *testing.T) {
+		restarted := testJWTIssuer(t, pemKey)
+		if restarted.keyID != current.keyID {
+			t.Fatalf("kid изменился: %s != %s", restarted.keyID, current.keyID)
+		}
+		if _, err := restarted.Parse(raw); err != nil {
+			t.Errorf("токен не принят после перезапуска: %v", err)
+		}
+	})
+
+	t.Run("новый ключ не принимает старые токены", func(t *testing.T) {
+		rotated := testJWTIssuer(t, testPEMKey(t))
+		if rotated.keyID == current.keyID {
+			t.Fatal("kid не изменился при смене ключа")
+		}
+		if _, err := rotated.Parse(raw); err == nil {
+			t.Error("токен, подписанный прежним ключом, принят")
+		}
+	})
+
+	t.Run("подмена kid", func(t *testing.T) {
+		other := testJWTIssuer(t, testPEMKey(t))
+		other.keyID = current.keyID
+		forged, _, err := other.Sign(testJWTSession, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := current.Parse(forged); err == nil {
+			t.Error("токен с чужой подписью и известным kid принят")
+		}
+	})
+
+	t.Run("временный ключ", func(t *testing.T) {
+		first, generated, err := newJWTIssuer("", "plaginagile", time.Minute)
+		if err != nil || !generated {
+			t.Fatalf("generated = %t, err = %v", generated, err)
+		}
+		second, _, err := newJWTIssuer("", "plaginagile", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.keyID == second.keyID {
+			t.Error("временные ключи получили одинаковый kid")
+		}
+	})
+}
+
+func TestJWTParseRejects(t *testing.T) {
+	issuer := testJWTIssuer(t, testPEMKey(t))
+
+	sign := func(t *testing.T, modify func(*accessClaims), method jwt.SigningMethod, key interface{}) string {
+		t.Helper()
+		now := time.Now()
+		claims := accessClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "plaginagile",
+				Subject:   "42",
+				Audience:  jwt.ClaimStrings{jwtAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			SessionID: "session-1",
+		}
+		modify(&claims)
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = issuer.keyID
+		raw, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	es256 := func(modify func(*accessClaims)) func(t *testing.T) string {
+		return func(t *testing.T) string { return sign(t, modify, jwt.SigningMethodES256, issuer.key) }
+	}
+
+	if _, err := issuer.Parse(es256(func(*accessClaims) {})(t)); err != nil {
+		t.Fatalf("исходный токен не принят: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token func(t *testing.T) string
+	}{
+		{"другой издатель", es256(func(c *accessClaims) { c.Issuer = "other" })},
+		{"другой получатель", es256(func(c *accessClaims) { c.Audience = jwt.ClaimStrings{"gitlab"} })},
+		{"истекший токен", es256(func(c *accessClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) })},
+		{"без срока действия", es256(func(c *accessClaims) { c.ExpiresAt = nil })},
+		{"без сессии", es256(func(c *accessClaims) { c.SessionID = "" })},
+		{"алгоритм HS256", func(t *testing.T) string {
+			return sign(t, func(*accessClaims) {}, jwt.SigningMethodHS256, []byte("secret"))
+		}},
+		{"испорченная подпись", func(t *testing.T) string {
+			raw := es256(func(*accessClaims) {})(t)
+			return raw[:strings.LastIndex(raw, ".")+1] + "AAAA"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := issuer.Parse(tt.token(t)); err == nil {
+				t.Error("токен принят")
+			}
+		})
+	}
+}
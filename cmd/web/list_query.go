@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+)
+
+// listQuerySpec описывает параметры фильтрации, которые можно передать в список GitLab
+type listQuerySpec struct {
+	enums map[string][]string // параметры с фиксированным набором значений
+	ints  []string            // положительные целые числа
+	bools []string            // true/false
+	texts []string            // произвольные строки
+	// idsOrKeywords — параметры, принимающие число или одно из ключевых слов (например, None/Any)
+	idsOrKeywords map[string][]string
+}
+
+var sortValues = []string{"asc", "desc"}
+
+// projectListQuery — параметры списка проектов
+var projectListQuery = listQuerySpec{
+	enums: map[string][]string{
+		"order_by":   {"id", "name", "path", "created_at", "updated_at", "last_activity_at"},
+		"sort":       sortValues,
+		"visibility": {"public", "internal", "private"},
+	},
+	bools: []string{"archived", "membership", "owned", "starred", "simple"},
+	texts: []string{"search"},
+}
+
+// issueListQuery — параметры списка задач проекта
+var issueListQuery = listQuerySpec{
+	enums: map[string][]string{
+		"state":    {"opened", "closed", "all"},
+		"order_by": {"created_at", "updated_at", "priority", "due_date", "relative_position", "label_priority", "milestone_due", "popularity", "weight", "title"},
+		"sort":     sortValues,
+		"scope":    {"created_by_me", "assigned_to_me", "all"},
+		"in":       {"title", "description", "title,description"},
+	},
+	ints:  []string{"author_id"},
+	bools: []string{"confidential"},
+	texts: []string{"search", "labels", "milestone", "assignee_username", "created_after", "created_before", "updated_after", "updated_before"},
+	idsOrKeywords: map[string][]string{
+		"assignee_id": {"None", "Any"},
+	},
+}
+
+// userListQuery — параметры списка пользователей
+var userListQuery = listQuerySpec{
+	enums: map[string][]string{
+		"order_by": {"id", "name", "username", "created_at", "updated_at"},
+		"sort":     sortValues,
+	},
+	bools: []string{"active", "blocked", "external", "exclude_internal"},
+	texts: []string{"search", "username"},
+}
+
+// defaultListPerPage — размер страницы, если per_page не передан
+const defaultListPerPage = gitlab.MaxPerPage
+
+// parseListQuery проверяет параметры запроса и формирует параметры для GitLab.
+// Список всегда читается постранично: без page/per_page отдается первая страница
+// из defaultListPerPage элементов.
+func parseListQuery(c *gin.Context, spec listQuerySpec) (url.Values, error) {
+	query := url.Values{"page": {"1"}, "per_page": {strconv.Itoa(defaultListPerPage)}}
+
+	for _, name := range []string{"page", "per_page"} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("параметр %s должен быть положительным числом", name)
+		}
+		if name == "per_page" && value > gitlab.MaxPerPage {
+			return nil, fmt.Errorf("параметр per_page не может быть больше %d", gitlab.MaxPerPage)
+		}
+		query.Set(name, strconv.Itoa(value))
+	}
+
+	for name, allowed := range spec.enums {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		if !containsString(allowed, raw) {
+			return nil, fmt.Errorf("недопустимое значение %s: %s (допустимо: %s)", name, raw, strings.Join(allowed, ", "))
+		}
+		query.Set(name, raw)
+	}
+
+	for _, name := range spec.ints {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		if value, err := strconv.Atoi(raw); err != nil || value < 1 {
+			return nil, fmt.Errorf("параметр %s должен быть положительным числом", name)
+		}
+		query.Set(name, raw)
+	}
+
+	for _, name := range spec.bools {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("параметр %s должен быть true или false", name)
+		}
+		query.Set(name, strconv.FormatBool(value))
+	}
+
+	for _, name := range spec.texts {
+		if raw := strings.TrimSpace(c.Query(name)); raw != "" {
+			query.Set(name, raw)
+		}
+	}
+
+	for name, keywords := range spec.idsOrKeywords {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		if value, err := strconv.Atoi(raw); (err != nil || value < 1) && !containsString(keywords, raw) {
+			return nil, fmt.Errorf("параметр %s должен быть ID или одним из: %s", name, strings.Join(keywords, ", "))
+		}
+		query.Set(name, raw)
+	}
+
+	return query, nil
+}
+
+// listGitLab получает из GitLab страницу списка. Метаданные пагинации передаются
+// в заголовках X-Page, X-Per-Page, X-Next-Page, X-Prev-Page, X-Total и X-Total-Pages
+// и возвращаются для тела ответа (см. listResponse).
+func listGitLab[T any](app *application, c *gin.Context, client *gitlab.Client, path string, query url.Values) ([]T, *gitlab.Response, bool) {
+	items, resp, err := gitlab.ListPage[T](c.Request.Context(), client, path, query)
+	if err != nil {
+		app.respondGitLabError(c, err)
+		return nil, nil, false
+	}
+	if items == nil {
+		items = []T{}
+	}
+	setPaginationHeaders(c, resp)
+	return items, resp, true
+}
+
+// listResponse — тело ответа списка: элементы страницы и метаданные пагинации
+func listResponse(items interface{}, resp *gitlab.Response) gin.H {
+	return gin.H{
+		"items":      items,
+		"pagination": resp,
+	}
+}
+
+// setPaginationHeaders передает клиенту метаданные пагинации. Заголовки, которых
+// нет в ответе GitLab (например, X-Total у больших списков), не передаются.
+func setPaginationHeaders(c *gin.Context, resp *gitlab.Response) {
+	headers := map[string]int{
+		"X-Page":        resp.Page,
+		"X-Per-Page":    resp.PerPage,
+		"X-Next-Page":   resp.NextPage,
+		"X-Prev-Page":   resp.PrevPage,
+		"X-Total":       resp.Total,
+		"X-Total-Pages": resp.TotalPages,
+	}
+	for name, value := range headers {
+		if value > 0 {
+			c.Header(name, strconv.Itoa(value))
+		}
+	}
+}
+
+// respondInvalidQuery отправляет ошибку валидации параметров списка
+func respondInvalidQuery(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
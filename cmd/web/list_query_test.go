@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+)
+
+func testListContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/issues?"+rawQuery, nil)
+	return c
+}
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    url.Values
+		wantErr bool
+	}{
+		{
+			name:  "по умолчанию первая страница",
+			query: "",
+			want:  url.Values{"page": {"1"}, "per_page": {"100"}},
+		},
+		{
+			name:  "страница и размер",
+			query: "page=3&per_page=20",
+			want:  url.Values{"page": {"3"}, "per_page": {"20"}},
+		},
+		{
+			name:  "максимальный размер страницы",
+			query: "per_page=100",
+			want:  url.Values{"page": {"1"}, "per_page": {"100"}},
+		},
+		{name: "нулевая страница", query: "page=0", wantErr: true},
+		{name: "отрицательная страница", query: "page=-1", wantErr: true},
+		{name: "страница не число", query: "page=abc", wantErr: true},
+		{name: "нулевой размер страницы", query: "per_page=0", wantErr: true},
+		{name: "размер страницы больше лимита", query: "per_page=101", wantErr: true},
+		{
+			name:  "фильтры задач",
+			query: "state=opened&confidential=1&author_id=5&search=+форма+&assignee_id=None",
+			want: url.Values{
+				"page": {"1"}, "per_page": {"100"},
+				"state": {"opened"}, "confidential": {"true"}, "author_id": {"5"},
+				"search": {"форма"}, "assignee_id": {"None"},
+			},
+		},
+		{
+			name:  "исполнитель по ID",
+			query: "assignee_id=7",
+			want:  url.Values{"page": {"1"}, "per_page": {"100"}, "assignee_id": {"7"}},
+		},
+		{name: "недопустимый state", query: "state=merged", wantErr: true},
+		{name: "недопустимый bool", query: "confidential=yes", wantErr: true},
+		{name: "author_id не число", query: "author_id=me", wantErr: true},
+		{name: "недопустимое ключевое слово", query: "assignee_id=Nobody", wantErr: true},
+		{
+			name:  "неизвестный параметр не передается",
+			query: "private_token=secret",
+			want:  url.Values{"page": {"1"}, "per_page": {"100"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListQuery(testListContext(tt.query), issueListQuery)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Encode() != tt.want.Encode() {
+				t.Errorf("parseListQuery(%q) = %s, ожидалось %s", tt.query, got.Encode(), tt.want.Encode())
+			}
+		})
+	}
+}
+
+func TestSetPaginationHeaders(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	setPaginationHeaders(c, &gitlab.Response{Page: 2, PerPage: 20, PrevPage: 1})
+
+	want := map[string]string{
+		"X-Page":        "2",
+		"X-Per-Page":    "20",
+		"X-Prev-Page":   "1",
+		"X-Next-Page":   "",
+		"X-Total":       "",
+		"X-Total-Pages": "",
+	}
+	for name, value := range want {
+		if got := recorder.Header().Get(name); got != value {
+			t.Errorf("%s = %q, ожидалось %q", name, got, value)
+		}
+	}
+}
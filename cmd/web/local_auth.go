@@ -0,0 +1,532 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// Параметры паролей локальных пользователей
+const (
+	bcryptCost        = 12
+	passwordMinLength = 10
+	passwordMaxBytes  = 72 // bcrypt учитывает только первые 72 байта
+)
+
+// Защита от подбора пароля: после loginMaxFailures неудачных попыток подряд
+// вход в учетную запись блокируется на loginLockDuration; с одного адреса
+// допускается не больше loginIPLimit попыток за loginIPWindow.
+const (
+	loginMaxFailures  = 5
+	loginLockDuration = 15 * time.Minute
+	loginIPLimit      = 20
+	loginIPWindow     = 15 * time.Minute
+)
+
+// passwordResetTTL — срок действия токена сброса пароля
+const passwordResetTTL = 24 * time.Hour
+
+var (
+	errInvalidCredentials = errors.New("неверный логин или пароль")
+	errLoginLocked        = errors.New("вход временно заблокирован после нескольких неудачных попыток")
+)
+
+// dummyPasswordHash сравнивается с паролем неизвестного пользователя, чтобы
+// по времени ответа нельзя было узнать, существует ли логин
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("plaginagile-dummy-password"), bcryptCost)
+	return hash
+})
+
+// loginLimiter ограничивает число попыток входа с одного адреса в окне времени
+type loginLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]*loginWindow
+}
+
+type loginWindow struct {
+	start time.Time
+	count int
+}
+
+func newLoginLimiter(limit int, window time.Duration) *loginLimiter {
+	return &loginLimiter{limit: limit, window: window, entries: map[string]*loginWindow{}}
+}
+
+// Allow засчитывает попытку с адреса key. Если попыток слишком много, возвращает
+// false и время, через которое можно повторить.
+func (l *loginLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.start) >= l.window {
+		l.entries[key] = &loginWindow{start: now, count: 1}
+		return true, 0
+	}
+	if entry.count >= l.limit {
+		return false, entry.start.Add(l.window).Sub(now)
+	}
+	entry.count++
+	return true, 0
+}
+
+// Cleanup удаляет окна, которые уже закончились
+func (l *loginLimiter) Cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, entry := range l.entries {
+		if now.Sub(entry.start) >= l.window {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// hashPassword возвращает хеш bcrypt пароля
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// validatePassword проверяет требования к новому паролю
+func validatePassword(password string) error {
+	if len([]rune(password)) < passwordMinLength {
+		return fmt.Errorf("пароль должен быть не короче %d символов", passwordMinLength)
+	}
+	if len(password) > passwordMaxBytes {
+		return fmt.Errorf("пароль должен быть не длиннее %d байт", passwordMaxBytes)
+	}
+	if strings.TrimSpace(password) == "" {
+		return errors.New("пароль не может состоять из пробелов")
+	}
+	return nil
+}
+
+// localUserName собирает ФИО пользователя таблицы users
+func localUserName(user *models.User) string {
+	return strings.Join(strings.Fields(user.UsrSurname+" "+user.UsrName+" "+user.UsrPatronomic), " ")
+}
+
+// hashPlaintextPasswords заменяет хешами bcrypt пароли, которые хранятся
+// в таблице users в открытом виде
+func (app *application) hashPlaintextPasswords() error {
+	passwords, err := app.models.GetPlaintextPasswords()
+	if err != nil {
+		return err
+	}
+	for userID, password := range passwords {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return fmt.Errorf("пользователь %d: %w", userID, err)
+		}
+		if err := app.models.ReplacePlaintextPassword(userID, password, hash); err != nil {
+			return err
+		}
+	}
+	if len(passwords) > 0 {
+		app.infoLog.Printf("Открытые пароли пользователей заменены хешами: %d", len(passwords))
+	}
+	return nil
+}
+
+// checkLocalCredentials проверяет логин и пароль с учетом блокировки учетной записи.
+// Возвращает errInvalidCredentials или errLoginLocked (вместе со временем окончания
+// блокировки), если войти нельзя.
+func (app *application) checkLocalCredentials(username, password string) (*models.User, *time.Time, error) {
+	user, err := app.models.GetLocalUser(username)
+	if errors.Is(err, models.ErrNoRecord) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Во время блокировки пароль не проверяется, чтобы подбор не продолжался
+	if user.UsrLockedUntil != nil && user.UsrLockedUntil.After(time.Now()) {
+		return nil, user.UsrLockedUntil, errLoginLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.UsrPassword), []byte(password)); err != nil {
+		lockedUntil, err := app.models.RecordLoginFailure(user.UsrID, loginMaxFailures, loginLockDuration)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lockedUntil != nil {
+			app.infoLog.Printf("Вход пользователя %s заблокирован до %s после неудачных попыток", user.UsrUsername, lockedUntil.Format(time.RFC3339))
+			return nil, lockedUntil, errLoginLocked
+		}
+		return nil, nil, errInvalidCredentials
+	}
+
+	if user.UsrFailedLogins > 0 || user.UsrLockedUntil != nil {
+		if err := app.models.ResetLoginFailures(user.UsrID); err != nil {
+			app.errorLog.Printf("%v", err)
+		}
+	}
+	// Хеш с устаревшей стоимостью пересчитываем, пока пароль известен
+	if cost, err := bcrypt.Cost([]byte(user.UsrPassword)); err == nil && cost < bcryptCost {
+		if hash, err := hashPassword(password); err == nil {
+			if err := app.models.SetUserPassword(user.UsrID, hash); err != nil {
+				app.errorLog.Printf("%v", err)
+			}
+		}
+	}
+	return user, nil, nil
+}
+
+// respondCredentialsError отвечает на неудачную проверку логина и пароля
+func (app *application) respondCredentialsError(c *gin.Context, err error, lockedUntil *time.Time) {
+	switch {
+	case errors.Is(err, errLoginLocked):
+		if lockedUntil != nil {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(*lockedUntil).Seconds())+1))
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		app.errorLog.Printf("Ошибка проверки пароля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить пароль"})
+	}
+}
+
+// allowLoginAttempt ограничивает попытки входа и сброса пароля с одного адреса.
+// Адрес берется из X-Forwarded-For только за прокси из trusted-proxies.
+func (app *application) allowLoginAttempt(c *gin.Context) bool {
+	allowed, retryAfter := app.loginLimiter.Allow(c.ClientIP(), time.Now())
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много попыток входа, повторите позже"})
+		return false
+	}
+	return true
+}
+
+// requireLocalLogin отвечает 404, если локальный вход выключен в настройках
+func (app *application) requireLocalLogin(c *gin.Context) bool {
+	if !app.localLogin {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вход по паролю отключен"})
+		return false
+	}
+	return true
+}
+
+// startLocalSession создает сессию для пользователя таблицы users. Если он
+// связан с пользователем GitLab, сессия получает его ID и роли.
+func (app *application) startLocalSession(c *gin.Context, user *models.User) (*models.Session, error) {
+	cookieValue, id, err := newSessionToken()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать идентификатор сессии: %w", err)
+	}
+
+	localID := user.UsrID
+	session := &models.Session{
+		ID:          id,
+		Username:    user.UsrUsername,
+		Name:        localUserName(user),
+		Email:       user.UsrEmail,
+		LocalUserID: &localID,
+		UserAgent:   c.Request.UserAgent(),
+		IP:          c.ClientIP(),
+		ExpiresAt:   time.Now().Add(app.sessionTTL),
+	}
+	if user.UsrGitLabID != nil {
+		session.UserID = *user.UsrGitLabID
+	}
+	if err := app.models.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	app.setCookie(c, sessionCookieName, cookieValue, int(app.sessionTTL.Seconds()))
+	return session, nil
+}
+
+// sessionLocalUser возвращает пользователя таблицы users текущей сессии: вошедшего
+// по паролю или связанного с вошедшим через GitLab
+func (app *application) sessionLocalUser(session *models.Session) (*models.User, error) {
+	if session.LocalUserID != nil {
+		return app.models.GetLocalUserByID(*session.LocalUserID)
+	}
+	return app.models.GetLocalUserByGitLabID(session.UserID)
+}
+
+// LocalLoginRequest — тело запроса входа по паролю и связи с GitLab
+type LocalLoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// loginLocal выполняет вход по логину и паролю из таблицы users
+func (app *application) loginLocal(c *gin.Context) {
+	if !app.requireLocalLogin(c) || !app.allowLoginAttempt(c) {
+		return
+	}
+
+	var req LocalLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите логин и пароль"})
+		return
+	}
+
+	user, lockedUntil, err := app.checkLocalCredentials(req.Username, req.Password)
+	if err != nil {
+		app.respondCredentialsError(c, err, lockedUntil)
+		return
+	}
+
+	session, err := app.startLocalSession(c, user)
+	if err != nil {
+		app.errorLog.Printf("Ошибка создания сессии: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать сессию"})
+		return
+	}
+
+	app.infoLog.Printf("Пользователь %s вошел по паролю", user.UsrUsername)
+	c.JSON(http.StatusOK, gin.H{
+		"user":       newSessionUser(session),
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// ChangePasswordRequest — тело запроса смены пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// changePassword меняет пароль пользователя текущей сессии. Остальные его сессии
+// локального входа завершаются.
+func (app *application) changePassword(c *gin.Context) {
+	session, ok := app.requireInteractiveSession(c)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите текущий и новый пароль"})
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	local, err := app.sessionLocalUser(session)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Учетная запись не связана с локальным пользователем"})
+		return
+	}
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить пользователя")
+		return
+	}
+
+	user, lockedUntil, err := app.checkLocalCredentials(local.UsrUsername, req.CurrentPassword)
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Неверный текущий пароль"})
+			return
+		}
+		app.respondCredentialsError(c, err, lockedUntil)
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		app.respondError(c, err, "Не удалось сменить пароль")
+		return
+	}
+	if err := app.models.SetUserPassword(user.UsrID, hash); err != nil {
+		app.respondError(c, err, "Не удалось сменить пароль")
+		return
+	}
+	if _, err := app.models.DeleteLocalUserSessions(user.UsrID, session.ID); err != nil {
+		app.errorLog.Printf("%v", err)
+	}
+
+	app.infoLog.Printf("Пользователь %s сменил пароль", user.UsrUsername)
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен"})
+}
+
+// createPasswordReset выдает одноразовый токен сброса пароля пользователя
+// таблицы users (администратор). Токен передается пользователю вне системы.
+func (app *application) createPasswordReset(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID пользователя"})
+		return
+	}
+
+	user, err := app.models.GetLocalUserByID(userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить пользователя")
+		return
+	}
+
+	token, id, err := newSessionToken()
+	if err != nil {
+		app.respondError(c, err, "Не удалось создать токен сброса пароля")
+		return
+	}
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := app.models.CreatePasswordReset(id, user.UsrID, expiresAt); err != nil {
+		app.respondError(c, err, "Не удалось создать токен сброса пароля")
+		return
+	}
+
+	if admin, ok := currentUser(c); ok {
+		app.infoLog.Printf("Администратор %s выдал сброс пароля пользователю %s", admin.Username, user.UsrUsername)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"reset_token": token,
+		"expires_at":  expiresAt,
+	})
+}
+
+// ResetPasswordRequest — тело запроса установки пароля по токену сброса
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// resetPassword устанавливает новый пароль по одноразовому токену сброса.
+// Все сессии локального входа пользователя завершаются.
+func (app *application) resetPassword(c *gin.Context) {
+	if !app.requireLocalLogin(c) || !app.allowLoginAttempt(c) {
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите токен сброса и новый пароль"})
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := app.models.ConsumePasswordReset(hashSessionToken(req.Token))
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Токен сброса пароля недействителен или истек"})
+		return
+	}
+	if err != nil {
+		app.respondError(c, err, "Не удалось сбросить пароль")
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		app.respondError(c, err, "Не удалось сбросить пароль")
+		return
+	}
+	if err := app.models.SetUserPassword(userID, hash); err != nil {
+		app.respondError(c, err, "Не удалось сбросить пароль")
+		return
+	}
+	if _, err := app.models.DeleteLocalUserSessions(userID, ""); err != nil {
+		app.errorLog.Printf("%v", err)
+	}
+
+	app.infoLog.Printf("Пароль пользователя %d сброшен по токену", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен, войдите с новым паролем"})
+}
+
+// linkLocalUser связывает пользователя, вошедшего через GitLab, с учетной записью
+// таблицы users. Владение учетной записью подтверждается ее паролем.
+func (app *application) linkLocalUser(c *gin.Context) {
+	session, ok := app.requireInteractiveSession(c)
+	if !ok {
+		return
+	}
+	if session.LocalUserID != nil || session.UserID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Связь создается после входа через GitLab"})
+		return
+	}
+	if !app.allowLoginAttempt(c) {
+		return
+	}
+
+	var req LocalLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите логин и пароль"})
+		return
+	}
+
+	user, lockedUntil, err := app.checkLocalCredentials(req.Username, req.Password)
+	if err != nil {
+		app.respondCredentialsError(c, err, lockedUntil)
+		return
+	}
+	if user.UsrGitLabID != nil && *user.UsrGitLabID != session.UserID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Учетная запись уже связана с другим пользователем GitLab"})
+		return
+	}
+
+	gitlabID := session.UserID
+	if err := app.models.LinkUserGitLab(user.UsrID, &gitlabID); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Пользователь GitLab уже связан с другой учетной записью"})
+			return
+		}
+		app.respondError(c, err, "Не удалось связать учетные записи")
+		return
+	}
+
+	app.infoLog.Printf("Пользователь GitLab %s связан с учетной записью %s", session.Username, user.UsrUsername)
+	c.JSON(http.StatusOK, gin.H{"message": "Учетные записи связаны", "local_id": user.UsrID})
+}
+
+// unlinkLocalUser снимает связь пользователя GitLab с учетной записью таблицы users
+func (app *application) unlinkLocalUser(c *gin.Context) {
+	session, ok := app.requireInteractiveSession(c)
+	if !ok {
+		return
+	}
+	if session.LocalUserID != nil || session.UserID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Связь снимается после входа через GitLab"})
+		return
+	}
+
+	user, err := app.models.GetLocalUserByGitLabID(session.UserID)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Учетная запись не связана с локальным пользователем"})
+		return
+	}
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить пользователя")
+		return
+	}
+
+	if err := app.models.LinkUserGitLab(user.UsrID, nil); err != nil {
+		app.respondError(c, err, "Не удалось снять связь учетных записей")
+		return
+	}
+	// Сессии локального входа действовали от имени пользователя GitLab
+	if _, err := app.models.DeleteLocalUserSessions(user.UsrID, ""); err != nil {
+		app.errorLog.Printf("%v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Связь учетных записей снята"})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	start := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newLoginLimiter(3, 15*time.Minute)
+
+	for i := range 3 {
+		if ok, _ := limiter.Allow("10.0.0.1", start.Add(time.Duration(i)*time.Minute)); !ok {
+			t.Fatalf("попытка %d отклонена до лимита", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.Allow("10.0.0.1", start.Add(5*time.Minute))
+	if ok {
+		t.Fatal("попытка сверх лимита разрешена")
+	}
+	if retryAfter != 10*time.Minute {
+		t.Errorf("retryAfter = %s, ожидалось 10m", retryAfter)
+	}
+
+	if ok, _ := limiter.Allow("10.0.0.2", start.Add(5*time.Minute)); !ok {
+		t.Error("лимит одного адреса заблокировал другой")
+	}
+
+	if ok, _ := limiter.Allow("10.0.0.1", start.Add(15*time.Minute)); !ok {
+		t.Error("попытка после окончания окна отклонена")
+	}
+
+	limiter.Cleanup(start.Add(25 * time.Minute))
+	if _, ok := limiter.entries["10.0.0.1"]; !ok {
+		t.Error("Cleanup удалил действующее окно")
+	}
+	if _, ok := limiter.entries["10.0.0.2"]; ok {
+		t.Error("Cleanup не удалил закончившееся окно")
+	}
+}
+
+func TestAllowLoginAttemptTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		// Адреса X-Forwarded-For по попыткам: подмена адреса не должна обходить лимит
+		forwarded []string
+		want      []int
+	}{
+		{
+			name:      "без доверенных прокси X-Forwarded-For игнорируется",
+			remote:    "203.0.113.5:4000",
+			forwarded: []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+			want:      []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:      "запрос не от доверенного прокси",
+			proxies:   []string{"10.0.0.0/8"},
+			remote:    "203.0.113.5:4000",
+			forwarded: []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+			want:      []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:      "адрес клиента от доверенного прокси",
+			proxies:   []string{"10.0.0.0/8"},
+			remote:    "10.1.2.3:4000",
+			forwarded: []string{"198.51.100.1", "198.51.100.2", "198.51.100.1", "198.51.100.1"},
+			want:      []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				errorLog:     log.New(&strings.Builder{}, "", 0),
+				infoLog:      log.New(&strings.Builder{}, "", 0),
+				loginLimiter: newLoginLimiter(2, time.Minute),
+			}
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			router.POST("/login", func(c *gin.Context) {
+				if app.allowLoginAttempt(c) {
+					c.Status(http.StatusOK)
+				}
+			})
+
+			for i, forwarded := range tt.forwarded {
+				req := httptest.NewRequest(http.MethodPost, "/login", nil)
+				req.RemoteAddr = tt.remote
+				req.Header.Set("X-Forwarded-For", forwarded)
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				if recorder.Code != tt.want[i] {
+					t.Fatalf("попытка %d с %s: статус %d, ожидалось %d", i+1, forwarded, recorder.Code, tt.want[i])
+				}
+				if recorder.Code == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") == "" {
+					t.Error("нет заголовка Retry-After")
+				}
+			}
+		})
+	}
+}
+
+func TestRespondCredentialsError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &application{errorLog: log.New(&strings.Builder{}, "", 0)}
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name        string
+		err         error
+		lockedUntil *time.Time
+		status      int
+		retryAfter  bool
+	}{
+		{"неверный пароль", errInvalidCredentials, nil, http.StatusUnauthorized, false},
+		{"блокировка после неудачных попыток", errLoginLocked, &lockedUntil, http.StatusTooManyRequests, true},
+		{"обернутая блокировка без срока", fmt.Errorf("вход: %w", errLoginLocked), nil, http.StatusTooManyRequests, false},
+		{"внутренняя ошибка", bcrypt.ErrHashTooShort, nil, http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			app.respondCredentialsError(c, tt.err, tt.lockedUntil)
+
+			if recorder.Code != tt.status {
+				t.Errorf("статус %d, ожидалось %d", recorder.Code, tt.status)
+			}
+			if got := recorder.Header().Get("Retry-After") != ""; got != tt.retryAfter {
+				t.Errorf("Retry-After = %q", recorder.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"подходящий пароль", "correct-horse", false},
+		{"короткий пароль", "short", true},
+		{"десять символов кириллицей", "пароль1234", false},
+		{"только пробелы", strings.Repeat(" ", 12), true},
+		{"длиннее 72 байт", strings.Repeat("я", 37), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePassword(tt.password); (err != nil) != tt.wantErr {
+				t.Errorf("validatePassword() = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// gitlabRevokeTimeout ограничивает ожидание GitLab при отзыве токенов, чтобы
+// выход не зависал, когда GitLab недоступен
+const gitlabRevokeTimeout = 10 * time.Second
+
+// legacyTokenCookie — кука, в которой раньше хранился токен GitLab. Очищается
+// при выходе у клиентов, оставшихся со старых версий.
+const legacyTokenCookie = "gitlab_token"
+
+// revokeGitLabTokens отзывает в GitLab токены сессии. Ошибки не мешают выходу:
+// сессия удаляется в любом случае, а токен GitLab истечет сам.
+func (app *application) revokeGitLabTokens(ctx context.Context, session *models.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, gitlabRevokeTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, encrypted := range [][]byte{session.AccessTokenEncrypted, session.RefreshTokenEncrypted} {
+		token, err := app.decryptSessionToken(encrypted)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if token == "" {
+			continue
+		}
+
+		form := url.Values{}
+		form.Set("client_id", app.oauthHandler.clientID)
+		form.Set("client_secret", app.oauthHandler.clientSecret)
+		form.Set("token", token)
+		if err := app.gitlab.RevokeToken(ctx, form); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clearAuthCookies удаляет куки входа
+func (app *application) clearAuthCookies(c *gin.Context) {
+	app.setCookie(c, sessionCookieName, "", -1)
+	app.setCookie(c, legacyTokenCookie, "", -1)
+}
+
+// logoutUser завершает все сессии пользователя (с их JWT и refresh-токенами),
+// отзывает его токены GitLab и персональные токены плагина
+func (app *application) logoutUser(ctx context.Context, userID int) (sessions, tokens int64, err error) {
+	userSessions, err := app.models.GetUserSessions(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range userSessions {
+		if err := app.revokeGitLabTokens(ctx, &userSessions[i]); err != nil {
+			app.errorLog.Printf("Не удалось отозвать токены GitLab пользователя %s: %v", userSessions[i].Username, err)
+		}
+	}
+
+	if sessions, err = app.models.DeleteUserSessions(userID); err != nil {
+		return 0, 0, err
+	}
+	if tokens, err = app.models.RevokeUserPersonalTokens(userID); err != nil {
+		return sessions, 0, err
+	}
+	return sessions, tokens, nil
+}
+
+// logout завершает текущую сессию: отзывает токен GitLab, удаляет сессию вместе
+// с выданными по ней JWT и refresh-токенами и очищает куки. С параметром all=true
+// завершаются все сессии пользователя и отзываются его персональные токены.
+func (app *application) logout(c *gin.Context) {
+	if _, ok := currentPersonalToken(c); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Выход по персональному токену невозможен, отзовите токен"})
+		return
+	}
+
+	session, ok := currentSession(c)
+	if !ok {
+		// Уже не вошел: просто убираем куки
+		app.clearAuthCookies(c)
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+		return
+	}
+
+	if c.Query("all") == "true" {
+		var sessions, tokens int64
+		var err error
+		if session.UserID > 0 {
+			sessions, tokens, err = app.logoutUser(c.Request.Context(), session.UserID)
+		} else if session.LocalUserID != nil {
+			// Локальный пользователь без GitLab: ни токенов GitLab, ни персональных токенов у него нет
+			sessions, err = app.models.DeleteLocalUserSessions(*session.LocalUserID, "")
+		}
+		if err != nil {
+			app.errorLog.Printf("Ошибка выхода пользователя %s: %v", session.Username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось завершить сессии"})
+			return
+		}
+		app.infoLog.Printf("Пользователь %s вышел на всех устройствах", session.Username)
+		app.clearAuthCookies(c)
+		c.JSON(http.StatusOK, gin.H{
+			"status":                  "success",
+			"sessions_ended":          sessions,
+			"personal_tokens_revoked": tokens,
+		})
+		return
+	}
+
+	gitlabRevoked := true
+	if err := app.revokeGitLabTokens(c.Request.Context(), session); err != nil {
+		app.errorLog.Printf("Не удалось отозвать токены GitLab пользователя %s: %v", session.Username, err)
+		gitlabRevoked = false
+	}
+	if err := app.models.DeleteSession(session.ID); err != nil {
+		app.errorLog.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось завершить сессию"})
+		return
+	}
+
+	app.clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "gitlab_revoked": gitlabRevoked})
+}
+
+// forceLogoutUser завершает все сессии пользователя и отзывает его токены
+// (администратор)
+func (app *application) forceLogoutUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID пользователя"})
+		return
+	}
+
+	sessions, tokens, err := app.logoutUser(c.Request.Context(), userID)
+	if err != nil {
+		app.respondError(c, err, "Не удалось завершить сессии пользователя")
+		return
+	}
+
+	if admin, ok := currentUser(c); ok {
+		app.infoLog.Printf("Администратор %s завершил сессии пользователя %d", admin.Username, userID)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":                  "success",
+		"sessions_ended":          sessions,
+		"personal_tokens_revoked": tokens,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthStateTTL — сколько ждем возврата пользователя из GitLab после начала входа
+const oauthStateTTL = 10 * time.Minute
+
+// oauthStateCookie привязывает начатый вход к браузеру, в котором он начат
+const oauthStateCookie = "oauth_state"
+
+// Коды ошибок входа, которые получает SPA в параметре auth_error
+const (
+	authErrorDenied        = "access_denied"
+	authErrorInvalidState  = "invalid_state"
+	authErrorMissingCode   = "missing_code"
+	authErrorTokenExchange = "token_exchange_failed"
+	authErrorUser          = "user_failed"
+	authErrorSession       = "session_failed"
+)
+
+var errRedirectNotAllowed = errors.New("адрес возврата не входит в список разрешенных")
+
+// randomURLToken возвращает size случайных байт в base64url без дополнения
+func randomURLToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// pkceChallenge вычисляет code_challenge по методу S256 (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// resolveRedirectTarget проверяет адрес возврата после входа. Относительный путь
+// отсчитывается от адреса фронтенда, абсолютный адрес должен принадлежать одному
+// из разрешенных источников. Пустой адрес — главная страница фронтенда.
+func (app *application) resolveRedirectTarget(raw string) (string, error) {
+	if raw == "" {
+		return app.frontendURL + "/", nil
+	}
+
+	// "//host" и "/\host" браузеры понимают как адрес другого сайта
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") && !strings.HasPrefix(raw, "/\\") {
+		target, err := url.Parse(app.frontendURL + raw)
+		if err != nil {
+			return "", errRedirectNotAllowed
+		}
+		return target.String(), nil
+	}
+
+	target, err := url.Parse(raw)
+	if err != nil || target.Host == "" || (target.Scheme != "http" && target.Scheme != "https") {
+		return "", errRedirectNotAllowed
+	}
+	if !containsString(app.redirectAllowlist, target.Scheme+"://"+target.Host) {
+		return "", errRedirectNotAllowed
+	}
+	return target.String(), nil
+}
+
+// redirectAuthError возвращает пользователя в SPA с кодом ошибки входа
+func (app *application) redirectAuthError(c *gin.Context, target, code string) {
+	if target == "" {
+		target = app.frontendURL + "/"
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		u, _ = url.Parse(app.frontendURL + "/")
+	}
+	query := u.Query()
+	query.Set("auth_error", code)
+	u.RawQuery = query.Encode()
+	c.Redirect(http.StatusSeeOther, u.String())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// Пример из RFC 7636, приложение B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got := pkceChallenge(verifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("pkceChallenge() = %s", got)
+	}
+}
+
+func TestRandomURLToken(t *testing.T) {
+	first, err := randomURLToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := randomURLToken(32)
+	// 32 байта в base64url без дополнения — 43 символа, как требует RFC 7636 для verifier
+	if len(first) != 43 || first == second {
+		t.Errorf("randomURLToken(32) = %q, %q", first, second)
+	}
+}
+
+func TestResolveRedirectTarget(t *testing.T) {
+	app := &application{
+		frontendURL:       "https://app.example.com",
+		redirectAllowlist: []string{"https://app.example.com", "http://localhost:8080"},
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"пустой адрес", "", "https://app.example.com/"},
+		{"относительный путь", "/sprints/5?tab=board#issue-3", "https://app.example.com/sprints/5?tab=board#issue-3"},
+		{"разрешенный источник", "http://localhost:8080/projects", "http://localhost:8080/projects"},
+		{"адрес фронтенда", "https://app.example.com/", "https://app.example.com/"},
+		{"протокол-относительный адрес", "//evil.example.com/path", ""},
+		{"обратная косая черта", "/\\evil.example.com", ""},
+		{"источник не из списка", "https://evil.example.com/", ""},
+		{"поддомен разрешенного источника", "https://app.example.com.evil.example.com/", ""},
+		{"другой порт", "http://localhost:9090/", ""},
+		{"другая схема того же хоста", "http://app.example.com/", ""},
+		{"javascript", "javascript:alert(1)", ""},
+		{"data", "data:text/html,<script>alert(1)</script>", ""},
+		{"путь без слеша", "evil.example.com", ""},
+		{"учетные данные в адресе", "https://app.example.com@evil.example.com/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.resolveRedirectTarget(tt.raw)
+			if tt.want == "" {
+				if !errors.Is(err, errRedirectNotAllowed) {
+					t.Errorf("resolveRedirectTarget(%q) = %q, %v, ожидался отказ", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveRedirectTarget(%q) = %q, %v, ожидалось %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// Роли пользователей плагина (user_settings.us_role)
+const (
+	roleAdministrator  = "administrator"
+	roleProjectManager = "project_manager"
+	roleDeveloper      = "developer"
+)
+
+// contextKeyRole — ключ контекста gin для роли пользователя, определенной в запросе
+const contextKeyRole = "role"
+
+// roleResolver определяет роль пользователя запроса: глобальную или в проекте
+type roleResolver func(c *gin.Context) (string, error)
+
+// permission — действие, доступ к которому зависит от роли
+type permission string
+
+const (
+	permManageSprints   permission = "manage_sprints"   // создание, изменение, завершение спринтов и их состава
+	permMoveIssues      permission = "move_issues"      // создание задач; статус, исполнитель, ветки и мердж-реквесты задач спринта
+	permManageProjects  permission = "manage_projects"  // создание, изменение и подключение проектов, вебхуки
+	permDeleteProjects  permission = "delete_projects"  // удаление проектов GitLab
+	permDeleteIssues    permission = "delete_issues"    // удаление задач GitLab
+	permChangeRoles     permission = "change_roles"     // назначение ролей пользователям
+	permManageTemplates permission = "manage_templates" // шаблоны начальной настройки проектов
+	permManageRoles     permission = "manage_roles"     // назначение ролей участникам проекта
+	permForceLogout     permission = "force_logout"     // завершение сессий других пользователей
+	permManageUsers     permission = "manage_users"     // сброс паролей локальных пользователей
+)
+
+// permissionNames — названия действий для сообщений об отказе
+var permissionNames = map[permission]string{
+	permManageSprints:   "управление спринтами",
+	permMoveIssues:      "работа с задачами спринта",
+	permManageProjects:  "управление проектами",
+	permDeleteProjects:  "удаление проектов",
+	permDeleteIssues:    "удаление задач",
+	permChangeRoles:     "назначение ролей",
+	permManageTemplates: "управление шаблонами проектов",
+	permManageRoles:     "назначение ролей в проекте",
+	permForceLogout:     "завершение сессий пользователей",
+	permManageUsers:     "управление локальными пользователями",
+}
+
+// roleNames — названия ролей для сообщений об отказе
+var roleNames = map[string]string{
+	roleAdministrator:  "администратор",
+	roleProjectManager: "менеджер проекта",
+	roleDeveloper:      "разработчик",
+}
+
+// rolePermissions — действия, разрешенные каждой роли
+var rolePermissions = map[string][]permission{
+	roleAdministrator: {
+		permManageSprints, permMoveIssues, permManageProjects, permDeleteProjects,
+		permDeleteIssues, permChangeRoles, permManageTemplates, permManageRoles, permForceLogout,
+		permManageUsers,
+	},
+	roleProjectManager: {
+		permManageSprints, permMoveIssues, permManageProjects, permDeleteIssues, permManageRoles,
+	},
+	roleDeveloper: {
+		permMoveIssues,
+	},
+}
+
+// validRoles возвращает роли плагина в порядке убывания прав
+func validRoles() []string {
+	return []string{roleAdministrator, roleProjectManager, roleDeveloper}
+}
+
+// roleAllows проверяет, разрешено ли роли действие
+func roleAllows(role string, perm permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// rolesWith возвращает роли, которым разрешено действие
+func rolesWith(perm permission) []string {
+	var roles []string
+	for _, role := range validRoles() {
+		if roleAllows(role, perm) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// userRole возвращает роль пользователя запроса. Роль читается из user_settings
+// при каждом запросе, чтобы смена роли действовала сразу, а не после истечения JWT.
+func (app *application) userRole(c *gin.Context) (string, error) {
+	if role := c.GetString(contextKeyRole); role != "" {
+		return role, nil
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return "", nil
+	}
+	var role string
+	if user.ID == 0 && user.LocalID > 0 {
+		local, err := app.localUserRole(user.LocalID)
+		if err != nil {
+			return "", err
+		}
+		role = local
+	} else {
+		roles, err := app.userRoles([]int{user.ID})
+		if err != nil {
+			return "", err
+		}
+		role = roles[user.ID]
+	}
+	c.Set(contextKeyRole, role)
+	return role, nil
+}
+
+// localUserRole возвращает роль локального пользователя, не связанного с GitLab,
+// из users.usr_role. Неизвестные значения заменяются ролью по умолчанию.
+func (app *application) localUserRole(localID int) (string, error) {
+	user, err := app.models.GetLocalUserByID(localID)
+	if err != nil {
+		return "", err
+	}
+	if !containsString(validRoles(), user.UsrRole) {
+		return defaultUserRole, nil
+	}
+	return user.UsrRole, nil
+}
+
+// sessionRole возвращает глобальную роль пользователя сессии
+func (app *application) sessionRole(session *models.Session) (string, error) {
+	if session.UserID == 0 && session.LocalUserID != nil {
+		return app.localUserRole(*session.LocalUserID)
+	}
+	roles, err := app.userRoles([]int{session.UserID})
+	if err != nil {
+		return "", err
+	}
+	return roles[session.UserID], nil
+}
+
+// isGitLabAdmin проверяет, является ли пользователь администратором GitLab.
+// Администраторы GitLab имеют права администратора плагина, в том числе для
+// назначения первых ролей.
+func (app *application) isGitLabAdmin(c *gin.Context) bool {
+	token, err := app.requestGitLabToken(c)
+	if err != nil || token == "" {
+		return false
+	}
+	user, err := app.gitlab.WithToken(token).CurrentUser(c.Request.Context())
+	if err != nil {
+		app.errorLog.Printf("Не удалось проверить администратора GitLab: %v", err)
+		return false
+	}
+	return user.IsAdmin
+}
+
+// require возвращает middleware, пропускающее только пользователей, глобальная
+// роль которых разрешает действие perm. Отказ — 403 с причиной.
+func (app *application) require(perm permission) gin.HandlerFunc {
+	return app.requireWith(perm, app.userRole)
+}
+
+// requireProject — как require, но проверяет роль пользователя в проекте из
+// параметра маршрута :id
+func (app *application) requireProject(perm permission) gin.HandlerFunc {
+	return app.requireWith(perm, app.projectRole)
+}
+
+func (app *application) requireWith(perm permission, resolve roleResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.allowWith(c, perm, resolve) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow проверяет, разрешено ли пользователю запроса действие perm по его
+// глобальной роли. При отказе ответ уже отправлен.
+func (app *application) allow(c *gin.Context, perm permission) bool {
+	return app.allowWith(c, perm, app.userRole)
+}
+
+func (app *application) allowWith(c *gin.Context, perm permission, resolve roleResolver) bool {
+	if _, ok := currentUser(c); !ok {
+		app.respondReauth(c, "Требуется вход")
+		return false
+	}
+
+	if token, ok := currentPersonalToken(c); ok && !scopesAllow(token.Scopes, perm) {
+		app.respondScopeForbidden(c, perm, token)
+		return false
+	}
+
+	role, err := resolve(c)
+	if err != nil {
+		app.errorLog.Printf("Ошибка получения роли пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить права пользователя"})
+		return false
+	}
+
+	if roleAllows(role, perm) {
+		return true
+	}
+	if roleAllows(roleAdministrator, perm) && app.isGitLabAdmin(c) {
+		c.Set(contextKeyRole, roleAdministrator)
+		return true
+	}
+
+	app.respondForbidden(c, perm, role)
+	return false
+}
+
+// respondForbidden отвечает 403 с объяснением, какой роли не хватает
+func (app *application) respondForbidden(c *gin.Context, perm permission, role string) {
+	allowed := rolesWith(perm)
+	names := make([]string, 0, len(allowed))
+	for _, r := range allowed {
+		names = append(names, roleNames[r])
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": fmt.Sprintf("Недостаточно прав: действие «%s» доступно ролям: %s (ваша роль: %s)",
+			permissionNames[perm], strings.Join(names, ", "), roleNames[role]),
+		"permission":     perm,
+		"role":           role,
+		"required_roles": allowed,
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	// Матрица прав: для каждой роли перечислены все действия, отсутствие
+	// действия в списке означает отказ
+	allowed := map[string][]permission{
+		roleAdministrator: {
+			permManageSprints, permMoveIssues, permManageProjects, permDeleteProjects, permDeleteIssues,
+			permChangeRoles, permManageTemplates, permManageRoles, permForceLogout, permManageUsers,
+		},
+		roleProjectManager: {
+			permManageSprints, permMoveIssues, permManageProjects, permDeleteIssues, permManageRoles,
+		},
+		roleDeveloper: {
+			permMoveIssues,
+		},
+		"":        nil,
+		"unknown": nil,
+	}
+
+	for role, perms := range allowed {
+		for perm := range permissionNames {
+			want := false
+			for _, p := range perms {
+				if p == perm {
+					want = true
+				}
+			}
+			t.Run(role+"/"+string(perm), func(t *testing.T) {
+				if got := roleAllows(role, perm); got != want {
+					t.Errorf("roleAllows(%q, %s) = %t, ожидалось %t", role, perm, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRoleAllowsDenied(t *testing.T) {
+	tests := []struct {
+		role string
+		perm permission
+	}{
+		{roleDeveloper, permDeleteProjects},
+		{roleDeveloper, permManageRoles},
+		{roleDeveloper, permManageSprints},
+		{roleDeveloper, permDeleteIssues},
+		{roleDeveloper, permChangeRoles},
+		{roleProjectManager, permDeleteProjects},
+		{roleProjectManager, permChangeRoles},
+		{roleProjectManager, permManageTemplates},
+		{roleProjectManager, permForceLogout},
+		{roleProjectManager, permManageUsers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+"/"+string(tt.perm), func(t *testing.T) {
+			if roleAllows(tt.role, tt.perm) {
+				t.Errorf("роли %s разрешено %s", tt.role, tt.perm)
+			}
+		})
+	}
+}
+
+func TestRolesWith(t *testing.T) {
+	tests := []struct {
+		perm permission
+		want []string
+	}{
+		{permMoveIssues, []string{roleAdministrator, roleProjectManager, roleDeveloper}},
+		{permManageSprints, []string{roleAdministrator, roleProjectManager}},
+		{permManageRoles, []string{roleAdministrator, roleProjectManager}},
+		{permDeleteProjects, []string{roleAdministrator}},
+		{permChangeRoles, []string{roleAdministrator}},
+		{permManageUsers, []string{roleAdministrator}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.perm), func(t *testing.T) {
+			if got := rolesWith(tt.perm); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("rolesWith(%s) = %v, ожидалось %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+
+	// У каждого действия есть название для сообщения об отказе, и его может выполнить администратор
+	for perm := range permissionNames {
+		if !roleAllows(roleAdministrator, perm) {
+			t.Errorf("администратору не разрешено %s", perm)
+		}
+	}
+	for _, perms := range rolePermissions {
+		for _, perm := range perms {
+			if permissionNames[perm] == "" {
+				t.Errorf("у действия %s нет названия", perm)
+			}
+		}
+	}
+}
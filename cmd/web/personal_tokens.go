@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// personalTokenPrefix отличает персональные токены от JWT в заголовке Authorization
+const personalTokenPrefix = "pat_"
+
+// Срок действия персонального токена по умолчанию и наибольший
+const (
+	personalTokenDefaultTTL = 90 * 24 * time.Hour
+	personalTokenMaxTTL     = 365 * 24 * time.Hour
+)
+
+// contextKeyPersonalToken — ключ контекста gin для персонального токена запроса
+const contextKeyPersonalToken = "personal_token"
+
+// Области (scopes) персональных токенов
+const (
+	scopeRead     = "read"     // чтение (GET)
+	scopeSprints  = "sprints"  // спринты и задачи спринтов
+	scopeProjects = "projects" // проекты, задачи и вебхуки GitLab, роли в проекте
+	scopeAdmin    = "admin"    // глобальные роли, шаблоны проектов, сессии и пароли пользователей
+)
+
+// scopePermissions — действия, разрешенные токену с областью. Роль владельца
+// токена при этом тоже должна разрешать действие.
+var scopePermissions = map[string][]permission{
+	scopeRead:     {},
+	scopeSprints:  {permManageSprints, permMoveIssues},
+	scopeProjects: {permManageProjects, permDeleteProjects, permDeleteIssues, permManageRoles},
+	scopeAdmin:    {permChangeRoles, permManageTemplates, permForceLogout, permManageUsers},
+}
+
+// validScopes возвращает области персональных токенов
+func validScopes() []string {
+	return []string{scopeRead, scopeSprints, scopeProjects, scopeAdmin}
+}
+
+// scopesAllow проверяет, разрешено ли действие хотя бы одной из областей
+func scopesAllow(scopes []string, perm permission) bool {
+	for _, scope := range scopes {
+		for _, p := range scopePermissions[scope] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopesWith возвращает области, разрешающие действие
+func scopesWith(perm permission) []string {
+	var scopes []string
+	for _, scope := range validScopes() {
+		if scopesAllow([]string{scope}, perm) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// currentPersonalToken возвращает персональный токен, которым подписан запрос
+func currentPersonalToken(c *gin.Context) (*models.PersonalToken, bool) {
+	value, ok := c.Get(contextKeyPersonalToken)
+	if !ok {
+		return nil, false
+	}
+	token, ok := value.(*models.PersonalToken)
+	return token, ok && token != nil
+}
+
+// authenticatePersonalToken проверяет персональный токен и делает его владельца
+// пользователем запроса. Запросы на чтение требуют области read; изменения
+// проверяются по областям в middleware прав (require/requireProject). Обращаться
+// к GitLab персональный токен не может: gitlabClient отвечает 403.
+func (app *application) authenticatePersonalToken(c *gin.Context, raw string) bool {
+	token, err := app.models.GetActivePersonalToken(hashSessionToken(raw))
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.errorLog.Printf("Ошибка получения персонального токена: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки токена"})
+			return false
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Персональный токен недействителен, истек или отозван"})
+		return false
+	}
+
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		if !containsString(token.Scopes, scopeRead) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":           "Для чтения токену нужна область read",
+				"required_scopes": []string{scopeRead},
+			})
+			return false
+		}
+	}
+
+	if err := app.models.TouchPersonalToken(token.ID, c.ClientIP()); err != nil {
+		app.errorLog.Printf("%v", err)
+	}
+
+	c.Set(contextKeyPersonalToken, token)
+	c.Set(contextKeyUser, &sessionUser{
+		ID:       token.UserID,
+		Username: token.Username,
+		Name:     token.UserName,
+		Email:    token.UserEmail,
+	})
+	return true
+}
+
+// respondScopeForbidden отвечает 403, если областей токена не хватает для действия
+func (app *application) respondScopeForbidden(c *gin.Context, perm permission, token *models.PersonalToken) {
+	required := scopesWith(perm)
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": fmt.Sprintf("Недостаточно прав токена: действие «%s» требует области: %s",
+			permissionNames[perm], strings.Join(required, ", ")),
+		"permission":      perm,
+		"scopes":          token.Scopes,
+		"required_scopes": required,
+	})
+}
+
+// CreatePersonalTokenRequest — тело запроса создания персонального токена
+type CreatePersonalTokenRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresAt string   `json:"expires_at"` // ГГГГ-ММ-ДД, по умолчанию — через 90 дней
+}
+
+// personalTokenExpiry разбирает дату истечения токена: токен действует до конца
+// указанного дня (UTC), но не дольше года
+func personalTokenExpiry(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now.Add(personalTokenDefaultTTL), nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("неверный формат даты истечения, ожидается ГГГГ-ММ-ДД")
+	}
+	expiresAt := date.AddDate(0, 0, 1)
+	if !expiresAt.After(now) {
+		return time.Time{}, errors.New("дата истечения должна быть в будущем")
+	}
+	if expiresAt.Sub(now) > personalTokenMaxTTL {
+		return time.Time{}, errors.New("токен не может действовать дольше года")
+	}
+	return expiresAt, nil
+}
+
+// requireInteractiveSession пропускает только пользователя, вошедшего через GitLab:
+// персональными токенами нельзя выпускать и отзывать другие токены
+func (app *application) requireInteractiveSession(c *gin.Context) (*models.Session, bool) {
+	session, ok := currentSession(c)
+	if !ok {
+		if _, isToken := currentPersonalToken(c); isToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Персональными токенами управляют только после входа через GitLab"})
+			return nil, false
+		}
+		app.respondReauth(c, "Требуется вход")
+		return nil, false
+	}
+	return session, true
+}
+
+// getPersonalTokens возвращает персональные токены текущего пользователя
+func (app *application) getPersonalTokens(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		app.respondReauth(c, "Требуется вход")
+		return
+	}
+
+	tokens, err := app.models.GetPersonalTokens(user.ID)
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить персональные токены")
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// createPersonalToken выпускает персональный токен. Значение токена возвращается
+// только в этом ответе.
+func (app *application) createPersonalToken(c *gin.Context) {
+	session, ok := app.requireInteractiveSession(c)
+	if !ok {
+		return
+	}
+	if session.UserID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Персональные токены доступны пользователям, связанным с GitLab"})
+		return
+	}
+
+	var req CreatePersonalTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неверный формат данных: %v", err)})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Название токена должно быть от 1 до 255 символов"})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Укажите области токена: %v", validScopes())})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !containsString(validScopes(), scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Неизвестная область %q. Допустимые области: %v", scope, validScopes()),
+			})
+			return
+		}
+	}
+	expiresAt, err := personalTokenExpiry(req.ExpiresAt, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := randomURLToken(32)
+	if err != nil {
+		app.errorLog.Printf("Ошибка генерации персонального токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выпустить токен"})
+		return
+	}
+	value := personalTokenPrefix + secret
+
+	token := &models.PersonalToken{
+		Hash:      hashSessionToken(value),
+		Prefix:    value[:len(personalTokenPrefix)+8],
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		UserID:    session.UserID,
+		Username:  session.Username,
+		UserName:  session.Name,
+		UserEmail: session.Email,
+		ExpiresAt: expiresAt,
+	}
+	if err := app.models.CreatePersonalToken(token); err != nil {
+		app.respondError(c, err, "Не удалось выпустить токен")
+		return
+	}
+
+	app.infoLog.Printf("Пользователь %s выпустил персональный токен %d (%s)", session.Username, token.ID, strings.Join(token.Scopes, ", "))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"token":          value,
+		"personal_token": token,
+	})
+}
+
+// revokePersonalToken отзывает персональный токен текущего пользователя
+func (app *application) revokePersonalToken(c *gin.Context) {
+	session, ok := app.requireInteractiveSession(c)
+	if !ok {
+		return
+	}
+	tokenID, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil || tokenID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID токена"})
+		return
+	}
+
+	if err := app.models.RevokePersonalToken(tokenID, session.UserID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Токен не найден или уже отозван"})
+			return
+		}
+		app.respondError(c, err, "Не удалось отозвать токен")
+		return
+	}
+
+	app.infoLog.Printf("Пользователь %s отозвал персональный токен %d", session.Username, tokenID)
+	c.JSON(http.StatusOK, gin.H{"message": "Токен отозван"})
+}
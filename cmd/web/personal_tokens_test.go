@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScopesAllow(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		perm   permission
+		want   bool
+	}{
+		{"только чтение", []string{scopeRead}, permManageSprints, false},
+		{"спринты", []string{scopeSprints}, permManageSprints, true},
+		{"спринты и задачи спринта", []string{scopeSprints}, permMoveIssues, true},
+		{"спринты не дают удалять проекты", []string{scopeSprints}, permDeleteProjects, false},
+		{"проекты", []string{scopeProjects}, permManageRoles, true},
+		{"проекты не дают менять глобальные роли", []string{scopeProjects}, permChangeRoles, false},
+		{"администрирование", []string{scopeAdmin}, permManageUsers, true},
+		{"администрирование не дает работать со спринтами", []string{scopeAdmin}, permManageSprints, false},
+		{"несколько областей", []string{scopeRead, scopeSprints, scopeProjects}, permDeleteIssues, true},
+		{"неизвестная область", []string{"write"}, permManageSprints, false},
+		{"без областей", nil, permMoveIssues, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopesAllow(tt.scopes, tt.perm); got != tt.want {
+				t.Errorf("scopesAllow(%v, %s) = %t, ожидалось %t", tt.scopes, tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesWith(t *testing.T) {
+	tests := []struct {
+		perm permission
+		want []string
+	}{
+		{permManageSprints, []string{scopeSprints}},
+		{permManageProjects, []string{scopeProjects}},
+		{permForceLogout, []string{scopeAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.perm), func(t *testing.T) {
+			if got := scopesWith(tt.perm); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("scopesWith(%s) = %v, ожидалось %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+
+	// Каждое действие должно быть доступно хотя бы одной области, иначе
+	// его нельзя выполнить персональным токеном вовсе
+	for perm := range permissionNames {
+		if len(scopesWith(perm)) == 0 {
+			t.Errorf("действие %s не разрешено ни одной областью", perm)
+		}
+	}
+}
+
+func TestPersonalTokenExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"по умолчанию 90 дней", "", now.Add(personalTokenDefaultTTL), false},
+		{"до конца указанного дня", "2024-06-01", time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), false},
+		{"сегодняшняя дата", "2024-05-10", time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC), false},
+		{"вчерашняя дата", "2024-05-09", time.Time{}, true},
+		{"дольше года", "2025-06-01", time.Time{}, true},
+		{"неверный формат", "01.06.2024", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := personalTokenExpiry(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("personalTokenExpiry(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
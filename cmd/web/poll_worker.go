@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// pollWorker опрашивает GitLab вместо вебхуков: для установок за NAT, до которых
+// GitLab не может достучаться. Push-события берутся из Events API, мердж-реквесты
+// и задачи — из списков с updated_after. Найденное обрабатывается той же логикой,
+// что и вебхуки. Одобрения MR через опрос не отслеживаются.
+type pollWorker struct {
+	app      *application
+	client   *gitlab.Client
+	interval time.Duration
+}
+
+func newPollWorker(app *application, serviceToken string, interval time.Duration) *pollWorker {
+	return &pollWorker{
+		app:      app,
+		client:   app.gitlab.WithToken(serviceToken),
+		interval: interval,
+	}
+}
+
+// Run опрашивает GitLab сразу и затем каждые interval до отмены контекста
+func (w *pollWorker) Run(ctx context.Context) {
+	w.app.infoLog.Printf("Опрос событий GitLab запущен: интервал %s", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.pollOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.app.infoLog.Printf("Опрос событий GitLab остановлен")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce опрашивает все подключенные проекты и проекты со спринтами
+func (w *pollWorker) pollOnce(ctx context.Context) {
+	projectIDs, err := w.app.models.GetPolledProjectIDs()
+	if err != nil {
+		w.app.errorLog.Printf("Опрос GitLab: ошибка получения проектов: %v", err)
+		return
+	}
+
+	for _, projectID := range projectIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := w.pollProject(ctx, projectID); err != nil {
+			w.app.errorLog.Printf("Опрос GitLab: проект %d: %v", projectID, err)
+			// Если GitLab недоступен, остальные проекты тоже не опросить
+			if errors.Is(err, gitlab.ErrCircuitOpen) {
+				return
+			}
+		}
+	}
+}
+
+// pollProject обрабатывает новые события проекта и сдвигает курсор.
+// Курсор сохраняется после каждого этапа, даже если следующий завершился ошибкой,
+// поэтому уже обработанные события не будут обработаны повторно.
+func (w *pollWorker) pollProject(ctx context.Context, projectID int) error {
+	cursor, err := w.app.models.GetPollCursor(projectID)
+	if errors.Is(err, models.ErrNoRecord) {
+		// Первый опрос: историю не воспроизводим, начинаем с текущего момента
+		now := time.Now()
+		w.app.infoLog.Printf("Опрос GitLab: проект %d добавлен, события отслеживаются с %s", projectID, now.Format(time.RFC3339))
+		return w.app.models.SavePollCursor(&models.PollCursor{
+			ProjectID:          projectID,
+			EventsSince:        now,
+			MergeRequestsSince: now,
+			IssuesSince:        now,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	stages := []func(context.Context, *models.PollCursor) error{
+		w.pollPushEvents,
+		w.pollMergeRequests,
+		w.pollIssues,
+	}
+	for _, stage := range stages {
+		stageErr := stage(ctx, cursor)
+		if err := w.app.models.SavePollCursor(cursor); err != nil {
+			return err
+		}
+		if stageErr != nil {
+			return stageErr
+		}
+	}
+
+	return nil
+}
+
+// pollPushEvents обрабатывает push-события после последнего обработанного события
+func (w *pollWorker) pollPushEvents(ctx context.Context, cursor *models.PollCursor) error {
+	projectID := strconv.Itoa(cursor.ProjectID)
+
+	// Параметр after принимает только дату и не включает ее, поэтому берем день
+	// раньше и отбрасываем уже обработанные события по ID
+	query := url.Values{
+		"action": {"pushed"},
+		"sort":   {"asc"},
+		"after":  {cursor.EventsSince.AddDate(0, 0, -1).Format("2006-01-02")},
+	}
+	events, err := w.client.ListProjectEvents(ctx, projectID, query)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if event.ID <= cursor.LastEventID {
+			continue
+		}
+
+		if event.PushData != nil {
+			webhook, err := w.pushWebhook(ctx, cursor.ProjectID, event.PushData)
+			if err != nil {
+				return err
+			}
+			if webhook != nil {
+				if err := w.app.handleGitLabPush(*webhook); err != nil {
+					w.app.errorLog.Printf("Опрос GitLab: ошибка обработки push-события %d: %v", event.ID, err)
+				}
+			}
+		}
+
+		cursor.LastEventID = event.ID
+		if event.CreatedAt != nil {
+			cursor.EventsSince = *event.CreatedAt
+		}
+	}
+
+	return nil
+}
+
+// pushWebhook собирает тело push-вебхука по событию: коммиты берутся из сравнения
+// commit_from..commit_to или, для новой ветки, последние commit_count коммитов.
+// Для тегов и удаленных веток возвращает nil.
+func (w *pollWorker) pushWebhook(ctx context.Context, projectID int, data *gitlab.PushData) (*GitLabWebhookRequest, error) {
+	if data.RefType != "branch" || data.CommitTo == nil {
+		return nil, nil
+	}
+
+	var commits []gitlab.Commit
+	var err error
+	if data.CommitFrom != nil {
+		commits, err = w.client.CompareCommits(ctx, strconv.Itoa(projectID), *data.CommitFrom, *data.CommitTo)
+	} else {
+		count := min(max(data.CommitCount, 1), gitlab.MaxPerPage)
+		query := url.Values{"ref_name": {*data.CommitTo}, "per_page": {strconv.Itoa(count)}}
+		commits, err = w.client.ListCommits(ctx, strconv.Itoa(projectID), query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &GitLabWebhookRequest{ObjectKind: "push"}
+	webhook.Project.ID = projectID
+	for _, commit := range commits {
+		webhookCommit := GitLabWebhookCommit{
+			ID:      commit.ID,
+			Message: commit.Message,
+			Title:   commit.Title,
+		}
+		if commit.CommittedDate != nil {
+			webhookCommit.Timestamp = commit.CommittedDate.Format(time.RFC3339)
+		}
+		webhookCommit.Author.Name = commit.AuthorName
+		webhookCommit.Author.Email = commit.AuthorEmail
+		webhook.Commits = append(webhook.Commits, webhookCommit)
+	}
+
+	return webhook, nil
+}
+
+// pollMergeRequests обрабатывает мердж-реквесты, измененные после курсора
+func (w *pollWorker) pollMergeRequests(ctx context.Context, cursor *models.PollCursor) error {
+	query := url.Values{
+		"updated_after": {cursor.MergeRequestsSince.Format(time.RFC3339Nano)},
+		"order_by":      {"updated_at"},
+		"sort":          {"asc"},
+		"scope":         {"all"},
+	}
+	mergeRequests, err := w.client.ListProjectMergeRequests(ctx, strconv.Itoa(cursor.ProjectID), query)
+	if err != nil {
+		return err
+	}
+
+	for _, mr := range mergeRequests {
+		// updated_after включает границу — пропускаем уже обработанное
+		if mr.UpdatedAt == nil || pollSeen(cursor.MergeRequestsSince, cursor.MergeRequestsSeen, mr.IID, *mr.UpdatedAt) {
+			continue
+		}
+
+		previousState, err := w.app.models.GetMergeRequestState(cursor.ProjectID, mr.IID)
+		if err != nil {
+			return err
+		}
+		if action := mergeRequestAction(previousState, mr.State); action != "" {
+			webhook := mergeRequestWebhook(cursor.ProjectID, mr, action)
+			if err := w.app.handleGitLabMergeRequest(webhook); err != nil {
+				w.app.errorLog.Printf("Опрос GitLab: ошибка обработки мердж-реквеста !%d: %v", mr.IID, err)
+			}
+		}
+
+		pollAdvance(&cursor.MergeRequestsSince, &cursor.MergeRequestsSeen, mr.IID, *mr.UpdatedAt)
+	}
+
+	return nil
+}
+
+// pollSeen сообщает, что изменение объекта iid уже обработано. Граница курсора
+// включается: объекты, обновленные в ту же секунду, что и последний обработанный,
+// отбрасываются только по номеру из seen.
+func pollSeen(since time.Time, seen []int, iid int, updatedAt time.Time) bool {
+	if updatedAt.Before(since) {
+		return true
+	}
+	if updatedAt.Equal(since) {
+		for _, id := range seen {
+			if id == iid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pollAdvance сдвигает курсор на обработанное изменение объекта iid
+func pollAdvance(since *time.Time, seen *[]int, iid int, updatedAt time.Time) {
+	if updatedAt.After(*since) {
+		*since = updatedAt
+		*seen = []int{iid}
+		return
+	}
+	*seen = append(*seen, iid)
+}
+
+// mergeRequestAction восстанавливает действие вебхука по смене состояния MR.
+// Пустая строка означает, что состояние уже обработано.
+func mergeRequestAction(previousState, state string) string {
+	switch state {
+	case "merged":
+		if previousState != "merged" {
+			return "merge"
+		}
+	case "closed":
+		if previousState != "closed" {
+			return "close"
+		}
+	case "opened":
+		switch previousState {
+		case "":
+			return "open"
+		case "closed", "merged":
+			return "reopen"
+		default:
+			return "update"
+		}
+	}
+	return ""
+}
+
+// mergeRequestWebhook собирает тело вебхука merge_request по данным API
+func mergeRequestWebhook(projectID int, mr gitlab.MergeRequest, action string) GitLabWebhookRequest {
+	webhook := GitLabWebhookRequest{ObjectKind: "merge_request"}
+	webhook.Project.ID = projectID
+	webhook.ObjectAttributes = GitLabMergeRequestAttributes{
+		ID:              mr.ID,
+		IID:             mr.IID,
+		Title:           mr.Title,
+		Description:     mr.Description,
+		State:           mr.State,
+		Action:          action,
+		CreatedAt:       formatOptionalTime(mr.CreatedAt),
+		UpdatedAt:       formatOptionalTime(mr.UpdatedAt),
+		MergedAt:        formatOptionalTime(mr.MergedAt),
+		SourceBranch:    mr.SourceBranch,
+		TargetBranch:    mr.TargetBranch,
+		TargetProjectID: projectID,
+		Draft:           mr.Draft,
+		URL:             mr.WebURL,
+	}
+	if mr.Author != nil {
+		webhook.ObjectAttributes.AuthorID = mr.Author.ID
+		webhook.User = GitLabWebhookUser{ID: mr.Author.ID, Name: mr.Author.Name, Username: mr.Author.Username}
+	}
+	for _, reviewer := range mr.Reviewers {
+		webhook.Reviewers = append(webhook.Reviewers, GitLabWebhookUser{ID: reviewer.ID, Name: reviewer.Name, Username: reviewer.Username})
+	}
+	return webhook
+}
+
+// pollIssues сверяет задачи спринтов, измененные в GitLab после курсора
+func (w *pollWorker) pollIssues(ctx context.Context, cursor *models.PollCursor) error {
+	query := url.Values{
+		"updated_after": {cursor.IssuesSince.Format(time.RFC3339Nano)},
+		"order_by":      {"updated_at"},
+		"sort":          {"asc"},
+		"scope":         {"all"},
+	}
+	issues, err := w.client.ListProjectIssues(ctx, strconv.Itoa(cursor.ProjectID), query)
+	if err != nil || len(issues) == 0 {
+		return err
+	}
+
+	sprintIssues, err := w.projectSprintIssues(cursor.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	for i := range issues {
+		issue := &issues[i]
+		if issue.UpdatedAt == nil || pollSeen(cursor.IssuesSince, cursor.IssuesSeen, issue.IID, *issue.UpdatedAt) {
+			continue
+		}
+
+		for _, sprintIssue := range sprintIssues[issue.IID] {
+			if err := w.app.applyGitLabIssue(cursor.ProjectID, sprintIssue, issue); err != nil {
+				w.app.errorLog.Printf("Опрос GitLab: ошибка сверки задачи #%d спринта %d: %v", issue.IID, sprintIssue.SprintID, err)
+			}
+		}
+
+		pollAdvance(&cursor.IssuesSince, &cursor.IssuesSeen, issue.IID, *issue.UpdatedAt)
+	}
+
+	return nil
+}
+
+// projectSprintIssues возвращает задачи незавершенных спринтов проекта по номеру задачи
+func (w *pollWorker) projectSprintIssues(projectID int) (map[int][]models.SprintIssue, error) {
+	sprints, err := w.app.models.GetActiveSprints()
+	if err != nil {
+		return nil, err
+	}
+
+	byIID := map[int][]models.SprintIssue{}
+	for _, sprint := range sprints {
+		if sprint.SptProjectID != projectID {
+			continue
+		}
+		issues, err := w.app.models.GetSprintIssues(sprint.SptID)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			byIID[issue.IssueID] = append(byIID[issue.IssueID], issue)
+		}
+	}
+	return byIID, nil
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
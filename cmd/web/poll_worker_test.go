@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPollSeen(t *testing.T) {
+	since := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		seen      []int
+		iid       int
+		updatedAt time.Time
+		want      bool
+	}{
+		{"обновлен раньше курсора", []int{1}, 2, since.Add(-time.Second), true},
+		{"на границе и уже обработан", []int{1, 2}, 2, since, true},
+		{"на границе, но еще не обработан", []int{1}, 2, since, false},
+		{"на границе без обработанных", nil, 2, since, false},
+		{"обновлен после курсора", []int{2}, 2, since.Add(time.Second), false},
+		{"тот же объект обновлен позже", []int{2}, 2, since.Add(time.Millisecond), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pollSeen(since, tt.seen, tt.iid, tt.updatedAt); got != tt.want {
+				t.Errorf("pollSeen(%v, %d, %s) = %t, ожидалось %t", tt.seen, tt.iid, tt.updatedAt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollAdvance(t *testing.T) {
+	start := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	type change struct {
+		iid       int
+		updatedAt time.Time
+	}
+	tests := []struct {
+		name      string
+		changes   []change
+		wantSince time.Time
+		wantSeen  []int
+	}{
+		{
+			name:      "новое время сбрасывает обработанные",
+			changes:   []change{{1, start}, {2, start.Add(time.Second)}},
+			wantSince: start.Add(time.Second),
+			wantSeen:  []int{2},
+		},
+		{
+			name:      "одинаковое время копит обработанные",
+			changes:   []change{{1, start.Add(time.Second)}, {2, start.Add(time.Second)}, {3, start.Add(time.Second)}},
+			wantSince: start.Add(time.Second),
+			wantSeen:  []int{1, 2, 3},
+		},
+		{
+			name:      "объект на исходной границе",
+			changes:   []change{{4, start}},
+			wantSince: start,
+			wantSeen:  []int{9, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, seen := start, []int{9}
+			for _, c := range tt.changes {
+				pollAdvance(&since, &seen, c.iid, c.updatedAt)
+			}
+			if !since.Equal(tt.wantSince) || fmt.Sprint(seen) != fmt.Sprint(tt.wantSeen) {
+				t.Errorf("курсор = %s %v, ожидалось %s %v", since, seen, tt.wantSince, tt.wantSeen)
+			}
+		})
+	}
+}
+
+// TestPollNoDuplicates проверяет, что повторный опрос с включенной границей не
+// обрабатывает изменения дважды, но не теряет изменения в ту же секунду
+func TestPollNoDuplicates(t *testing.T) {
+	start := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	at := start.Add(time.Second)
+
+	since, seen := start, []int(nil)
+	var processed []int
+	poll := func(iids ...int) {
+		for _, iid := range iids {
+			if pollSeen(since, seen, iid, at) {
+				continue
+			}
+			processed = append(processed, iid)
+			pollAdvance(&since, &seen, iid, at)
+		}
+	}
+
+	poll(1, 2)
+	// GitLab возвращает объекты на границе снова, вместе с новым в ту же секунду
+	poll(1, 2, 3)
+
+	if fmt.Sprint(processed) != "[1 2 3]" {
+		t.Errorf("обработано %v, ожидалось [1 2 3]", processed)
+	}
+}
+
+func TestMergeRequestAction(t *testing.T) {
+	tests := []struct {
+		previous string
+		current  string
+		want     string
+	}{
+		{"", "opened", "open"},
+		{"opened", "opened", "update"},
+		{"closed", "opened", "reopen"},
+		{"merged", "opened", "reopen"},
+		{"", "merged", "merge"},
+		{"opened", "merged", "merge"},
+		{"merged", "merged", ""},
+		{"", "closed", "close"},
+		{"opened", "closed", "close"},
+		{"closed", "closed", ""},
+		{"opened", "locked", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous+"→"+tt.current, func(t *testing.T) {
+			if got := mergeRequestAction(tt.previous, tt.current); got != tt.want {
+				t.Errorf("mergeRequestAction(%q, %q) = %q, ожидалось %q", tt.previous, tt.current, got, tt.want)
+			}
+		})
+	}
+}
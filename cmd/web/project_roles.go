@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// contextKeyProjectRole — ключ контекста gin для роли пользователя в проекте запроса
+const contextKeyProjectRole = "project_role"
+
+// Откуда взята роль пользователя в проекте
+const (
+	roleSourceGlobal  = "global"  // глобальная роль из user_settings
+	roleSourceProject = "project" // назначена в проекте
+	roleSourceGitLab  = "gitlab"  // выведена из уровня доступа в GitLab
+)
+
+// assignableProjectRoles возвращает роли, которые можно назначить в проекте.
+// Администратор назначается только глобально.
+func assignableProjectRoles() []string {
+	return []string{roleProjectManager, roleDeveloper}
+}
+
+// effectiveProjectRole определяет роль пользователя в проекте. Глобальный администратор
+// остается администратором во всех проектах; затем действует роль, назначенная
+// в проекте; затем доступ Maintainer и выше в GitLab дает роль project_manager
+// (если включено в настройках); иначе — глобальная роль.
+func (app *application) effectiveProjectRole(globalRole, assigned string, accessLevel int) (string, string) {
+	switch {
+	case globalRole == roleAdministrator:
+		return roleAdministrator, roleSourceGlobal
+	case assigned != "":
+		return assigned, roleSourceProject
+	case app.rolesFromGitLab && accessLevel >= gitlab.MaintainerAccess:
+		return roleProjectManager, roleSourceGitLab
+	default:
+		return globalRole, roleSourceGlobal
+	}
+}
+
+// projectRole возвращает роль пользователя запроса в проекте из параметра :id.
+// Без корректного ID проекта действует глобальная роль — обработчик сам ответит 400.
+func (app *application) projectRole(c *gin.Context) (string, error) {
+	if role := c.GetString(contextKeyProjectRole); role != "" {
+		return role, nil
+	}
+
+	globalRole, err := app.userRole(c)
+	if err != nil || globalRole == "" {
+		return globalRole, err
+	}
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || projectID < 1 {
+		return globalRole, nil
+	}
+	user, _ := currentUser(c)
+
+	assigned, err := app.models.GetProjectRole(projectID, user.ID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return "", err
+	}
+
+	accessLevel := 0
+	if assigned == "" && globalRole != roleAdministrator && app.rolesFromGitLab {
+		accessLevel = app.projectAccessLevel(c, projectID)
+	}
+
+	role, _ := app.effectiveProjectRole(globalRole, assigned, accessLevel)
+	c.Set(contextKeyProjectRole, role)
+	return role, nil
+}
+
+// projectAccessLevel возвращает уровень доступа пользователя запроса к проекту
+// GitLab. При ошибке считается, что доступа нет.
+func (app *application) projectAccessLevel(c *gin.Context, projectID int) int {
+	token, err := app.requestGitLabToken(c)
+	if err != nil || token == "" {
+		return 0
+	}
+	project, err := app.gitlab.WithToken(token).GetProject(c.Request.Context(), strconv.Itoa(projectID))
+	if err != nil {
+		if !errors.Is(err, gitlab.ErrNotFound) {
+			app.errorLog.Printf("Не удалось получить доступ к проекту %d: %v", projectID, err)
+		}
+		return 0
+	}
+	return project.AccessLevel()
+}
+
+// memberRole — роли участника проекта для ответа API
+type memberRole struct {
+	Global  string // глобальная роль
+	Project string // действующая роль в проекте
+	Source  string // откуда взята роль в проекте
+}
+
+// memberProjectRoles определяет роли участников проекта GitLab
+func (app *application) memberProjectRoles(projectID int, members []gitlab.Member) (map[int]memberRole, error) {
+	userIDs := make([]int, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.ID)
+	}
+	globalRoles, err := app.userRoles(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := map[int]string{}
+	if projectID > 0 {
+		roles, err := app.models.GetProjectRoles(projectID)
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range roles {
+			assigned[role.UserID] = role.Role
+		}
+	}
+
+	result := make(map[int]memberRole, len(members))
+	for _, member := range members {
+		role, source := app.effectiveProjectRole(globalRoles[member.ID], assigned[member.ID], member.AccessLevel)
+		result[member.ID] = memberRole{Global: globalRoles[member.ID], Project: role, Source: source}
+	}
+	return result, nil
+}
+
+// ProjectRoleRequest — тело запроса назначения роли в проекте
+type ProjectRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// projectRoleUserParam читает ID пользователя из параметра маршрута :userId
+func projectRoleUserParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID пользователя"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// getProjectRoles возвращает роли, назначенные в проекте вручную
+func (app *application) getProjectRoles(c *gin.Context) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	roles, err := app.models.GetProjectRoles(projectID)
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить роли в проекте")
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// setProjectRole назначает пользователю роль в проекте, переопределяя глобальную
+// роль и роль из GitLab
+func (app *application) setProjectRole(c *gin.Context) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+	userID, ok := projectRoleUserParam(c)
+	if !ok {
+		return
+	}
+
+	var req ProjectRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат данных"})
+		return
+	}
+	if !containsString(assignableProjectRoles(), req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Неверная роль в проекте. Допустимые роли: %v", assignableProjectRoles()),
+		})
+		return
+	}
+
+	role := &models.ProjectRole{ProjectID: projectID, UserID: userID, Role: req.Role}
+	if err := app.models.SetProjectRole(role); err != nil {
+		app.respondError(c, err, "Не удалось назначить роль в проекте")
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+// deleteProjectRole снимает назначенную в проекте роль: снова действуют роль
+// из GitLab и глобальная роль
+func (app *application) deleteProjectRole(c *gin.Context) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+	userID, ok := projectRoleUserParam(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.DeleteProjectRole(projectID, userID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Роль в проекте не назначена"})
+			return
+		}
+		app.respondError(c, err, "Не удалось снять роль в проекте")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Роль в проекте снята"})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"golangify.com/plaginagile/pkg/gitlab"
+)
+
+func TestEffectiveProjectRole(t *testing.T) {
+	tests := []struct {
+		name            string
+		rolesFromGitLab bool
+		globalRole      string
+		assigned        string
+		accessLevel     int
+		wantRole        string
+		wantSource      string
+	}{
+		{"администратор во всех проектах", true, roleAdministrator, roleDeveloper, gitlab.DeveloperAccess, roleAdministrator, roleSourceGlobal},
+		{"роль, назначенная в проекте", true, roleDeveloper, roleProjectManager, 0, roleProjectManager, roleSourceProject},
+		{"назначенная роль ниже глобальной", true, roleProjectManager, roleDeveloper, gitlab.MaintainerAccess, roleDeveloper, roleSourceProject},
+		{"Maintainer в GitLab", true, roleDeveloper, "", gitlab.MaintainerAccess, roleProjectManager, roleSourceGitLab},
+		{"Owner в GitLab", true, roleDeveloper, "", gitlab.OwnerAccess, roleProjectManager, roleSourceGitLab},
+		{"Developer в GitLab", true, roleDeveloper, "", gitlab.DeveloperAccess, roleDeveloper, roleSourceGlobal},
+		{"роли из GitLab выключены", false, roleDeveloper, "", gitlab.OwnerAccess, roleDeveloper, roleSourceGlobal},
+		{"без глобальной роли и доступа", true, "", "", 0, "", roleSourceGlobal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{rolesFromGitLab: tt.rolesFromGitLab}
+			role, source := app.effectiveProjectRole(tt.globalRole, tt.assigned, tt.accessLevel)
+			if role != tt.wantRole || source != tt.wantSource {
+				t.Errorf("effectiveProjectRole() = %q, %q, ожидалось %q, %q", role, source, tt.wantRole, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestProjectRolePermissions(t *testing.T) {
+	// Разработчик, которому в GitLab дан Maintainer, получает права менеджера
+	// только в этом проекте и по-прежнему не может удалять проекты
+	app := &application{rolesFromGitLab: true}
+	role, _ := app.effectiveProjectRole(roleDeveloper, "", gitlab.MaintainerAccess)
+
+	tests := []struct {
+		perm permission
+		want bool
+	}{
+		{permManageSprints, true},
+		{permManageRoles, true},
+		{permDeleteIssues, true},
+		{permDeleteProjects, false},
+		{permChangeRoles, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.perm), func(t *testing.T) {
+			if got := roleAllows(role, tt.perm); got != tt.want {
+				t.Errorf("roleAllows(%s, %s) = %t, ожидалось %t", role, tt.perm, got, tt.want)
+			}
+		})
+	}
+
+	for _, assignable := range assignableProjectRoles() {
+		if assignable == roleAdministrator {
+			t.Error("роль администратора назначается в проекте")
+		}
+	}
+}
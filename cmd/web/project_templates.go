@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// templateReport описывает результат применения шаблона к проекту.
+// Шаги выполняются независимо: ошибка одного шага не отменяет остальные.
+type templateReport struct {
+	TemplateID int      `json:"template_id"`
+	Labels     int      `json:"labels"`
+	BoardID    int      `json:"board_id,omitempty"`
+	BoardLists int      `json:"board_lists"`
+	Milestones int      `json:"milestones"`
+	Files      int      `json:"files"`
+	Errors     []string `json:"errors"`
+}
+
+func (r *templateReport) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// applyProjectTemplate создает в проекте метки, доску, вехи и файлы из шаблона.
+// Вехи нарезаются по датам проекта startDate и endDate (YYYY-MM-DD).
+func (app *application) applyProjectTemplate(ctx context.Context, client *gitlab.Client, projectID int, branch string, template *models.ProjectTemplate, startDate, endDate string) *templateReport {
+	report := &templateReport{TemplateID: template.ID, Errors: []string{}}
+	project := strconv.Itoa(projectID)
+	config := template.Config
+
+	// Метки; уже существующие метки не считаются ошибкой
+	labelIDs := map[string]int{}
+	for _, label := range config.Labels {
+		created, err := client.CreateLabel(ctx, project, map[string]interface{}{
+			"name":        label.Name,
+			"color":       label.Color,
+			"description": label.Description,
+		})
+		if err != nil {
+			if !errors.Is(err, gitlab.ErrConflict) {
+				report.fail("метка %q: %s", label.Name, gitlab.Message(err))
+			}
+			continue
+		}
+		labelIDs[created.Name] = created.ID
+		report.Labels++
+	}
+
+	if config.Board != nil {
+		app.applyTemplateBoard(ctx, client, project, config.Board, labelIDs, report)
+	}
+
+	if config.Milestones != nil {
+		ranges, err := milestoneRanges(startDate, endDate, config.Milestones.DurationDays)
+		if err != nil {
+			report.fail("вехи: %v", err)
+		}
+		prefix := config.Milestones.TitlePrefix
+		if prefix == "" {
+			prefix = template.Name
+		}
+		for i, r := range ranges {
+			title := prefix
+			if len(ranges) > 1 {
+				title = fmt.Sprintf("%s %d", prefix, i+1)
+			}
+			_, err := client.CreateMilestone(ctx, project, map[string]interface{}{
+				"title":      title,
+				"start_date": r[0].Format("2006-01-02"),
+				"due_date":   r[1].Format("2006-01-02"),
+			})
+			if err != nil {
+				report.fail("веха %q: %s", title, gitlab.Message(err))
+				continue
+			}
+			report.Milestones++
+		}
+	}
+
+	// Все файлы шаблона добавляются одним коммитом
+	if len(config.Files) > 0 {
+		actions := make([]gitlab.CommitAction, 0, len(config.Files))
+		for _, file := range config.Files {
+			actions = append(actions, gitlab.CommitAction{Action: "create", FilePath: file.Path, Content: file.Content})
+		}
+		_, err := client.CreateCommit(ctx, project, branch, "Добавлены шаблоны задач и мердж-реквестов", actions)
+		if err != nil {
+			report.fail("файлы шаблона: %s", gitlab.Message(err))
+		} else {
+			report.Files = len(actions)
+		}
+	}
+
+	app.infoLog.Printf("Шаблон %q применен к проекту %d: меток %d, колонок %d, вех %d, файлов %d, ошибок %d",
+		template.Name, projectID, report.Labels, report.BoardLists, report.Milestones, report.Files, len(report.Errors))
+	return report
+}
+
+// applyTemplateBoard создает колонки доски по меткам. Используется доска проекта
+// по умолчанию; если ее нет, создается новая.
+func (app *application) applyTemplateBoard(ctx context.Context, client *gitlab.Client, project string, config *models.TemplateBoard, labelIDs map[string]int, report *templateReport) {
+	// Метки, которые уже были в проекте, ищем по названию
+	for _, name := range config.Lists {
+		if _, ok := labelIDs[name]; !ok {
+			labels, err := client.ListLabels(ctx, project)
+			if err != nil {
+				report.fail("доска: %s", gitlab.Message(err))
+				return
+			}
+			for _, label := range labels {
+				labelIDs[label.Name] = label.ID
+			}
+			break
+		}
+	}
+
+	boards, err := client.ListProjectBoards(ctx, project)
+	if err != nil {
+		report.fail("доска: %s", gitlab.Message(err))
+		return
+	}
+	var board *gitlab.Board
+	if len(boards) > 0 {
+		board = &boards[0]
+	} else {
+		name := config.Name
+		if name == "" {
+			name = "Development"
+		}
+		board, err = client.CreateProjectBoard(ctx, project, name)
+		if err != nil {
+			report.fail("доска: %s", gitlab.Message(err))
+			return
+		}
+	}
+	report.BoardID = board.ID
+
+	existing := map[int]bool{}
+	for _, list := range board.Lists {
+		if list.Label != nil {
+			existing[list.Label.ID] = true
+		}
+	}
+
+	for _, name := range config.Lists {
+		labelID, ok := labelIDs[name]
+		if !ok {
+			report.fail("колонка %q: метка не найдена", name)
+			continue
+		}
+		if existing[labelID] {
+			continue
+		}
+		if _, err := client.CreateBoardList(ctx, project, board.ID, labelID); err != nil {
+			report.fail("колонка %q: %s", name, gitlab.Message(err))
+			continue
+		}
+		report.BoardLists++
+	}
+}
+
+// milestoneRanges нарезает период проекта на отрезки по days дней;
+// при days <= 0 возвращает один отрезок на весь период
+func milestoneRanges(startDate, endDate string, days int) ([][2]time.Time, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("неверная дата начала проекта %q", startDate)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("неверная дата окончания проекта %q", endDate)
+	}
+	if end.Before(start) {
+		return nil, errors.New("дата окончания проекта раньше даты начала")
+	}
+
+	if days <= 0 {
+		return [][2]time.Time{{start, end}}, nil
+	}
+
+	var ranges [][2]time.Time
+	for from := start; !from.After(end); from = from.AddDate(0, 0, days) {
+		to := from.AddDate(0, 0, days-1)
+		if to.After(end) {
+			to = end
+		}
+		ranges = append(ranges, [2]time.Time{from, to})
+	}
+	return ranges, nil
+}
+
+// ProjectTemplateRequest — тело запроса создания и изменения шаблона проекта
+type ProjectTemplateRequest struct {
+	Name        string                       `json:"name" binding:"required"`
+	Description string                       `json:"description"`
+	Config      models.ProjectTemplateConfig `json:"config"`
+}
+
+// validate проверяет название, метки, вехи и пути файлов шаблона
+func (r *ProjectTemplateRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("название шаблона не может быть пустым")
+	}
+	for _, label := range r.Config.Labels {
+		if strings.TrimSpace(label.Name) == "" {
+			return errors.New("название метки не может быть пустым")
+		}
+		if len(label.Color) != 7 || label.Color[0] != '#' {
+			return fmt.Errorf("цвет метки %q должен быть в формате #RRGGBB", label.Name)
+		}
+	}
+	if m := r.Config.Milestones; m != nil && m.DurationDays < 0 {
+		return errors.New("длительность вехи не может быть отрицательной")
+	}
+	for _, file := range r.Config.Files {
+		if file.Path == "" || strings.HasPrefix(file.Path, "/") || strings.Contains(file.Path, "..") {
+			return fmt.Errorf("недопустимый путь файла %q", file.Path)
+		}
+	}
+	return nil
+}
+
+func templateIDParam(c *gin.Context) (int, bool) {
+	templateID, err := strconv.Atoi(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID шаблона"})
+		return 0, false
+	}
+	return templateID, true
+}
+
+// getProjectTemplates возвращает все шаблоны проектов
+func (app *application) getProjectTemplates(c *gin.Context) {
+	templates, err := app.models.GetProjectTemplates()
+	if err != nil {
+		app.errorLog.Printf("Ошибка получения шаблонов проектов: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить шаблоны проектов"})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// getProjectTemplate возвращает шаблон проекта
+func (app *application) getProjectTemplate(c *gin.Context) {
+	templateID, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	template, err := app.models.GetProjectTemplate(templateID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+			return
+		}
+		app.errorLog.Printf("Ошибка получения шаблона %d: %v", templateID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить шаблон проекта"})
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// createProjectTemplate создает шаблон проекта (право manage_templates)
+func (app *application) createProjectTemplate(c *gin.Context) {
+	var req ProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неверный формат данных: %v", err)})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := &models.ProjectTemplate{Name: req.Name, Description: req.Description, Config: req.Config}
+	if err := app.models.CreateProjectTemplate(template); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Шаблон с таким названием уже существует"})
+			return
+		}
+		app.errorLog.Printf("Ошибка создания шаблона проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать шаблон проекта"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// updateProjectTemplate изменяет шаблон проекта (право manage_templates)
+func (app *application) updateProjectTemplate(c *gin.Context) {
+	templateID, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неверный формат данных: %v", err)})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := &models.ProjectTemplate{ID: templateID, Name: req.Name, Description: req.Description, Config: req.Config}
+	if err := app.models.UpdateProjectTemplate(template); err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		case errors.Is(err, models.ErrDuplicate):
+			c.JSON(http.StatusConflict, gin.H{"error": "Шаблон с таким названием уже существует"})
+		default:
+			app.errorLog.Printf("Ошибка изменения шаблона %d: %v", templateID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось изменить шаблон проекта"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// deleteProjectTemplate удаляет шаблон проекта (право manage_templates)
+func (app *application) deleteProjectTemplate(c *gin.Context) {
+	templateID, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.DeleteProjectTemplate(templateID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+			return
+		}
+		app.errorLog.Printf("Ошибка удаления шаблона %d: %v", templateID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить шаблон проекта"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Шаблон удален"})
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// errWebhookURLNotConfigured возвращается, если не задан публичный адрес вебхука
+var errWebhookURLNotConfigured = errors.New("адрес вебхука не настроен (-webhook-url)")
+
+// generateWebhookSecret создает случайный секрет, который GitLab передает в X-Gitlab-Token
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать секрет вебхука: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// webhookHookOptions — параметры вебхука: push, MR, задачи, комментарии и пайплайны
+func webhookHookOptions(hookURL, secret string) map[string]interface{} {
+	return map[string]interface{}{
+		"url":                     hookURL,
+		"token":                   secret,
+		"push_events":             true,
+		"merge_requests_events":   true,
+		"issues_events":           true,
+		"note_events":             true,
+		"pipeline_events":         true,
+		"enable_ssl_verification": strings.HasPrefix(hookURL, "https://"),
+	}
+}
+
+// registerProjectWebhook регистрирует вебхук приложения в проекте GitLab.
+// Если вебхук уже зарегистрирован и существует в GitLab, возвращает его без изменений.
+func (app *application) registerProjectWebhook(ctx context.Context, client *gitlab.Client, projectID int) (*models.ProjectWebhook, error) {
+	if app.webhookURL == "" {
+		return nil, errWebhookURLNotConfigured
+	}
+
+	existing, err := app.models.GetProjectWebhook(projectID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return nil, err
+	}
+	if existing != nil {
+		_, err := client.GetProjectHook(ctx, strconv.Itoa(projectID), existing.HookID)
+		if err == nil {
+			return existing, nil
+		}
+		if !errors.Is(err, gitlab.ErrNotFound) {
+			return nil, err
+		}
+		// Вебхук удалили в GitLab вручную — регистрируем заново
+		app.infoLog.Printf("Вебхук %d проекта %d не найден в GitLab, регистрируем заново", existing.HookID, projectID)
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := client.AddProjectHook(ctx, strconv.Itoa(projectID), webhookHookOptions(app.webhookURL, secret))
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.ProjectWebhook{
+		ProjectID: projectID,
+		HookID:    hook.ID,
+		URL:       hook.URL,
+		Secret:    secret,
+	}
+	if err := app.saveProjectWebhook(webhook); err != nil {
+		// Без сохраненного секрета вебхук бесполезен, удаляем его
+		if delErr := client.DeleteProjectHook(ctx, strconv.Itoa(projectID), hook.ID); delErr != nil {
+			app.errorLog.Printf("Не удалось удалить вебхук %d проекта %d: %v", hook.ID, projectID, delErr)
+		}
+		return nil, err
+	}
+
+	app.infoLog.Printf("Зарегистрирован вебхук %d для проекта %d", hook.ID, projectID)
+	return webhook, nil
+}
+
+// verifyWebhookToken сверяет X-Gitlab-Token с секретом вебхука проекта. Вебхуки,
+// установленные вручную (без зарегистрированного приложением вебхука), сверяются
+// с общим секретом webhook-secret; если он не задан, такие запросы отклоняются.
+func (app *application) verifyWebhookToken(projectID int, token string) (bool, error) {
+	secret := app.webhookSecret
+	webhook, err := app.models.GetProjectWebhook(projectID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return false, err
+	}
+	if webhook != nil {
+		if secret, err = app.tokenCipher.Decrypt(webhook.SecretEncrypted); err != nil {
+			return false, fmt.Errorf("не удалось расшифровать секрет вебхука проекта %d: %w", projectID, err)
+		}
+	}
+	if secret == "" || token == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1, nil
+}
+
+// saveProjectWebhook шифрует секрет вебхука и сохраняет вебхук
+func (app *application) saveProjectWebhook(webhook *models.ProjectWebhook) error {
+	encrypted, err := app.tokenCipher.Encrypt(webhook.Secret)
+	if err != nil {
+		return fmt.Errorf("не удалось зашифровать секрет вебхука: %w", err)
+	}
+	webhook.SecretEncrypted = encrypted
+	return app.models.SaveProjectWebhook(webhook)
+}
+
+// encryptPlaintextWebhookSecrets шифрует секреты вебхуков, сохраненные в открытом виде
+func (app *application) encryptPlaintextWebhookSecrets() error {
+	secrets, err := app.models.GetPlaintextWebhookSecrets()
+	if err != nil {
+		return err
+	}
+	for projectID, secret := range secrets {
+		encrypted, err := app.tokenCipher.Encrypt(secret)
+		if err != nil {
+			return fmt.Errorf("проект %d: %w", projectID, err)
+		}
+		if err := app.models.ReplacePlaintextWebhookSecret(projectID, secret, encrypted); err != nil {
+			return err
+		}
+	}
+	if len(secrets) > 0 {
+		app.infoLog.Printf("Открытые секреты вебхуков зашифрованы: %d", len(secrets))
+	}
+	return nil
+}
+
+// projectIDParam читает числовой ID проекта GitLab из пути
+func projectIDParam(c *gin.Context) (int, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || projectID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID проекта"})
+		return 0, false
+	}
+	return projectID, true
+}
+
+// respondWebhookError отправляет ошибку работы с вебхуком
+func (app *application) respondWebhookError(c *gin.Context, err error) {
+	if errors.Is(err, errWebhookURLNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Адрес вебхука не настроен на сервере"})
+		return
+	}
+	app.respondError(c, err, "Ошибка работы с вебхуком")
+}
+
+// RegisterProjectWebhook подключает существующий проект GitLab: регистрирует вебхук приложения
+func (h *OAuthHandler) RegisterProjectWebhook(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.app.registerProjectWebhook(c.Request.Context(), client, projectID)
+	if err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+// VerifyProjectWebhook проверяет, что вебхук существует в GitLab, указывает на нас
+// и подписан на все нужные события
+func (h *OAuthHandler) VerifyProjectWebhook(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.app.models.GetProjectWebhook(projectID)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вебхук для проекта не зарегистрирован"})
+		return
+	}
+	if err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	hook, err := client.GetProjectHook(c.Request.Context(), strconv.Itoa(projectID), webhook.HookID)
+	if errors.Is(err, gitlab.ErrNotFound) {
+		c.JSON(http.StatusOK, gin.H{
+			"webhook":  webhook,
+			"ok":       false,
+			"problems": []string{"вебхук удален в GitLab"},
+		})
+		return
+	}
+	if err != nil {
+		h.app.respondGitLabError(c, err)
+		return
+	}
+
+	var problems []string
+	if h.app.webhookURL != "" && hook.URL != h.app.webhookURL {
+		problems = append(problems, fmt.Sprintf("вебхук указывает на %s вместо %s", hook.URL, h.app.webhookURL))
+	}
+	events := map[string]bool{
+		"push_events":           hook.PushEvents,
+		"merge_requests_events": hook.MergeRequestsEvents,
+		"issues_events":         hook.IssuesEvents,
+		"note_events":           hook.NoteEvents,
+		"pipeline_events":       hook.PipelineEvents,
+	}
+	for _, name := range []string{"push_events", "merge_requests_events", "issues_events", "note_events", "pipeline_events"} {
+		if !events[name] {
+			problems = append(problems, "отключены события "+name)
+		}
+	}
+	if hook.AlertStatus != "" && hook.AlertStatus != "executable" {
+		problems = append(problems, "GitLab отключил вебхук из-за ошибок доставки: "+hook.AlertStatus)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook":        webhook,
+		"ok":             len(problems) == 0,
+		"problems":       problems,
+		"alert_status":   hook.AlertStatus,
+		"disabled_until": hook.DisabledUntil,
+	})
+}
+
+// RotateProjectWebhookSecret меняет секрет вебхука в GitLab и у нас.
+// Заодно восстанавливает адрес и набор событий, если их изменили вручную.
+func (h *OAuthHandler) RotateProjectWebhookSecret(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.app.models.GetProjectWebhook(projectID)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вебхук для проекта не зарегистрирован"})
+		return
+	}
+	if err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	hookURL := webhook.URL
+	if h.app.webhookURL != "" {
+		hookURL = h.app.webhookURL
+	}
+
+	hook, err := client.EditProjectHook(c.Request.Context(), strconv.Itoa(projectID), webhook.HookID, webhookHookOptions(hookURL, secret))
+	if err != nil {
+		h.app.respondGitLabError(c, err)
+		return
+	}
+
+	webhook.URL = hook.URL
+	webhook.Secret = secret
+	if err := h.app.saveProjectWebhook(webhook); err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	h.app.infoLog.Printf("Секрет вебхука %d проекта %d обновлен", webhook.HookID, projectID)
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+// DeleteProjectWebhookHandler удаляет вебхук приложения из проекта GitLab
+func (h *OAuthHandler) DeleteProjectWebhookHandler(c *gin.Context) {
+	client, ok := h.app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.app.models.GetProjectWebhook(projectID)
+	if errors.Is(err, models.ErrNoRecord) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вебхук для проекта не зарегистрирован"})
+		return
+	}
+	if err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	// Уже удаленный в GitLab вебхук не считается ошибкой
+	err = client.DeleteProjectHook(c.Request.Context(), strconv.Itoa(projectID), webhook.HookID)
+	if err != nil && !errors.Is(err, gitlab.ErrNotFound) {
+		h.app.respondGitLabError(c, err)
+		return
+	}
+
+	if err := h.app.models.DeleteProjectWebhook(projectID); err != nil {
+		h.app.respondWebhookError(c, err)
+		return
+	}
+
+	h.app.infoLog.Printf("Вебхук %d проекта %d удален", webhook.HookID, projectID)
+	c.JSON(http.StatusOK, gin.H{"message": "Вебхук удален"})
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// newProject — данные для создания проекта в GitLab и локальной базе
+type newProject struct {
+	Title        string
+	Description  string
+	StartDate    string // YYYY-MM-DD
+	EndDate      string // YYYY-MM-DD
+	OwnerID      *int   // локальный пользователь-владелец
+	Participants []int
+	// GitLabOptions передаются в POST /projects как есть
+	GitLabOptions map[string]interface{}
+}
+
+// validateProjectDates проверяет даты проекта в формате YYYY-MM-DD
+func validateProjectDates(startDate, endDate string) error {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return fmt.Errorf("дата начала должна быть в формате ГГГГ-ММ-ДД")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return fmt.Errorf("дата окончания должна быть в формате ГГГГ-ММ-ДД")
+	}
+	if end.Before(start) {
+		return errors.New("дата окончания раньше даты начала")
+	}
+	return nil
+}
+
+// createProject создает проект одним согласованным сценарием:
+//  1. локальная запись со статусом creating и участники;
+//  2. проект в GitLab — при ошибке локальная запись удаляется;
+//  3. связь записи с GitLab и статус active — при ошибке удаляется и проект GitLab.
+func (app *application) createProject(ctx context.Context, client *gitlab.Client, input newProject) (int, *gitlab.Project, error) {
+	owner, err := client.CurrentUser(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	prjID, rollbackLocal, err := app.insertLocalProject(input)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	gitlabProject, err := client.CreateProject(ctx, input.GitLabOptions)
+	if err != nil {
+		app.errorLog.Printf("Проект %q не создан в GitLab, локальная запись %d удаляется: %v", input.Title, prjID, err)
+		rollbackLocal()
+		return 0, nil, err
+	}
+
+	if err := app.models.AttachGitLabProject(prjID, gitlabProject.ID, owner.ID, gitlabProject.WebURL); err != nil {
+		app.errorLog.Printf("Проект GitLab %d не связан с локальной записью %d, удаляем его: %v", gitlabProject.ID, prjID, err)
+		// Контекст запроса мог быть отменен, компенсацию выполняем независимо от него
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), gitlab.DefaultTimeout)
+		defer cancel()
+		if delErr := client.DeleteProject(cleanupCtx, strconv.Itoa(gitlabProject.ID)); delErr != nil {
+			app.errorLog.Printf("Компенсация: не удалось удалить проект GitLab %d: %v", gitlabProject.ID, delErr)
+		}
+		rollbackLocal()
+		return 0, nil, err
+	}
+
+	app.infoLog.Printf("Создан проект %q: локальный ID %d, GitLab ID %d", input.Title, prjID, gitlabProject.ID)
+	return prjID, gitlabProject, nil
+}
+
+// insertLocalProject создает локальную запись проекта со статусом creating и участников.
+// Возвращаемая функция удаляет запись, если проект не удалось довести до конца.
+func (app *application) insertLocalProject(input newProject) (int, func(), error) {
+	prjID, err := app.models.CreateProject(input.Title, input.Description, input.StartDate, input.EndDate, models.ProjectStatusCreating, input.OwnerID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rollback := func() {
+		if err := app.models.DeleteProjectWithParticipants(prjID); err != nil {
+			app.errorLog.Printf("Компенсация: не удалось удалить локальный проект %d: %v", prjID, err)
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, userID := range input.Participants {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		if err := app.models.AddUsersProjects(prjID, userID); err != nil {
+			rollback()
+			return 0, nil, err
+		}
+	}
+
+	return prjID, rollback, nil
+}
+
+// requireProjectMaintainer пропускает пользователей с правами Maintainer и выше в проекте
+// и администраторов GitLab. Возвращает проект и текущего пользователя.
+func (app *application) requireProjectMaintainer(c *gin.Context, client *gitlab.Client, projectRef string) (*gitlab.Project, *gitlab.User, bool) {
+	user, err := client.CurrentUser(c.Request.Context())
+	if err != nil {
+		app.respondGitLabError(c, err)
+		return nil, nil, false
+	}
+
+	project, err := client.GetProject(c.Request.Context(), projectRef)
+	if err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден в GitLab или нет доступа"})
+			return nil, nil, false
+		}
+		app.respondGitLabError(c, err)
+		return nil, nil, false
+	}
+
+	if !user.IsAdmin && project.AccessLevel() < gitlab.MaintainerAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Нужны права Maintainer в проекте GitLab"})
+		return nil, nil, false
+	}
+	return project, user, true
+}
+
+// LinkProjectRequest — тело запроса подключения существующего проекта GitLab
+type LinkProjectRequest struct {
+	ProjectID    int    `json:"project_id"`   // ID проекта в GitLab
+	ProjectPath  string `json:"project_path"` // или путь вида group/project
+	StartDate    string `json:"start_date"`   // по умолчанию — сегодня
+	EndDate      string `json:"end_date"`     // по умолчанию — через три месяца после начала
+	OwnerID      *int   `json:"owner_id"`
+	Participants []int  `json:"participants"`
+	ImportIssues bool   `json:"import_issues"` // добавить открытые задачи в бэклог
+}
+
+// linkProject подключает существующий проект GitLab: создает локальную запись,
+// регистрирует вебхук и при необходимости переносит открытые задачи в бэклог
+func (app *application) linkProject(c *gin.Context) {
+	client, ok := app.gitlabClient(c)
+	if !ok {
+		return
+	}
+
+	var req LinkProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неверный формат данных: %v", err)})
+		return
+	}
+
+	projectRef := strings.TrimSpace(req.ProjectPath)
+	if req.ProjectID > 0 {
+		projectRef = strconv.Itoa(req.ProjectID)
+	}
+	if projectRef == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите project_id или project_path"})
+		return
+	}
+
+	if req.StartDate == "" {
+		req.StartDate = time.Now().Format("2006-01-02")
+	}
+	if req.EndDate == "" {
+		if start, err := time.Parse("2006-01-02", req.StartDate); err == nil {
+			req.EndDate = start.AddDate(0, 3, 0).Format("2006-01-02")
+		}
+	}
+	if err := validateProjectDates(req.StartDate, req.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	gitlabProject, user, ok := app.requireProjectMaintainer(c, client, projectRef)
+	if !ok {
+		return
+	}
+
+	_, err := app.models.GetProjectByGitLabID(gitlabProject.ID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Проект уже подключен"})
+		return
+	}
+	if !errors.Is(err, models.ErrNoRecord) {
+		app.respondError(c, err, "Не удалось проверить проект")
+		return
+	}
+
+	prjID, rollbackLocal, err := app.insertLocalProject(newProject{
+		Title:        gitlabProject.Name,
+		Description:  gitlabProject.Description,
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+		OwnerID:      req.OwnerID,
+		Participants: req.Participants,
+	})
+	if err != nil {
+		app.respondError(c, err, "Не удалось сохранить проект")
+		return
+	}
+	if err := app.models.AttachGitLabProject(prjID, gitlabProject.ID, user.ID, gitlabProject.WebURL); err != nil {
+		rollbackLocal()
+		app.respondError(c, err, "Не удалось сохранить проект")
+		return
+	}
+
+	app.infoLog.Printf("Подключен проект GitLab %d (%s), локальный ID %d", gitlabProject.ID, gitlabProject.PathWithNamespace, prjID)
+
+	response := gin.H{
+		"message": "Проект подключен",
+		"project": gin.H{
+			"id":                  prjID,
+			"gitlab_id":           gitlabProject.ID,
+			"name":                gitlabProject.Name,
+			"path_with_namespace": gitlabProject.PathWithNamespace,
+			"web_url":             gitlabProject.WebURL,
+			"start_date":          req.StartDate,
+			"end_date":            req.EndDate,
+		},
+	}
+
+	// Проект уже подключен, поэтому ошибки вебхука и импорта только сообщаем
+	webhook, err := app.registerProjectWebhook(c.Request.Context(), client, gitlabProject.ID)
+	if err != nil {
+		app.errorLog.Printf("Не удалось зарегистрировать вебхук проекта %d: %v", gitlabProject.ID, err)
+		response["webhook_error"] = err.Error()
+	} else {
+		response["webhook"] = webhook
+	}
+
+	if req.ImportIssues {
+		imported, err := app.importOpenIssues(c.Request.Context(), client, gitlabProject.ID)
+		if err != nil {
+			app.errorLog.Printf("Ошибка импорта задач проекта %d: %v", gitlabProject.ID, err)
+			response["import_error"] = err.Error()
+		}
+		response["imported_issues"] = imported
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// importOpenIssues переносит открытые задачи проекта GitLab в бэклог
+func (app *application) importOpenIssues(ctx context.Context, client *gitlab.Client, projectID int) (int, error) {
+	issues, err := client.ListProjectIssues(ctx, strconv.Itoa(projectID), url.Values{"state": {"opened"}})
+	if err != nil {
+		return 0, err
+	}
+
+	backlog := make([]models.BacklogIssue, 0, len(issues))
+	for _, issue := range issues {
+		backlog = append(backlog, models.BacklogIssue{
+			ProjectID:   projectID,
+			IssueID:     issue.IID,
+			Title:       issue.Title,
+			Description: issue.Description,
+			Labels:      issue.Labels,
+			Weight:      issue.Weight,
+		})
+	}
+	return app.models.AddBacklogIssues(backlog)
+}
+
+// unlinkProject удаляет данные плагина о проекте, не трогая сам проект в GitLab.
+// С параметром remove_webhook=true из GitLab удаляется и вебхук плагина.
+func (app *application) unlinkProject(c *gin.Context) {
+	client, ok := app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	// Проект, удаленный в GitLab, может отключить только тот, кому разрешено удалять проекты
+	_, err := client.GetProject(c.Request.Context(), strconv.Itoa(projectID))
+	if errors.Is(err, gitlab.ErrNotFound) {
+		if !app.allow(c, permDeleteProjects) {
+			return
+		}
+	} else if _, _, ok := app.requireProjectMaintainer(c, client, strconv.Itoa(projectID)); !ok {
+		return
+	}
+
+	if c.Query("remove_webhook") == "true" {
+		webhook, err := app.models.GetProjectWebhook(projectID)
+		if err == nil {
+			err = client.DeleteProjectHook(c.Request.Context(), strconv.Itoa(projectID), webhook.HookID)
+		}
+		if err != nil && !errors.Is(err, models.ErrNoRecord) && !errors.Is(err, gitlab.ErrNotFound) {
+			app.respondError(c, err, "Не удалось удалить вебхук")
+			return
+		}
+	}
+
+	if err := app.models.UnlinkProject(projectID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Проект не подключен"})
+			return
+		}
+		app.respondError(c, err, "Не удалось отключить проект")
+		return
+	}
+
+	app.infoLog.Printf("Проект GitLab %d отключен", projectID)
+	c.JSON(http.StatusOK, gin.H{"message": "Проект отключен"})
+}
+
+// getBacklog возвращает бэклог проекта GitLab
+func (app *application) getBacklog(c *gin.Context) {
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	issues, err := app.models.GetBacklogIssues(projectID)
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить бэклог")
+		return
+	}
+	c.JSON(http.StatusOK, issues)
+}
+
+// respondError отправляет ошибку GitLab как есть, остальные ошибки — как внутренние
+func (app *application) respondError(c *gin.Context, err error, message string) {
+	var gitlabErr *gitlab.Error
+	var circuitErr *gitlab.CircuitOpenError
+	if errors.As(err, &gitlabErr) || errors.As(err, &circuitErr) {
+		app.respondGitLabError(c, err)
+		return
+	}
+	app.errorLog.Printf("%s: %v", message, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// getProjects возвращает локальные проекты с датами, статусом и владельцем
+func (app *application) getProjects(c *gin.Context) {
+	projects, err := app.models.GetProjects()
+	if err != nil {
+		app.respondError(c, err, "Не удалось получить проекты")
+		return
+	}
+	if projects == nil {
+		projects = []models.Project{}
+	}
+	c.JSON(http.StatusOK, projects)
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// sessionCookieName — имя HttpOnly куки с идентификатором сессии
+const sessionCookieName = "plaginagile_session"
+
+// Ключи контекста gin, в которые middleware помещает данные сессии
+const (
+	contextKeySession = "session"
+	contextKeyUser    = "user"
+)
+
+// sessionCleanupInterval — периодичность удаления истекших сессий
+const sessionCleanupInterval = time.Hour
+
+// tokenRefreshMargin — токен GitLab обновляется заранее, за это время до истечения,
+// чтобы он не истек во время обращения к GitLab
+const tokenRefreshMargin = 2 * time.Minute
+
+// reauthPath — адрес, с которого фронтенд начинает повторный вход через GitLab
+const reauthPath = "/api/gitlab/auth"
+
+// errSessionExpired возвращается, если токен GitLab сессии больше нельзя обновить
+// (refresh-токен отозван или истек) и пользователю нужно войти заново
+var errSessionExpired = errors.New("сессия GitLab истекла, войдите снова")
+
+// errPersonalTokenNoGitLab возвращается для запросов по персональному токену: у токена
+// нет учетных данных GitLab владельца, поэтому он дает доступ только к данным плагина
+var errPersonalTokenNoGitLab = errors.New("персональный токен не дает доступа к GitLab, войдите через GitLab")
+
+// errGitLabNotLinked возвращается для сессии входа по паролю: у нее нет токена GitLab
+// пользователя, а сервисный токен обошел бы права пользователя в GitLab
+var errGitLabNotLinked = errors.New("свяжите учетную запись с GitLab и войдите через GitLab, чтобы работать с GitLab")
+
+// sessionLocks не дает нескольким одновременным запросам одной сессии обновлять токен
+// параллельно: GitLab выдает новый refresh-токен, и второй запрос получил бы отказ
+var sessionLocks = &keyedMutex{locks: map[string]*refMutex{}}
+
+// keyedMutex выдает блокировку по ключу. Блокировка удаляется, когда ее никто
+// не держит и не ждет, поэтому все запросы одной сессии получают один мьютекс.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int // сколько запросов держат или ждут блокировку
+}
+
+// Lock захватывает блокировку ключа и возвращает функцию ее освобождения
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &refMutex{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		k.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// sessionUser — пользователь запроса. ID — пользователь GitLab (0 у локального
+// пользователя, не связанного с GitLab), LocalID — пользователь таблицы users
+// при локальном входе.
+type sessionUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	LocalID  int    `json:"local_id,omitempty"`
+}
+
+// newSessionUser возвращает пользователя сессии
+func newSessionUser(session *models.Session) *sessionUser {
+	user := &sessionUser{
+		ID:       session.UserID,
+		Username: session.Username,
+		Name:     session.Name,
+		Email:    session.Email,
+	}
+	if session.LocalUserID != nil {
+		user.LocalID = *session.LocalUserID
+	}
+	return user
+}
+
+// tokenCipher шифрует токены GitLab перед сохранением в базе (AES-256-GCM)
+type tokenCipher struct {
+	aead cipher.AEAD
+}
+
+func newTokenCipher(key []byte) (*tokenCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenCipher{aead: aead}, nil
+}
+
+// Encrypt возвращает nonce и шифротекст одним срезом; пустая строка не шифруется
+func (tc *tokenCipher) Encrypt(plaintext string) ([]byte, error) {
+	if plaintext == "" {
+		return nil, nil
+	}
+	nonce := make([]byte, tc.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return tc.aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+// Decrypt расшифровывает значение, полученное от Encrypt
+func (tc *tokenCipher) Decrypt(ciphertext []byte) (string, error) {
+	if len(ciphertext) == 0 {
+		return "", nil
+	}
+	size := tc.aead.NonceSize()
+	if len(ciphertext) < size {
+		return "", errors.New("поврежденный шифротекст")
+	}
+	plaintext, err := tc.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// newSessionToken генерирует значение куки сессии и идентификатор для базы.
+// В базе хранится только хэш, поэтому утечка таблицы не раскрывает действующие куки.
+func newSessionToken() (string, string, error) {
+	token, err := randomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, hashSessionToken(token), nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession создает сессию для пользователя GitLab и устанавливает куку
+func (app *application) startSession(c *gin.Context, user *gitlab.User, token *gitlab.Token) error {
+	cookieValue, id, err := newSessionToken()
+	if err != nil {
+		return fmt.Errorf("не удалось создать идентификатор сессии: %w", err)
+	}
+
+	session := &models.Session{
+		ID:        id,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		Email:     user.Email,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: time.Now().Add(app.sessionTTL),
+	}
+	if session.AccessTokenEncrypted, err = app.tokenCipher.Encrypt(token.AccessToken); err != nil {
+		return fmt.Errorf("не удалось зашифровать токен: %w", err)
+	}
+	if session.RefreshTokenEncrypted, err = app.tokenCipher.Encrypt(token.RefreshToken); err != nil {
+		return fmt.Errorf("не удалось зашифровать токен: %w", err)
+	}
+	session.TokenExpiresAt = tokenExpiresAt(token)
+
+	if err := app.models.CreateSession(session); err != nil {
+		return err
+	}
+
+	app.setCookie(c, sessionCookieName, cookieValue, int(app.sessionTTL.Seconds()))
+	return nil
+}
+
+// tokenExpiresAt вычисляет момент истечения токена GitLab; nil — токен бессрочный
+func tokenExpiresAt(token *gitlab.Token) *time.Time {
+	if token.ExpiresIn <= 0 {
+		return nil
+	}
+	issued := time.Now()
+	if token.CreatedAt > 0 {
+		issued = time.Unix(token.CreatedAt, 0)
+	}
+	expiresAt := issued.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return &expiresAt
+}
+
+// setCookie устанавливает HttpOnly куку; флаг Secure задается конфигурацией
+func (app *application) setCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, "/", "", app.cookieSecure, true)
+}
+
+// sessionMiddleware находит сессию по куке и помещает сессию и пользователя
+// в контекст запроса. Запросы без сессии пропускаются дальше: обязательность
+// входа проверяет requireSession.
+func (app *application) sessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cookie, err := c.Cookie(sessionCookieName)
+		if err != nil || cookie == "" {
+			c.Next()
+			return
+		}
+
+		session, err := app.models.GetSession(hashSessionToken(cookie))
+		if err != nil {
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.errorLog.Printf("Ошибка получения сессии: %v", err)
+			}
+			// Недействительную куку убираем, чтобы браузер не присылал ее снова
+			app.setCookie(c, sessionCookieName, "", -1)
+			c.Next()
+			return
+		}
+
+		// Время последнего использования обновляем не чаще раза в минуту
+		if time.Since(session.LastSeenAt) > time.Minute {
+			if err := app.models.TouchSession(session.ID); err != nil {
+				app.errorLog.Printf("%v", err)
+			}
+		}
+
+		c.Set(contextKeySession, session)
+		c.Set(contextKeyUser, newSessionUser(session))
+		c.Next()
+	}
+}
+
+// requireSession отклоняет запросы без действующей сессии
+func (app *application) requireSession(c *gin.Context) {
+	if _, ok := currentUser(c); !ok {
+		app.respondReauth(c, "Требуется вход")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// respondReauth отвечает 401 с адресом повторного входа через GitLab
+func (app *application) respondReauth(c *gin.Context, message string) {
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":      message,
+		"reauth_url": reauthPath,
+	})
+}
+
+// endSession удаляет сессию текущего запроса и ее куку
+func (app *application) endSession(c *gin.Context) {
+	if session, ok := currentSession(c); ok {
+		if err := app.models.DeleteSession(session.ID); err != nil {
+			app.errorLog.Printf("%v", err)
+		}
+		c.Set(contextKeySession, nil)
+		c.Set(contextKeyUser, nil)
+	}
+	app.setCookie(c, sessionCookieName, "", -1)
+}
+
+// currentSession возвращает сессию текущего запроса
+func currentSession(c *gin.Context) (*models.Session, bool) {
+	value, ok := c.Get(contextKeySession)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*models.Session)
+	return session, ok && session != nil
+}
+
+// currentUser возвращает пользователя текущей сессии
+func currentUser(c *gin.Context) (*sessionUser, bool) {
+	value, ok := c.Get(contextKeyUser)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*sessionUser)
+	return user, ok && user != nil
+}
+
+// requestGitLabToken возвращает токен GitLab сессии пользователя (из куки или JWT),
+// обновляя его при необходимости. Если сессию продлить нельзя, она завершается
+// и возвращается errSessionExpired. Для персонального токена возвращается
+// errPersonalTokenNoGitLab, для сессии локального входа без токена GitLab —
+// errGitLabNotLinked.
+func (app *application) requestGitLabToken(c *gin.Context) (string, error) {
+	if _, ok := currentPersonalToken(c); ok {
+		return "", errPersonalTokenNoGitLab
+	}
+	session, ok := currentSession(c)
+	if !ok {
+		return "", nil
+	}
+	if session.LocalUserID != nil && len(session.AccessTokenEncrypted) == 0 {
+		return "", errGitLabNotLinked
+	}
+
+	token, err := app.sessionGitLabToken(c.Request.Context(), session)
+	if errors.Is(err, errSessionExpired) {
+		app.infoLog.Printf("Сессия пользователя %s завершена: %v", session.Username, err)
+		app.endSession(c)
+	}
+	return token, err
+}
+
+// sessionGitLabToken возвращает токен доступа сессии. Если токен скоро истечет,
+// он обновляется через refresh-токен до обращения к GitLab.
+func (app *application) sessionGitLabToken(ctx context.Context, session *models.Session) (string, error) {
+	if !tokenNeedsRefresh(session) {
+		return app.decryptSessionToken(session.AccessTokenEncrypted)
+	}
+
+	unlock := sessionLocks.Lock(session.ID)
+	defer unlock()
+
+	// Пока ждали блокировку, токен мог обновить другой запрос этой сессии
+	fresh, err := app.models.GetSession(session.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return "", errSessionExpired
+	}
+	if err != nil {
+		return "", err
+	}
+	if !tokenNeedsRefresh(fresh) {
+		*session = *fresh
+		return app.decryptSessionToken(fresh.AccessTokenEncrypted)
+	}
+
+	refreshToken, err := app.decryptSessionToken(fresh.RefreshTokenEncrypted)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := app.decryptSessionToken(fresh.AccessTokenEncrypted)
+	if err != nil {
+		return "", err
+	}
+	stillValid := fresh.TokenExpiresAt.After(time.Now())
+	if refreshToken == "" {
+		if stillValid {
+			return accessToken, nil
+		}
+		return "", errSessionExpired
+	}
+
+	form := url.Values{}
+	form.Set("client_id", app.oauthHandler.clientID)
+	form.Set("client_secret", app.oauthHandler.clientSecret)
+	form.Set("refresh_token", refreshToken)
+	form.Set("grant_type", "refresh_token")
+	form.Set("redirect_uri", app.oauthHandler.redirectURI)
+
+	token, err := app.gitlab.ExchangeToken(ctx, form)
+	if err != nil {
+		// invalid_grant: refresh-токен отозван, уже использован или истек
+		if errors.Is(err, gitlab.ErrBadRequest) || errors.Is(err, gitlab.ErrUnauthorized) {
+			return "", errSessionExpired
+		}
+		// GitLab временно недоступен: пока старый токен действует, работаем с ним
+		app.errorLog.Printf("Не удалось обновить токен GitLab пользователя %s: %v", fresh.Username, err)
+		if stillValid {
+			return accessToken, nil
+		}
+		return "", err
+	}
+
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	accessEncrypted, err := app.tokenCipher.Encrypt(token.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	refreshEncrypted, err := app.tokenCipher.Encrypt(token.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := tokenExpiresAt(token)
+	if err := app.models.UpdateSessionTokens(fresh.ID, accessEncrypted, refreshEncrypted, expiresAt); err != nil {
+		return "", err
+	}
+
+	session.AccessTokenEncrypted = accessEncrypted
+	session.RefreshTokenEncrypted = refreshEncrypted
+	session.TokenExpiresAt = expiresAt
+	app.infoLog.Printf("Токен GitLab пользователя %s обновлен", fresh.Username)
+	return token.AccessToken, nil
+}
+
+// tokenNeedsRefresh сообщает, что токен сессии истек или скоро истечет
+func tokenNeedsRefresh(session *models.Session) bool {
+	return session.TokenExpiresAt != nil && time.Until(*session.TokenExpiresAt) < tokenRefreshMargin
+}
+
+// decryptSessionToken расшифровывает токен сессии; поврежденный токен (например,
+// после смены ключа шифрования) означает, что сессию нужно начать заново
+func (app *application) decryptSessionToken(encrypted []byte) (string, error) {
+	token, err := app.tokenCipher.Decrypt(encrypted)
+	if err != nil {
+		app.errorLog.Printf("Не удалось расшифровать токен сессии: %v", err)
+		return "", errSessionExpired
+	}
+	return token, nil
+}
+
+// getSession возвращает пользователя текущей сессии
+func (app *application) getSession(c *gin.Context) {
+	user, ok := currentUser(c)
+	session, hasSession := currentSession(c)
+	if !ok || !hasSession {
+		app.respondReauth(c, "Требуется вход")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":       user,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// cleanupSessions периодически удаляет истекшие сессии
+func (app *application) cleanupSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := app.models.DeleteExpiredOAuthStates(); err != nil {
+				app.errorLog.Printf("%v", err)
+			}
+			if _, err := app.models.DeleteExpiredRefreshTokens(); err != nil {
+				app.errorLog.Printf("%v", err)
+			}
+			if _, err := app.models.DeleteExpiredPasswordResets(); err != nil {
+				app.errorLog.Printf("%v", err)
+			}
+			app.loginLimiter.Cleanup(time.Now())
+
+			removed, err := app.models.DeleteExpiredSessions()
+			if err != nil {
+				app.errorLog.Printf("%v", err)
+				continue
+			}
+			if removed > 0 {
+				app.infoLog.Printf("Удалено истекших сессий: %d", removed)
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// Источники спринтов в GitLab
+const (
+	sprintSourceMilestone = "milestone"
+	sprintSourceIteration = "iteration"
+)
+
+// defaultImportedSprintDays — длительность спринта, если у вехи не указаны даты
+const defaultImportedSprintDays = 14
+
+// ImportSprintsRequest — параметры импорта вех и итераций GitLab
+type ImportSprintsRequest struct {
+	State             string `json:"state"`              // active (по умолчанию) или all
+	IncludeIterations *bool  `json:"include_iterations"` // по умолчанию true
+}
+
+// importedSprint — результат импорта одной вехи или итерации
+type importedSprint struct {
+	SprintID    int    `json:"sprint_id"`
+	Source      string `json:"source"`
+	SourceID    int    `json:"source_id"`
+	Title       string `json:"title"`
+	Created     bool   `json:"created"`
+	IssuesAdded int    `json:"issues_added"`
+}
+
+// sprintSource — веха или итерация GitLab, из которой создается спринт
+type sprintSource struct {
+	kind        string
+	id          int
+	title       string
+	description string
+	startDate   string
+	dueDate     string
+	createdAt   *time.Time
+	closed      bool
+	issues      func(ctx context.Context) ([]gitlab.Issue, error)
+}
+
+// importSprints создает спринты из вех проекта и итераций его группы.
+// Повторный импорт не создает дубликатов: уже импортированные вехи дополняются новыми задачами.
+func (app *application) importSprints(c *gin.Context) {
+	client, ok := app.gitlabClient(c)
+	if !ok {
+		return
+	}
+	projectID, ok := projectIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ImportSprintsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неверный формат данных: %v", err)})
+			return
+		}
+	}
+	if req.State == "" {
+		req.State = "active"
+	}
+	if req.State != "active" && req.State != "all" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр state должен быть active или all"})
+		return
+	}
+
+	if _, err := app.models.GetProjectByGitLabID(projectID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Проект не подключен"})
+			return
+		}
+		app.respondError(c, err, "Не удалось получить проект")
+		return
+	}
+
+	ctx := c.Request.Context()
+	project, err := client.GetProject(ctx, strconv.Itoa(projectID))
+	if err != nil {
+		app.respondGitLabError(c, err)
+		return
+	}
+
+	sources, warnings, err := app.sprintSources(ctx, client, project, req.State, req.IncludeIterations == nil || *req.IncludeIterations)
+	if err != nil {
+		app.respondGitLabError(c, err)
+		return
+	}
+
+	imported := []importedSprint{}
+	for _, source := range sources {
+		result, err := app.importSprintSource(ctx, projectID, source)
+		if err != nil {
+			app.errorLog.Printf("Импорт %s %d проекта %d: %v", source.kind, source.id, projectID, err)
+			warnings = append(warnings, fmt.Sprintf("%s %q: %v", source.kind, source.title, err))
+			if errors.Is(err, gitlab.ErrCircuitOpen) {
+				break
+			}
+			continue
+		}
+		imported = append(imported, *result)
+	}
+
+	app.infoLog.Printf("Импорт спринтов проекта %d: обработано %d из %d", projectID, len(imported), len(sources))
+	c.JSON(http.StatusOK, gin.H{
+		"sprints": imported,
+		"errors":  warnings,
+	})
+}
+
+// sprintSources собирает вехи проекта и, если проект принадлежит группе, итерации группы.
+// Недоступность итераций (нет лицензии или прав) не считается ошибкой.
+func (app *application) sprintSources(ctx context.Context, client *gitlab.Client, project *gitlab.Project, state string, includeIterations bool) ([]sprintSource, []string, error) {
+	projectRef := strconv.Itoa(project.ID)
+	warnings := []string{}
+
+	query := url.Values{}
+	if state == "active" {
+		query.Set("state", "active")
+	}
+	milestones, err := client.ListProjectMilestones(ctx, projectRef, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sources []sprintSource
+	for _, m := range milestones {
+		milestone := m
+		sources = append(sources, sprintSource{
+			kind:        sprintSourceMilestone,
+			id:          milestone.ID,
+			title:       milestone.Title,
+			description: milestone.Description,
+			startDate:   milestone.StartDate,
+			dueDate:     milestone.DueDate,
+			createdAt:   milestone.CreatedAt,
+			closed:      milestone.State == "closed",
+			issues: func(ctx context.Context) ([]gitlab.Issue, error) {
+				return client.ListMilestoneIssues(ctx, projectRef, milestone.ID)
+			},
+		})
+	}
+
+	if !includeIterations || project.Namespace.Kind != "group" {
+		return sources, warnings, nil
+	}
+
+	iterationQuery := url.Values{"include_ancestors": {"true"}}
+	if state == "active" {
+		iterationQuery.Set("state", "opened")
+	}
+	iterations, err := client.ListGroupIterations(ctx, project.Namespace.ID, iterationQuery)
+	if errors.Is(err, gitlab.ErrNotFound) || errors.Is(err, gitlab.ErrForbidden) {
+		warnings = append(warnings, "итерации группы недоступны: "+gitlab.Message(err))
+		return sources, warnings, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, it := range iterations {
+		iteration := it
+		title := iteration.Title
+		if title == "" {
+			title = fmt.Sprintf("Итерация %d", iteration.SequenceID)
+		}
+		sources = append(sources, sprintSource{
+			kind:        sprintSourceIteration,
+			id:          iteration.ID,
+			title:       title,
+			description: iteration.Description,
+			startDate:   iteration.StartDate,
+			dueDate:     iteration.DueDate,
+			createdAt:   iteration.CreatedAt,
+			closed:      iteration.State == gitlab.IterationClosed,
+			issues: func(ctx context.Context) ([]gitlab.Issue, error) {
+				query := url.Values{"iteration_id": {strconv.Itoa(iteration.ID)}, "scope": {"all"}}
+				return client.ListProjectIssues(ctx, projectRef, query)
+			},
+		})
+	}
+
+	return sources, warnings, nil
+}
+
+// importSprintSource создает спринт из вехи или итерации (или находит созданный ранее)
+// и добавляет в него задачи. Story points по умолчанию берутся из веса задачи.
+func (app *application) importSprintSource(ctx context.Context, projectID int, source sprintSource) (*importedSprint, error) {
+	result := &importedSprint{Source: source.kind, SourceID: source.id, Title: source.title}
+
+	sprint, err := app.models.GetSprintByGitLabSource(projectID, source.kind, source.id)
+	switch {
+	case err == nil:
+		result.SprintID = sprint.SptID
+	case errors.Is(err, models.ErrNoRecord):
+		startDate, endDate := sourceDates(source)
+		var milestoneID, iterationID *int
+		if source.kind == sprintSourceMilestone {
+			milestoneID = &source.id
+		} else {
+			iterationID = &source.id
+		}
+
+		sprintID, err := app.models.CreateGitLabSprint(source.title, startDate, endDate, source.description,
+			projectID, milestoneID, iterationID, source.closed)
+		if errors.Is(err, models.ErrDuplicate) {
+			// Спринт создан параллельным импортом
+			sprint, err := app.models.GetSprintByGitLabSource(projectID, source.kind, source.id)
+			if err != nil {
+				return nil, err
+			}
+			result.SprintID = sprint.SptID
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.SprintID = sprintID
+		result.Created = true
+	default:
+		return nil, err
+	}
+
+	issues, err := source.issues(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var added []int
+	for i := range issues {
+		issue := &issues[i]
+
+		existing, err := app.models.GetSprintIssue(result.SprintID, issue.IID)
+		if err != nil {
+			return result, err
+		}
+		if existing != nil {
+			continue
+		}
+
+		storyPoints := 0
+		if issue.Weight != nil {
+			storyPoints = *issue.Weight
+		}
+		if err := app.models.AddIssueToSprint(result.SprintID, issue.IID, storyPoints, "", issue.Title, issue.Description); err != nil {
+			return result, err
+		}
+
+		// Переносим метки, исполнителей и состояние задачи (закрытые задачи становятся готовыми)
+		sprintIssue, err := app.models.GetSprintIssue(result.SprintID, issue.IID)
+		if err != nil || sprintIssue == nil {
+			return result, fmt.Errorf("задача #%d не добавлена в спринт: %v", issue.IID, err)
+		}
+		if err := app.applyGitLabIssue(projectID, *sprintIssue, issue); err != nil {
+			app.errorLog.Printf("Импорт: ошибка сверки задачи #%d: %v", issue.IID, err)
+		}
+
+		added = append(added, issue.IID)
+	}
+
+	result.IssuesAdded = len(added)
+	if len(added) > 0 {
+		if err := app.models.RemoveBacklogIssues(projectID, added); err != nil {
+			app.errorLog.Printf("Импорт: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// sourceDates возвращает даты спринта; если у вехи нет дат, спринт начинается
+// с даты ее создания и длится defaultImportedSprintDays дней
+func sourceDates(source sprintSource) (time.Time, time.Time) {
+	start, err := time.Parse("2006-01-02", source.startDate)
+	if err != nil {
+		start = time.Now().Truncate(24 * time.Hour)
+		if source.createdAt != nil {
+			start = source.createdAt.Truncate(24 * time.Hour)
+		}
+	}
+
+	end, err := time.Parse("2006-01-02", source.dueDate)
+	if err != nil || end.Before(start) {
+		end = start.AddDate(0, 0, defaultImportedSprintDays)
+	}
+	return start, end
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSourceDates(t *testing.T) {
+	created := time.Date(2024, 5, 3, 15, 30, 0, 0, time.UTC)
+	day := func(value string) time.Time {
+		date, _ := time.Parse("2006-01-02", value)
+		return date
+	}
+
+	tests := []struct {
+		name      string
+		source    sprintSource
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "даты вехи",
+			source:    sprintSource{startDate: "2024-05-06", dueDate: "2024-05-17"},
+			wantStart: day("2024-05-06"),
+			wantEnd:   day("2024-05-17"),
+		},
+		{
+			name:      "без даты начала берется дата создания",
+			source:    sprintSource{dueDate: "2024-05-17", createdAt: &created},
+			wantStart: day("2024-05-03"),
+			wantEnd:   day("2024-05-17"),
+		},
+		{
+			name:      "без срока спринт длится две недели",
+			source:    sprintSource{startDate: "2024-05-06"},
+			wantStart: day("2024-05-06"),
+			wantEnd:   day("2024-05-20"),
+		},
+		{
+			name:      "без дат",
+			source:    sprintSource{createdAt: &created},
+			wantStart: day("2024-05-03"),
+			wantEnd:   day("2024-05-17"),
+		},
+		{
+			name:      "срок раньше начала",
+			source:    sprintSource{startDate: "2024-05-06", dueDate: "2024-05-01"},
+			wantStart: day("2024-05-06"),
+			wantEnd:   day("2024-05-20"),
+		},
+		{
+			name:      "срок в день начала",
+			source:    sprintSource{startDate: "2024-05-06", dueDate: "2024-05-06"},
+			wantStart: day("2024-05-06"),
+			wantEnd:   day("2024-05-06"),
+		},
+		{
+			name:      "неверный формат даты",
+			source:    sprintSource{startDate: "06.05.2024", dueDate: "2024-05-17", createdAt: &created},
+			wantStart: day("2024-05-03"),
+			wantEnd:   day("2024-05-17"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := sourceDates(tt.source)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("sourceDates() = %s — %s, ожидалось %s — %s",
+					start.Format("2006-01-02"), end.Format("2006-01-02"),
+					tt.wantStart.Format("2006-01-02"), tt.wantEnd.Format("2006-01-02"))
+			}
+		})
+	}
+
+	t.Run("без дат и даты создания начинается сегодня", func(t *testing.T) {
+		start, end := sourceDates(sprintSource{})
+		today := time.Now().Truncate(24 * time.Hour)
+		if !start.Equal(today) || !end.Equal(today.AddDate(0, 0, defaultImportedSprintDays)) {
+			t.Errorf("sourceDates() = %s — %s", start, end)
+		}
+	})
+}
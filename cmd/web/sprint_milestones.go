@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+	"golangify.com/plaginagile/pkg/models/pgsql"
+)
+
+// errMilestoneNoToken возвращается, если для синхронизации с вехой не передан токен GitLab
+var errMilestoneNoToken = errors.New("для синхронизации с вехой GitLab нужно войти через GitLab")
+
+// optionalGitLabClient возвращает клиент с токеном пользователя или nil, если его нет.
+// Используется там, где обращение к GitLab дополняет локальную операцию, а не заменяет ее.
+func (app *application) optionalGitLabClient(c *gin.Context) *gitlab.Client {
+	token, err := app.requestGitLabToken(c)
+	if errors.Is(err, errPersonalTokenNoGitLab) || errors.Is(err, errGitLabNotLinked) {
+		return nil
+	}
+	if err != nil {
+		app.errorLog.Printf("Ошибка получения токена сессии: %v", err)
+		return nil
+	}
+	if token == "" {
+		return nil
+	}
+	return app.gitlab.WithToken(token)
+}
+
+// milestoneSyncError логирует ошибку синхронизации с вехой и возвращает текст для ответа.
+// Такие ошибки не отменяют изменения спринта.
+func (app *application) milestoneSyncError(sprintID int, err error) string {
+	app.errorLog.Printf("Ошибка синхронизации спринта %d с вехой GitLab: %v", sprintID, err)
+
+	var apiErr *gitlab.Error
+	if errors.As(err, &apiErr) || errors.Is(err, gitlab.ErrCircuitOpen) {
+		return gitlab.Message(err)
+	}
+	return err.Error()
+}
+
+// sprintMilestoneOptions формирует атрибуты вехи по данным спринта
+func sprintMilestoneOptions(title, goals string, startDate, endDate time.Time) map[string]interface{} {
+	opts := map[string]interface{}{
+		"title":       title,
+		"description": goals,
+	}
+	if !startDate.IsZero() {
+		opts["start_date"] = startDate.Format("2006-01-02")
+	}
+	if !endDate.IsZero() {
+		opts["due_date"] = endDate.Format("2006-01-02")
+	}
+	return opts
+}
+
+// attachSprintMilestone создает веху для спринта (или берет существующую веху с тем же
+// названием), связывает ее со спринтом и проставляет во всех задачах спринта
+func (app *application) attachSprintMilestone(ctx context.Context, client *gitlab.Client, sprint *pgsql.Sprint) (*gitlab.Milestone, error) {
+	projectRef := strconv.Itoa(sprint.SptProjectID)
+
+	existing, err := client.ListProjectMilestones(ctx, projectRef, url.Values{"title": {sprint.SptTitle}})
+	if err != nil {
+		return nil, err
+	}
+
+	var milestone *gitlab.Milestone
+	if len(existing) > 0 {
+		milestone = &existing[0]
+	} else {
+		milestone, err = client.CreateMilestone(ctx, projectRef, sprintMilestoneOptions(sprint.SptTitle, sprint.SptGoals, sprint.SptStartDate, sprint.SptEndDate))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := app.models.SetSprintGitLabSource(sprint.SptID, &milestone.ID, sprint.SptIterationID); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			return nil, fmt.Errorf("веха %q уже связана с другим спринтом", milestone.Title)
+		}
+		return nil, err
+	}
+	sprint.SptMilestoneID = &milestone.ID
+
+	issues, err := app.models.GetSprintIssues(sprint.SptID)
+	if err != nil {
+		return milestone, err
+	}
+	failed := 0
+	for _, issue := range issues {
+		if err := setIssueMilestone(ctx, client, sprint.SptProjectID, issue.IssueID, milestone.ID); err != nil {
+			app.errorLog.Printf("Не удалось проставить веху %d задаче #%d: %v", milestone.ID, issue.IssueID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return milestone, fmt.Errorf("веха не проставлена в %d из %d задач спринта", failed, len(issues))
+	}
+
+	return milestone, nil
+}
+
+// setIssueMilestone проставляет задаче GitLab веху спринта
+func setIssueMilestone(ctx context.Context, client *gitlab.Client, projectID, issueID, milestoneID int) error {
+	_, err := client.UpdateIssue(ctx, strconv.Itoa(projectID), issueID, map[string]interface{}{
+		"milestone_id": milestoneID,
+	})
+	return err
+}
+
+// clearIssueMilestone снимает с задачи GitLab веху спринта. Если задача уже перенесена
+// в другую веху или удалена, она не изменяется.
+func clearIssueMilestone(ctx context.Context, client *gitlab.Client, projectID, issueID, milestoneID int) error {
+	projectRef := strconv.Itoa(projectID)
+
+	issue, err := client.GetIssue(ctx, projectRef, issueID)
+	if err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if issue.Milestone == nil || issue.Milestone.ID != milestoneID {
+		return nil
+	}
+
+	_, err = client.UpdateIssue(ctx, projectRef, issueID, map[string]interface{}{
+		"milestone_id": 0,
+	})
+	return err
+}
+
+// handleGitLabIssueMilestone переносит задачу между спринтами, когда в GitLab меняется ее веха:
+// задача убирается из спринта прежней вехи и добавляется в спринт новой
+func (app *application) handleGitLabIssueMilestone(webhook GitLabWebhookRequest) error {
+	change := webhook.Changes.MilestoneID
+	if change == nil {
+		return nil
+	}
+
+	projectID := webhook.Project.ID
+	issue := webhook.ObjectAttributes
+	app.infoLog.Printf("Веха задачи #%d проекта %d изменена: %s -> %s",
+		issue.IID, projectID, formatOptionalInt(change.Previous), formatOptionalInt(change.Current))
+
+	if change.Previous != nil {
+		sprint, err := app.models.GetSprintByGitLabSource(projectID, sprintSourceMilestone, *change.Previous)
+		switch {
+		case err == nil:
+			if sprint.SptStatus != "completed" {
+				if err := app.models.DeleteSprintIssue(sprint.SptID, issue.IID); err != nil {
+					return err
+				}
+			}
+		case !errors.Is(err, models.ErrNoRecord):
+			return err
+		}
+	}
+
+	if change.Current == nil {
+		return nil
+	}
+
+	sprint, err := app.models.GetSprintByGitLabSource(projectID, sprintSourceMilestone, *change.Current)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sprint.SptStatus == "completed" {
+		app.infoLog.Printf("Спринт %d завершен, задача #%d не добавлена", sprint.SptID, issue.IID)
+		return nil
+	}
+
+	existing, err := app.models.GetSprintIssue(sprint.SptID, issue.IID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	storyPoints := 0
+	if issue.Weight != nil {
+		storyPoints = *issue.Weight
+	}
+	if err := app.models.AddIssueToSprint(sprint.SptID, issue.IID, storyPoints, "", issue.Title, issue.Description); err != nil {
+		return err
+	}
+	if err := app.models.RemoveBacklogIssues(projectID, []int{issue.IID}); err != nil {
+		app.errorLog.Printf("Ошибка обновления бэклога: %v", err)
+	}
+
+	app.infoLog.Printf("Задача #%d добавлена в спринт %d по вехе %d", issue.IID, sprint.SptID, *change.Current)
+	return nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// syncWorker периодически сверяет задачи активных спринтов с GitLab
+// от имени сервисного токена, чтобы чтение доски не ждало GitLab
+type syncWorker struct {
+	app         *application
+	client      *gitlab.Client
+	interval    time.Duration
+	concurrency int
+}
+
+func newSyncWorker(app *application, serviceToken string, interval time.Duration, concurrency int) *syncWorker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &syncWorker{
+		app:         app,
+		client:      app.gitlab.WithToken(serviceToken),
+		interval:    interval,
+		concurrency: concurrency,
+	}
+}
+
+// Run выполняет синхронизацию сразу и затем каждые interval до отмены контекста
+func (w *syncWorker) Run(ctx context.Context) {
+	w.app.infoLog.Printf("Фоновая синхронизация с GitLab запущена: интервал %s, параллельность %d", w.interval, w.concurrency)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.syncOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.app.infoLog.Printf("Фоновая синхронизация с GitLab остановлена")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncOnce сверяет все задачи активных спринтов, не более concurrency запросов одновременно
+func (w *syncWorker) syncOnce(ctx context.Context) {
+	started := time.Now()
+
+	sprints, err := w.app.models.GetActiveSprints()
+	if err != nil {
+		w.app.errorLog.Printf("Синхронизация: ошибка получения активных спринтов: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	access := newProjectAccess(w.client)
+	semaphore := make(chan struct{}, w.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	synced, failed := 0, 0
+
+sprintsLoop:
+	for _, sprint := range sprints {
+		issues, err := w.app.models.GetSprintIssues(sprint.SptID)
+		if err != nil {
+			w.app.errorLog.Printf("Синхронизация: ошибка получения задач спринта %d: %v", sprint.SptID, err)
+			continue
+		}
+
+		for _, issue := range issues {
+			select {
+			case <-ctx.Done():
+			case semaphore <- struct{}{}:
+			}
+			if ctx.Err() != nil {
+				break sprintsLoop
+			}
+
+			wg.Add(1)
+			go func(projectID int, issue models.SprintIssue) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				err := w.app.reconcileIssueWithGitLab(ctx, w.client, access, projectID, issue)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed++
+					w.app.errorLog.Printf("Синхронизация задачи #%d спринта %d: %v", issue.IssueID, issue.SprintID, err)
+					// Если GitLab недоступен, остальные запросы тоже не пройдут
+					if errors.Is(err, gitlab.ErrCircuitOpen) {
+						cancel()
+					}
+					return
+				}
+				synced++
+			}(sprint.SptProjectID, issue)
+		}
+	}
+
+	wg.Wait()
+	w.app.infoLog.Printf("Синхронизация с GitLab завершена за %s: спринтов %d, задач обновлено %d, ошибок %d",
+		time.Since(started).Round(time.Millisecond), len(sprints), synced, failed)
+}
+
+// Состояния задачи в GitLab, сохраняемые при сверке
+const (
+	gitlabStateOpened  = "opened"
+	gitlabStateClosed  = "closed"
+	gitlabStateDeleted = "deleted"
+	gitlabStateMoved   = "moved"
+)
+
+// projectAccess проверяет доступ к проектам GitLab не больше одного раза за сверку
+type projectAccess struct {
+	client *gitlab.Client
+	mu     sync.Mutex
+	checks map[int]func() error
+}
+
+func newProjectAccess(client *gitlab.Client) *projectAccess {
+	return &projectAccess{client: client, checks: map[int]func() error{}}
+}
+
+// Check возвращает ошибку, если проект недоступен: удален, переименован
+// или у токена больше нет доступа к нему
+func (pa *projectAccess) Check(ctx context.Context, projectID int) error {
+	pa.mu.Lock()
+	check, ok := pa.checks[projectID]
+	if !ok {
+		check = sync.OnceValue(func() error {
+			_, err := pa.client.GetProject(ctx, strconv.Itoa(projectID))
+			return err
+		})
+		pa.checks[projectID] = check
+	}
+	pa.mu.Unlock()
+
+	return check()
+}
+
+// reconcileIssueWithGitLab переносит в задачу спринта актуальные поля задачи GitLab
+// (название, описание, исполнителей, метки, вес, срок), меняет статус при закрытии
+// или переоткрытии задачи в GitLab и записывает найденные расхождения в отчет
+func (app *application) reconcileIssueWithGitLab(ctx context.Context, client *gitlab.Client, access *projectAccess, projectID int, issue models.SprintIssue) error {
+	gitlabIssue, err := client.GetIssue(ctx, strconv.Itoa(projectID), issue.IssueID)
+	if errors.Is(err, gitlab.ErrNotFound) {
+		// GitLab отвечает 404 и для задач недоступного проекта: задача считается
+		// удаленной, только если сам проект доступен
+		if err := access.Check(ctx, projectID); err != nil {
+			return fmt.Errorf("проект %d недоступен в GitLab: %w", projectID, err)
+		}
+		return app.markIssueGone(projectID, issue, gitlabStateDeleted, nil, "задача удалена в GitLab")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка запроса к GitLab: %w", err)
+	}
+
+	return app.applyGitLabIssue(projectID, issue, gitlabIssue)
+}
+
+// applyGitLabIssue сверяет задачу спринта с уже полученной задачей GitLab
+func (app *application) applyGitLabIssue(projectID int, issue models.SprintIssue, gitlabIssue *gitlab.Issue) error {
+	// Перенесенная задача закрывается в исходном проекте, но работа по ней не завершена
+	if gitlabIssue.MovedToID != nil {
+		reason := fmt.Sprintf("задача перенесена в другой проект (новый ID %d)", *gitlabIssue.MovedToID)
+		return app.markIssueGone(projectID, issue, gitlabStateMoved, gitlabIssue.MovedToID, reason)
+	}
+
+	snapshot, err := issueSnapshot(gitlabIssue)
+	if err != nil {
+		return err
+	}
+
+	discrepancies := issueDiscrepancies(projectID, issue, snapshot)
+	if err := app.models.ApplyGitLabIssueSnapshot(issue.SprintID, issue.IssueID, snapshot); err != nil {
+		return fmt.Errorf("ошибка обновления задачи: %w", err)
+	}
+
+	// Статус меняем только при смене состояния в GitLab, чтобы не перетирать ручные правки
+	var event models.IssueEvent
+	switch {
+	case snapshot.State == gitlabStateClosed && issue.GitLabState != gitlabStateClosed:
+		event = models.EventIssueClosed
+	case snapshot.State == gitlabStateOpened && issue.GitLabState == gitlabStateClosed:
+		event = models.EventIssueReopened
+	case issue.AssignedTo == nil && len(snapshot.AssigneeIDs) > 0:
+		event = models.EventAssigned
+	}
+	if event != "" {
+		assigned := issue.AssignedTo != nil || len(snapshot.AssigneeIDs) > 0
+		newStatus := models.NextIssueStatus(models.IssueState{Status: issue.Status, Assigned: assigned}, event)
+		if newStatus != issue.Status {
+			app.infoLog.Printf("Синхронизация: задача #%d в GitLab %s, обновляем статус на '%s'", issue.IssueID, snapshot.State, newStatus)
+			if err := app.models.UpdateSprintIssueStatus(issue.SprintID, issue.IssueID, newStatus, nil, nil, "", nil); err != nil {
+				return fmt.Errorf("ошибка обновления статуса задачи: %w", err)
+			}
+			discrepancies = append(discrepancies, models.SyncDiscrepancy{
+				SprintID:    issue.SprintID,
+				IssueID:     issue.IssueID,
+				ProjectID:   projectID,
+				Kind:        models.DiscrepancyStatus,
+				Field:       "status",
+				LocalValue:  issue.Status,
+				GitLabValue: snapshot.State,
+				Reason:      fmt.Sprintf("статус изменен на '%s'", newStatus),
+			})
+		}
+	}
+
+	if err := app.models.AddSyncDiscrepancies(discrepancies); err != nil {
+		return err
+	}
+
+	return app.models.MarkSprintIssueSynced(issue.SprintID, issue.IssueID, time.Now())
+}
+
+// markIssueGone отмечает задачу, удаленную или перенесенную в GitLab.
+// Расхождение записывается один раз — при первом обнаружении.
+func (app *application) markIssueGone(projectID int, issue models.SprintIssue, state string, movedToID *int, reason string) error {
+	if issue.GitLabState != state {
+		app.infoLog.Printf("Синхронизация: задача #%d спринта %d: %s", issue.IssueID, issue.SprintID, reason)
+
+		if err := app.models.SetSprintIssueGitLabState(issue.SprintID, issue.IssueID, state, movedToID); err != nil {
+			return err
+		}
+
+		err := app.models.AddSyncDiscrepancies([]models.SyncDiscrepancy{
+			goneDiscrepancy(projectID, issue, state, movedToID, reason),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.models.MarkSprintIssueSynced(issue.SprintID, issue.IssueID, time.Now())
+}
+
+// goneDiscrepancy описывает задачу, удаленную или перенесенную в GitLab;
+// для перенесенной задачи в отчет попадает ее новый ID
+func goneDiscrepancy(projectID int, issue models.SprintIssue, state string, movedToID *int, reason string) models.SyncDiscrepancy {
+	kind := models.DiscrepancyDeleted
+	gitlabValue := ""
+	if state == gitlabStateMoved && movedToID != nil {
+		kind = models.DiscrepancyMoved
+		gitlabValue = strconv.Itoa(*movedToID)
+	}
+	return models.SyncDiscrepancy{
+		SprintID:    issue.SprintID,
+		IssueID:     issue.IssueID,
+		ProjectID:   projectID,
+		Kind:        kind,
+		Field:       "issue",
+		LocalValue:  issue.Title,
+		GitLabValue: gitlabValue,
+		Reason:      reason,
+	}
+}
+
+// issueSnapshot выбирает из задачи GitLab поля, которые хранятся в задаче спринта
+func issueSnapshot(issue *gitlab.Issue) (models.GitLabIssueSnapshot, error) {
+	snapshot := models.GitLabIssueSnapshot{
+		Title:       issue.Title,
+		Description: issue.Description,
+		Labels:      issue.Labels,
+		Weight:      issue.Weight,
+		State:       issue.State,
+	}
+	for _, assignee := range issue.Assignees {
+		snapshot.AssigneeIDs = append(snapshot.AssigneeIDs, assignee.ID)
+	}
+	if issue.DueDate != nil && *issue.DueDate != "" {
+		dueDate, err := time.Parse("2006-01-02", *issue.DueDate)
+		if err != nil {
+			return snapshot, fmt.Errorf("неверный срок задачи в GitLab %q: %w", *issue.DueDate, err)
+		}
+		snapshot.DueDate = &dueDate
+	}
+	return snapshot, nil
+}
+
+// issueDiscrepancies сравнивает задачу спринта с данными GitLab.
+// Метки, исполнители, вес и срок сравниваются только после первой сверки,
+// иначе первое заполнение этих полей попало бы в отчет как расхождение.
+func issueDiscrepancies(projectID int, local models.SprintIssue, remote models.GitLabIssueSnapshot) []models.SyncDiscrepancy {
+	type field struct {
+		name          string
+		local, remote string
+	}
+	fields := []field{
+		{"title", local.Title, remote.Title},
+		{"description", local.Description, remote.Description},
+	}
+	if local.GitLabState != "" {
+		fields = append(fields,
+			field{"labels", strings.Join(local.Labels, ", "), strings.Join(remote.Labels, ", ")},
+			field{"assignees", joinInts(local.AssigneeIDs), joinInts(remote.AssigneeIDs)},
+			field{"weight", formatOptionalInt(local.Weight), formatOptionalInt(remote.Weight)},
+			field{"due_date", formatOptionalDate(local.DueDate), formatOptionalDate(remote.DueDate)},
+		)
+	}
+
+	var discrepancies []models.SyncDiscrepancy
+	for _, f := range fields {
+		if f.local == f.remote {
+			continue
+		}
+		discrepancies = append(discrepancies, models.SyncDiscrepancy{
+			SprintID:    local.SprintID,
+			IssueID:     local.IssueID,
+			ProjectID:   projectID,
+			Kind:        models.DiscrepancyChanged,
+			Field:       f.name,
+			LocalValue:  f.local,
+			GitLabValue: f.remote,
+			Reason:      "значение обновлено из GitLab",
+		})
+	}
+	return discrepancies
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatOptionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatOptionalDate(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format("2006-01-02")
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golangify.com/plaginagile/pkg/gitlab"
+	"golangify.com/plaginagile/pkg/models"
+)
+
+// testGitLab отвечает 404 на запросы задач, а на запрос проекта — projectStatus
+func testGitLab(t *testing.T, projectStatus int, projectRequests *atomic.Int32) *gitlab.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/issues/") {
+			http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+			return
+		}
+		projectRequests.Add(1)
+		w.WriteHeader(projectStatus)
+		if projectStatus == http.StatusOK {
+			w.Write([]byte(`{"id":7}`))
+		} else {
+			w.Write([]byte(`{"message":"404 Project Not Found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := gitlab.NewClient(server.URL, 0)
+	client.SetRetryPolicy(gitlab.RetryPolicy{})
+	return client
+}
+
+func TestProjectAccessChecksOnce(t *testing.T) {
+	var requests atomic.Int32
+	access := newProjectAccess(testGitLab(t, http.StatusNotFound, &requests))
+
+	for range 5 {
+		if err := access.Check(context.Background(), 7); !errors.Is(err, gitlab.ErrNotFound) {
+			t.Fatalf("Check() = %v, ожидалась ErrNotFound", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("запросов проекта %d, ожидался 1", got)
+	}
+}
+
+func TestReconcileIssueProjectUnavailable(t *testing.T) {
+	var requests atomic.Int32
+	client := testGitLab(t, http.StatusNotFound, &requests)
+	access := newProjectAccess(client)
+
+	// Без доступа к проекту задачи не отмечаются удаленными: models не задан,
+	// и любое обращение к базе завершило бы тест паникой
+	app := &application{}
+	for _, iid := range []int{1, 2, 3} {
+		err := app.reconcileIssueWithGitLab(context.Background(), client, access, 7, models.SprintIssue{SprintID: 1, IssueID: iid})
+		if !errors.Is(err, gitlab.ErrNotFound) {
+			t.Fatalf("задача #%d: ошибка = %v, ожидалась ошибка доступа к проекту", iid, err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("запросов проекта %d, ожидался 1", got)
+	}
+}
+
+func TestIssueDiscrepancies(t *testing.T) {
+	weight3, weight5 := 3, 5
+	due := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	dueLater := time.Date(2024, 5, 27, 0, 0, 0, 0, time.UTC)
+
+	synced := models.SprintIssue{
+		SprintID:    1,
+		IssueID:     42,
+		Title:       "Форма входа",
+		Description: "Описание",
+		Labels:      []string{"backend"},
+		AssigneeIDs: []int{7},
+		Weight:      &weight3,
+		DueDate:     &due,
+		GitLabState: gitlabStateOpened,
+	}
+	same := models.GitLabIssueSnapshot{
+		Title:       synced.Title,
+		Description: synced.Description,
+		Labels:      []string{"backend"},
+		AssigneeIDs: []int{7},
+		Weight:      &weight3,
+		DueDate:     &due,
+	}
+
+	tests := []struct {
+		name   string
+		local  models.SprintIssue
+		modify func(*models.GitLabIssueSnapshot)
+		want   map[string][2]string // поле -> локальное и новое значения
+	}{
+		{"без изменений", synced, func(*models.GitLabIssueSnapshot) {}, nil},
+		{
+			name:   "название",
+			local:  synced,
+			modify: func(s *models.GitLabIssueSnapshot) { s.Title = "Форма входа по паролю" },
+			want:   map[string][2]string{"title": {"Форма входа", "Форма входа по паролю"}},
+		},
+		{
+			name:   "метки",
+			local:  synced,
+			modify: func(s *models.GitLabIssueSnapshot) { s.Labels = []string{"backend", "security"} },
+			want:   map[string][2]string{"labels": {"backend", "backend, security"}},
+		},
+		{
+			name:   "вес",
+			local:  synced,
+			modify: func(s *models.GitLabIssueSnapshot) { s.Weight = &weight5 },
+			want:   map[string][2]string{"weight": {"3", "5"}},
+		},
+		{
+			name:   "вес убран",
+			local:  synced,
+			modify: func(s *models.GitLabIssueSnapshot) { s.Weight = nil },
+			want:   map[string][2]string{"weight": {"3", ""}},
+		},
+		{
+			name:   "срок",
+			local:  synced,
+			modify: func(s *models.GitLabIssueSnapshot) { s.DueDate = &dueLater },
+			want:   map[string][2]string{"due_date": {"2024-05-20", "2024-05-27"}},
+		},
+		{
+			name:  "несколько полей",
+			local: synced,
+			modify: func(s *models.GitLabIssueSnapshot) {
+				s.Title = "Вход"
+				s.AssigneeIDs = []int{7, 8}
+			},
+			want: map[string][2]string{"title": {"Форма входа", "Вход"}, "assignees": {"7", "7, 8"}},
+		},
+		{
+			name: "первая сверка не сравнивает метки, вес и срок",
+			local: models.SprintIssue{
+				SprintID: 1, IssueID: 42, Title: synced.Title, Description: synced.Description,
+			},
+			modify: func(*models.GitLabIssueSnapshot) {},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := same
+			tt.modify(&remote)
+
+			got := issueDiscrepancies(9, tt.local, remote)
+			if len(got) != len(tt.want) {
+				t.Fatalf("расхождений %d, ожидалось %d: %+v", len(got), len(tt.want), got)
+			}
+			for _, d := range got {
+				values, ok := tt.want[d.Field]
+				if !ok {
+					t.Errorf("неожиданное расхождение поля %s", d.Field)
+					continue
+				}
+				if d.LocalValue != values[0] || d.GitLabValue != values[1] {
+					t.Errorf("%s: %q -> %q, ожидалось %q -> %q", d.Field, d.LocalValue, d.GitLabValue, values[0], values[1])
+				}
+				if d.Kind != models.DiscrepancyChanged || d.SprintID != 1 || d.IssueID != 42 || d.ProjectID != 9 {
+					t.Errorf("расхождение %+v", d)
+				}
+			}
+		})
+	}
+}
+
+func TestIssueSnapshot(t *testing.T) {
+	weight := 2
+	valid, empty, invalid := "2024-05-20", "", "20.05.2024"
+
+	tests := []struct {
+		name    string
+		dueDate *string
+		want    string
+		wantErr bool
+	}{
+		{"срок задан", &valid, "2024-05-20", false},
+		{"срок не задан", nil, "", false},
+		{"пустой срок", &empty, "", false},
+		{"неверный срок", &invalid, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := &gitlab.Issue{
+				Title:     "Форма",
+				Labels:    []string{"ui"},
+				Assignees: []gitlab.User{{ID: 3}, {ID: 4}},
+				Weight:    &weight,
+				DueDate:   tt.dueDate,
+				State:     gitlabStateOpened,
+			}
+			snapshot, err := issueSnapshot(issue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := formatOptionalDate(snapshot.DueDate); got != tt.want {
+				t.Errorf("срок = %q, ожидалось %q", got, tt.want)
+			}
+			if joinInts(snapshot.AssigneeIDs) != "3, 4" || *snapshot.Weight != 2 || snapshot.State != gitlabStateOpened {
+				t.Errorf("снимок = %+v", snapshot)
+			}
+		})
+	}
+}
+
+func TestGoneDiscrepancy(t *testing.T) {
+	movedTo := 901
+	issue := models.SprintIssue{SprintID: 1, IssueID: 42, Title: "Форма входа"}
+
+	tests := []struct {
+		name      string
+		state     string
+		movedToID *int
+		kind      string
+		value     string
+	}{
+		{"удалена", gitlabStateDeleted, nil, models.DiscrepancyDeleted, ""},
+		{"перенесена", gitlabStateMoved, &movedTo, models.DiscrepancyMoved, "901"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := goneDiscrepancy(9, issue, tt.state, tt.movedToID, "причина")
+			if d.Kind != tt.kind || d.GitLabValue != tt.value || d.Field != "issue" || d.LocalValue != "Форма входа" || d.ProjectID != 9 {
+				t.Errorf("goneDiscrepancy() = %+v", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+-- Мердж-реквесты GitLab, ссылающиеся на задачи спринта
+CREATE TABLE IF NOT EXISTS sprint_issue_merge_requests (
+    simr_id              SERIAL PRIMARY KEY,
+    simr_sprint_id       INTEGER NOT NULL,
+    simr_issue_id        INTEGER NOT NULL,
+    simr_project_id      INTEGER NOT NULL,
+    simr_mr_id           INTEGER NOT NULL,
+    simr_mr_iid          INTEGER NOT NULL,
+    simr_title           TEXT NOT NULL DEFAULT '',
+    simr_state           VARCHAR(32) NOT NULL DEFAULT '',
+    simr_author_id       INTEGER NOT NULL DEFAULT 0,
+    simr_author_username VARCHAR(255) NOT NULL DEFAULT '',
+    simr_reviewers       TEXT[] NOT NULL DEFAULT '{}',
+    simr_approved_by     TEXT[] NOT NULL DEFAULT '{}',
+    simr_source_branch   VARCHAR(255) NOT NULL DEFAULT '',
+    simr_target_branch   VARCHAR(255) NOT NULL DEFAULT '',
+    simr_web_url         TEXT NOT NULL DEFAULT '',
+    simr_created_at      TIMESTAMPTZ,
+    simr_merged_at       TIMESTAMPTZ,
+    simr_updated_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (simr_project_id, simr_mr_iid, simr_sprint_id, simr_issue_id),
+    FOREIGN KEY (simr_sprint_id, simr_issue_id)
+        REFERENCES sprint_issues (si_sprint_id, si_issue_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_simr_sprint_issue
+    ON sprint_issue_merge_requests (simr_sprint_id, simr_issue_id);
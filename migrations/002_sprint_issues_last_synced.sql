@@ -0,0 +1,2 @@
+-- Время последней синхронизации задачи спринта с GitLab фоновым обработчиком
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_last_synced_at TIMESTAMPTZ;
@@ -0,0 +1,25 @@
+-- Поля задачи спринта, сверяемые с GitLab
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_labels TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_assignee_ids INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_weight INTEGER;
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_due_date DATE;
+-- Состояние задачи в GitLab при последней синхронизации: opened, closed, deleted, moved
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_gitlab_state VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE sprint_issues ADD COLUMN IF NOT EXISTS si_moved_to_id INTEGER;
+
+-- Расхождения между задачами спринта и GitLab, найденные при синхронизации
+CREATE TABLE IF NOT EXISTS sync_discrepancies (
+    sd_id          SERIAL PRIMARY KEY,
+    sd_sprint_id   INTEGER NOT NULL,
+    sd_issue_id    INTEGER NOT NULL,
+    sd_project_id  INTEGER NOT NULL,
+    sd_kind        VARCHAR(16) NOT NULL, -- changed, status, deleted, moved
+    sd_field       VARCHAR(32) NOT NULL DEFAULT '',
+    sd_local_value TEXT NOT NULL DEFAULT '',
+    sd_gitlab_value TEXT NOT NULL DEFAULT '',
+    sd_reason      TEXT NOT NULL DEFAULT '',
+    sd_detected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_discrepancies_sprint
+    ON sync_discrepancies (sd_sprint_id, sd_detected_at DESC);
@@ -0,0 +1,9 @@
+-- Курсоры опроса GitLab для режима без вебхуков: по одному на проект GitLab
+CREATE TABLE IF NOT EXISTS gitlab_poll_cursors (
+    gpc_project_id              INTEGER PRIMARY KEY,
+    gpc_last_event_id           BIGINT NOT NULL DEFAULT 0,
+    gpc_events_since            TIMESTAMPTZ NOT NULL,
+    gpc_merge_requests_since    TIMESTAMPTZ NOT NULL,
+    gpc_issues_since            TIMESTAMPTZ NOT NULL,
+    gpc_updated_at              TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+-- Вебхуки, зарегистрированные приложением в проектах GitLab
+CREATE TABLE IF NOT EXISTS project_webhooks (
+    pwh_project_id INTEGER PRIMARY KEY,           -- ID проекта в GitLab
+    pwh_hook_id    INTEGER NOT NULL,               -- ID вебхука в GitLab
+    pwh_url        TEXT NOT NULL,
+    pwh_secret     VARCHAR(128) NOT NULL,          -- передается GitLab в заголовке X-Gitlab-Token
+    pwh_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pwh_updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
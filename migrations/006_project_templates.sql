@@ -0,0 +1,40 @@
+-- Шаблоны начальной настройки проектов GitLab
+CREATE TABLE IF NOT EXISTS project_templates (
+    pt_id          SERIAL PRIMARY KEY,
+    pt_name        VARCHAR(100) NOT NULL UNIQUE,
+    pt_description TEXT NOT NULL DEFAULT '',
+    pt_config      JSONB NOT NULL DEFAULT '{}',
+    pt_created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pt_updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Стандартный шаблон: метки статусов и приоритетов, доска, вехи по две недели,
+-- шаблоны описания задачи и мердж-реквеста
+INSERT INTO project_templates (pt_name, pt_description, pt_config)
+VALUES ('Стандартный', 'Метки статусов и приоритетов, доска по статусам, двухнедельные вехи', '{
+    "labels": [
+        {"name": "К выполнению", "color": "#6699cc", "description": "Задача запланирована"},
+        {"name": "В работе", "color": "#f0ad4e", "description": "Задача в работе"},
+        {"name": "На проверке", "color": "#5843ad", "description": "Открыт мердж-реквест"},
+        {"name": "Заблокировано", "color": "#d9534f", "description": "Работа по задаче остановлена"},
+        {"name": "Приоритет: высокий", "color": "#cc0033", "description": ""},
+        {"name": "Приоритет: средний", "color": "#ed9121", "description": ""},
+        {"name": "Приоритет: низкий", "color": "#8fbc8f", "description": ""}
+    ],
+    "board": {
+        "name": "Спринт",
+        "lists": ["К выполнению", "В работе", "На проверке", "Заблокировано"]
+    },
+    "milestones": {"title_prefix": "Спринт", "duration_days": 14},
+    "files": [
+        {
+            "path": ".gitlab/issue_templates/Задача.md",
+            "content": "## Описание\n\n## Критерии приемки\n\n- [ ] \n\n## Оценка\n\nStory points: \n"
+        },
+        {
+            "path": ".gitlab/merge_request_templates/Default.md",
+            "content": "## Что сделано\n\n## Как проверить\n\nCloses #\n"
+        }
+    ]
+}')
+ON CONFLICT (pt_name) DO NOTHING;
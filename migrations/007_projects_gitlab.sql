@@ -0,0 +1,6 @@
+-- Связь локального проекта с проектом GitLab
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS prj_gitlab_id INTEGER UNIQUE;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS prj_gitlab_owner_id INTEGER; -- пользователь GitLab, создавший проект
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS prj_web_url TEXT NOT NULL DEFAULT '';
+-- Владелец из локальной таблицы users указывается не всегда
+ALTER TABLE projects ALTER COLUMN prj_owner DROP NOT NULL;
@@ -0,0 +1,11 @@
+-- Бэклог проекта: задачи GitLab, еще не распределенные по спринтам
+CREATE TABLE IF NOT EXISTS project_backlog (
+    pb_project_id  INTEGER NOT NULL,   -- ID проекта в GitLab
+    pb_issue_id    INTEGER NOT NULL,   -- IID задачи в GitLab
+    pb_title       TEXT NOT NULL,
+    pb_description TEXT NOT NULL DEFAULT '',
+    pb_labels      TEXT[] NOT NULL DEFAULT '{}',
+    pb_weight      INTEGER,
+    pb_created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pb_project_id, pb_issue_id)
+);
@@ -0,0 +1,8 @@
+-- Веха или итерация GitLab, из которой создан спринт
+ALTER TABLE sprint ADD COLUMN IF NOT EXISTS spt_milestone_id INTEGER;
+ALTER TABLE sprint ADD COLUMN IF NOT EXISTS spt_iteration_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sprint_milestone
+    ON sprint (spt_project_id, spt_milestone_id) WHERE spt_milestone_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sprint_iteration
+    ON sprint (spt_project_id, spt_iteration_id) WHERE spt_iteration_id IS NOT NULL;
@@ -0,0 +1,20 @@
+-- Серверные сессии пользователей после входа через GitLab OAuth.
+-- Токены GitLab хранятся зашифрованными (AES-GCM) и не передаются в браузер.
+CREATE TABLE IF NOT EXISTS sessions (
+    ses_id                CHAR(64) PRIMARY KEY,            -- SHA-256 от значения куки сессии
+    ses_user_id           INTEGER NOT NULL,                -- ID пользователя в GitLab
+    ses_username          VARCHAR(255) NOT NULL,
+    ses_name              VARCHAR(255) NOT NULL DEFAULT '',
+    ses_email             VARCHAR(255) NOT NULL DEFAULT '',
+    ses_access_token      BYTEA NOT NULL,                  -- зашифрованный токен доступа GitLab
+    ses_refresh_token     BYTEA,                           -- зашифрованный refresh-токен GitLab
+    ses_token_expires_at  TIMESTAMPTZ,
+    ses_user_agent        TEXT NOT NULL DEFAULT '',
+    ses_ip                VARCHAR(64) NOT NULL DEFAULT '',
+    ses_created_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ses_last_seen_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ses_expires_at        TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (ses_user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions (ses_expires_at);
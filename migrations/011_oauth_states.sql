@@ -0,0 +1,11 @@
+-- Незавершенные входы через GitLab OAuth: state, PKCE code_verifier и адрес возврата.
+-- Записи одноразовые и живут несколько минут.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    oas_state_hash    CHAR(64) PRIMARY KEY,        -- SHA-256 от параметра state
+    oas_code_verifier VARCHAR(128) NOT NULL,
+    oas_redirect_to   TEXT NOT NULL,
+    oas_created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    oas_expires_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires ON oauth_states (oas_expires_at);
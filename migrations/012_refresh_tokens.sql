@@ -0,0 +1,15 @@
+-- Refresh-токены для собственных JWT плагина. Токены ротируются: каждый используется
+-- один раз, повторное использование отзывает все семейство (признак кражи токена).
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    rt_id         CHAR(64) PRIMARY KEY,                 -- SHA-256 от значения токена
+    rt_family_id  CHAR(32) NOT NULL,                    -- цепочка токенов одного входа
+    rt_session_id CHAR(64) NOT NULL REFERENCES sessions (ses_id) ON DELETE CASCADE,
+    rt_user_id    INTEGER NOT NULL,                     -- ID пользователя в GitLab
+    rt_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rt_expires_at TIMESTAMPTZ NOT NULL,
+    rt_used_at    TIMESTAMPTZ,
+    rt_revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (rt_family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens (rt_session_id);
@@ -0,0 +1,10 @@
+-- Роли пользователей в отдельных проектах. Переопределяют глобальную роль
+-- из user_settings и роль, выведенную из уровня доступа в GitLab.
+CREATE TABLE IF NOT EXISTS project_roles (
+    pr_project_id INTEGER NOT NULL,                -- ID проекта в GitLab
+    pr_user_id    INTEGER NOT NULL,                -- ID пользователя в GitLab
+    pr_role       VARCHAR(50) NOT NULL,
+    pr_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pr_updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pr_project_id, pr_user_id)
+);
@@ -0,0 +1,20 @@
+-- Персональные токены доступа к API плагина для ботов, CI и скриптов.
+-- Значение токена показывается один раз при создании, хранится только его хеш.
+CREATE TABLE IF NOT EXISTS personal_tokens (
+    pat_id           SERIAL PRIMARY KEY,
+    pat_hash         CHAR(64) NOT NULL UNIQUE,       -- SHA-256 от значения токена
+    pat_prefix       VARCHAR(16) NOT NULL,           -- начало токена, чтобы узнать его в списке
+    pat_name         VARCHAR(255) NOT NULL,
+    pat_scopes       TEXT[] NOT NULL,
+    pat_user_id      INTEGER NOT NULL,               -- владелец: ID пользователя в GitLab
+    pat_username     VARCHAR(255) NOT NULL,
+    pat_user_name    VARCHAR(255) NOT NULL DEFAULT '',
+    pat_user_email   VARCHAR(255) NOT NULL DEFAULT '',
+    pat_created_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pat_expires_at   TIMESTAMPTZ NOT NULL,
+    pat_last_used_at TIMESTAMPTZ,
+    pat_last_used_ip VARCHAR(64),
+    pat_revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_tokens_user ON personal_tokens (pat_user_id);
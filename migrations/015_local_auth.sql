@@ -0,0 +1,28 @@
+-- Локальный вход по паролю для установок без GitLab SSO.
+-- Пароли в users.usr_password хранятся хешами bcrypt; оставшиеся открытые пароли
+-- приложение хеширует при запуске.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS usr_gitlab_id           INTEGER UNIQUE,            -- связанный пользователь GitLab
+    ADD COLUMN IF NOT EXISTS usr_failed_logins       INTEGER NOT NULL DEFAULT 0, -- неудачные попытки подряд
+    ADD COLUMN IF NOT EXISTS usr_locked_until        TIMESTAMPTZ,               -- вход заблокирован до
+    ADD COLUMN IF NOT EXISTS usr_password_changed_at TIMESTAMPTZ;
+
+-- Хеш bcrypt длиннее открытых паролей, под которые создавался столбец
+ALTER TABLE users ALTER COLUMN usr_password TYPE TEXT;
+
+-- Сессии локальных пользователей не имеют токена GitLab
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS ses_local_user_id INTEGER,                         -- пользователь из таблицы users
+    ALTER COLUMN ses_access_token DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_local_user ON sessions (ses_local_user_id);
+
+-- Одноразовые токены сброса пароля, которые выдает администратор
+CREATE TABLE IF NOT EXISTS password_resets (
+    pwr_id         CHAR(64) PRIMARY KEY,                 -- SHA-256 от значения токена
+    pwr_user_id    INTEGER NOT NULL,                     -- пользователь из таблицы users
+    pwr_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pwr_expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets (pwr_user_id);
@@ -0,0 +1,5 @@
+-- Секреты вебхуков хранятся зашифрованными (AES-GCM, ключ session-key), как токены
+-- сессий. Открытые секреты, сохраненные раньше, приложение шифрует при запуске.
+ALTER TABLE project_webhooks
+    ADD COLUMN IF NOT EXISTS pwh_secret_encrypted BYTEA,             -- зашифрованный секрет X-Gitlab-Token
+    ALTER COLUMN pwh_secret DROP NOT NULL;
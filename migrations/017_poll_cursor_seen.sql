@@ -0,0 +1,6 @@
+-- Номера мердж-реквестов и задач, уже обработанных с временем обновления, равным
+-- курсору. updated_after в GitLab включает границу: объекты, обновленные в ту же
+-- секунду, что и последний обработанный, приходят снова и отбрасываются по этим номерам.
+ALTER TABLE gitlab_poll_cursors
+    ADD COLUMN IF NOT EXISTS gpc_merge_requests_seen INTEGER[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS gpc_issues_seen         INTEGER[] NOT NULL DEFAULT '{}';
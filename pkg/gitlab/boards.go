@@ -0,0 +1,47 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// CreateLabel создает метку проекта
+func (c *Client) CreateLabel(ctx context.Context, projectID string, opts map[string]interface{}) (*Label, error) {
+	var label Label
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/labels", nil, opts, &label); err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// ListLabels получает все метки проекта
+func (c *Client) ListLabels(ctx context.Context, projectID string) ([]Label, error) {
+	return ListAll[Label](ctx, c, projectPath(projectID)+"/labels", nil)
+}
+
+// ListProjectBoards получает доски задач проекта
+func (c *Client) ListProjectBoards(ctx context.Context, projectID string) ([]Board, error) {
+	return ListAll[Board](ctx, c, projectPath(projectID)+"/boards", nil)
+}
+
+// CreateProjectBoard создает доску задач проекта
+func (c *Client) CreateProjectBoard(ctx context.Context, projectID, name string) (*Board, error) {
+	var board Board
+	body := map[string]interface{}{"name": name}
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/boards", nil, body, &board); err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// CreateBoardList добавляет на доску колонку для метки
+func (c *Client) CreateBoardList(ctx context.Context, projectID string, boardID, labelID int) (*BoardList, error) {
+	var list BoardList
+	path := projectPath(projectID) + "/boards/" + strconv.Itoa(boardID) + "/lists"
+	body := map[string]interface{}{"label_id": labelID}
+	if _, err := c.Do(ctx, http.MethodPost, path, nil, body, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
@@ -0,0 +1,129 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается без обращения к GitLab, пока автомат размыкания открыт
+var ErrCircuitOpen = errors.New("gitlab: GitLab временно недоступен")
+
+// CircuitOpenError сообщает, через сколько можно повторить запрос
+type CircuitOpenError struct {
+	BaseURL    string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("gitlab: автомат размыкания для %s открыт, повтор через %s", e.BaseURL, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// Параметры автомата размыкания по умолчанию
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker размыкается после threshold подряд неудачных обращений
+// и через cooldown пропускает один пробный запрос
+type circuitBreaker struct {
+	mu        sync.Mutex
+	baseURL   string
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+// breakerFor возвращает общий автомат размыкания для адреса GitLab
+func breakerFor(baseURL string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[baseURL]
+	if !ok {
+		b = &circuitBreaker{
+			baseURL:   baseURL,
+			threshold: DefaultBreakerThreshold,
+			cooldown:  DefaultBreakerCooldown,
+		}
+		breakers[baseURL] = b
+	}
+	return b
+}
+
+// allow проверяет, можно ли выполнить запрос
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.cooldown {
+			return &CircuitOpenError{BaseURL: b.baseURL, RetryAfter: b.cooldown - elapsed}
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{BaseURL: b.baseURL, RetryAfter: time.Second}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// success отмечает успешное обращение к GitLab
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// release отмечает запрос, прерванный вызывающей стороной: результат не говорит
+// о доступности GitLab, но пробный запрос больше не выполняется
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// failure отмечает недоступность GitLab (сетевая ошибка, 5xx)
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
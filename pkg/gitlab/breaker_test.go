@@ -0,0 +1,82 @@
+package gitlab
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerCycle(t *testing.T) {
+	b := &circuitBreaker{baseURL: "http://gitlab", threshold: 3, cooldown: time.Hour}
+
+	for i := range 2 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("попытка %d: %v", i+1, err)
+		}
+		b.failure()
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("автомат открылся до порога: %v", err)
+	}
+	b.failure()
+
+	err := b.allow()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("после порога ожидался ErrCircuitOpen, получено %v", err)
+	}
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.RetryAfter <= 0 || openErr.RetryAfter > time.Hour {
+		t.Errorf("RetryAfter = %v", openErr)
+	}
+
+	// Истекший cooldown: пропускается один пробный запрос
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.allow(); err != nil {
+		t.Fatalf("пробный запрос не пропущен: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("второй запрос во время пробы пропущен: %v", err)
+	}
+
+	// Неудачная проба снова размыкает автомат
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("после неудачной пробы ожидался ErrCircuitOpen, получено %v", err)
+	}
+
+	// Успешная проба замыкает автомат и сбрасывает счетчик
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.allow(); err != nil {
+		t.Fatalf("пробный запрос не пропущен: %v", err)
+	}
+	b.success()
+	if b.state != breakerClosed || b.failures != 0 {
+		t.Fatalf("state = %d, failures = %d после успеха", b.state, b.failures)
+	}
+	b.failure()
+	if err := b.allow(); err != nil {
+		t.Fatalf("одна ошибка после успеха разомкнула автомат: %v", err)
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	b := &circuitBreaker{baseURL: "http://gitlab", threshold: 1, cooldown: time.Hour}
+	b.failure()
+
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.allow(); err != nil {
+		t.Fatalf("пробный запрос не пропущен: %v", err)
+	}
+
+	// Отмененная проба не меняет состояние, но позволяет выполнить следующую
+	b.release()
+	if b.state != breakerHalfOpen {
+		t.Fatalf("state = %d, ожидалось полуоткрытое", b.state)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("после отмены пробы новый запрос не пропущен: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("второй запрос во время пробы пропущен: %v", err)
+	}
+}
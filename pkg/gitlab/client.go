@@ -0,0 +1,270 @@
+// Package gitlab реализует клиент GitLab REST API v4, используемый плагином:
+// таймауты запросов, типизированные ошибки и постраничное чтение списков через X-Next-Page.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout — таймаут запроса к GitLab по умолчанию
+const DefaultTimeout = 30 * time.Second
+
+// MaxPerPage — максимальный размер страницы, который принимает GitLab
+const MaxPerPage = 100
+
+// Client выполняет запросы к GitLab API от имени владельца токена
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	retry      RetryPolicy
+	breaker    *circuitBreaker
+}
+
+// NewClient создает клиент для GitLab, расположенного по адресу baseURL.
+// Клиенты с одинаковым адресом используют общий автомат размыкания.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: timeout},
+		retry:      DefaultRetryPolicy,
+		breaker:    breakerFor(baseURL),
+	}
+}
+
+// SetRetryPolicy задает политику повторов запросов
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// BaseURL возвращает адрес GitLab
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// WithToken возвращает копию клиента, выполняющую запросы с указанным токеном.
+// Токен принимается как в виде "Bearer <token>", так и без префикса.
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(token), "Bearer "))
+	return &clone
+}
+
+// Response содержит сведения о пагинации из заголовков ответа GitLab
+type Response struct {
+	StatusCode int `json:"-"`
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	NextPage   int `json:"next_page"`
+	PrevPage   int `json:"prev_page"`
+	TotalPages int `json:"total_pages"`
+	Total      int `json:"total"`
+}
+
+func newResponse(resp *http.Response) *Response {
+	header := func(name string) int {
+		value, _ := strconv.Atoi(resp.Header.Get(name))
+		return value
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Page:       header("X-Page"),
+		PerPage:    header("X-Per-Page"),
+		NextPage:   header("X-Next-Page"),
+		PrevPage:   header("X-Prev-Page"),
+		TotalPages: header("X-Total-Pages"),
+		Total:      header("X-Total"),
+	}
+}
+
+// Do выполняет запрос к GitLab API (path указывается относительно /api/v4)
+// и разбирает JSON-ответ в out, если он не nil
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*Response, error) {
+	endpoint := c.baseURL + "/api/v4" + path
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint += separator + query.Encode()
+	}
+
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("gitlab: ошибка маршалинга данных: %w", err)
+		}
+	}
+
+	newRequest := func() (*http.Request, error) {
+		var reqBody io.Reader
+		if jsonData != nil {
+			reqBody = bytes.NewReader(jsonData)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+		if err != nil {
+			return nil, err
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if jsonData != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	}
+
+	resp, respBody, err := c.send(ctx, method, path, newRequest)
+	if err != nil {
+		return resp, err
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp, fmt.Errorf("gitlab: ошибка парсинга ответа: %w", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// send выполняет запрос через автомат размыкания, повторяя его при 429
+// и временных ошибках согласно политике повторов
+func (c *Client) send(ctx context.Context, method, path string, newRequest func() (*http.Request, error)) (*Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			return nil, nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, fmt.Errorf("gitlab: ошибка создания запроса: %w", err)
+		}
+
+		statusCode := 0
+		var header http.Header
+		var respBody []byte
+		var response *Response
+
+		httpResp, err := c.httpClient.Do(req)
+		if err == nil {
+			respBody, err = io.ReadAll(httpResp.Body)
+			httpResp.Body.Close()
+			statusCode = httpResp.StatusCode
+			header = httpResp.Header
+			response = newResponse(httpResp)
+		}
+
+		switch {
+		case canceled(ctx, err):
+			c.breaker.release()
+		case unavailable(statusCode, err):
+			c.breaker.failure()
+		default:
+			c.breaker.success()
+		}
+
+		if attempt < c.retry.MaxRetries && ctx.Err() == nil && retryable(method, statusCode, err) {
+			if wait, ok := c.retry.delay(attempt+1, header); ok {
+				if sleepErr := sleep(ctx, wait); sleepErr == nil {
+					continue
+				}
+			}
+		}
+
+		if err != nil {
+			return response, nil, fmt.Errorf("gitlab: ошибка запроса %s %s: %w", method, path, err)
+		}
+		if statusCode < 200 || statusCode >= 300 {
+			return response, respBody, &Error{
+				Method:     method,
+				URL:        path,
+				StatusCode: statusCode,
+				Message:    errorMessage(respBody),
+			}
+		}
+		return response, respBody, nil
+	}
+}
+
+// errorMessage извлекает сообщение об ошибке из тела ответа GitLab.
+// GitLab возвращает {"message": "..."}, {"message": {"поле": ["..."]}} или {"error": "..."}.
+func errorMessage(body []byte) string {
+	var payload struct {
+		Message          interface{} `json:"message"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return strings.TrimSpace(string(body))
+	}
+
+	switch message := payload.Message.(type) {
+	case string:
+		return message
+	case nil:
+	default:
+		return fmt.Sprint(message)
+	}
+
+	if payload.ErrorDescription != "" {
+		return payload.ErrorDescription
+	}
+	return payload.Error
+}
+
+// ListPage получает одну страницу списка
+func ListPage[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, *Response, error) {
+	var items []T
+	resp, err := c.Do(ctx, http.MethodGet, path, query, nil, &items)
+	if err != nil {
+		return nil, resp, err
+	}
+	return items, resp, nil
+}
+
+// ListAll получает все страницы списка, следуя заголовку X-Next-Page
+func ListAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = append([]string(nil), values...)
+	}
+	if params.Get("per_page") == "" {
+		params.Set("per_page", strconv.Itoa(MaxPerPage))
+	}
+
+	all := []T{}
+	for {
+		items, resp, err := ListPage[T](ctx, c, path, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		params.Set("page", strconv.Itoa(resp.NextPage))
+	}
+}
+
+// projectPath формирует путь проекта; id может быть числом или путем "group/project"
+func projectPath(projectID string) string {
+	return "/projects/" + url.PathEscape(projectID)
+}
@@ -0,0 +1,142 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnprocessableEntity, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusInternalServerError, ErrServer},
+		{http.StatusBadGateway, ErrServer},
+		{http.StatusTeapot, ErrUnexpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("обертка: %w", &Error{StatusCode: tt.status})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ошибка %d не соответствует %v", tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"ошибка клиента передается как есть", &Error{StatusCode: http.StatusNotFound}, http.StatusNotFound},
+		{"ошибка сервера GitLab", &Error{StatusCode: http.StatusServiceUnavailable}, http.StatusBadGateway},
+		{"сетевая ошибка", errors.New("connection refused"), http.StatusBadGateway},
+		{"автомат размыкания открыт", &CircuitOpenError{BaseURL: "http://gitlab"}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err); got != tt.want {
+				t.Errorf("HTTPStatus() = %d, ожидалось %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"строка message", `{"message":"404 Project Not Found"}`, "404 Project Not Found"},
+		{"ошибки полей", `{"message":{"name":["has already been taken"]}}`, "map[name:[has already been taken]]"},
+		{"ошибка OAuth", `{"error":"invalid_grant","error_description":"The provided authorization grant is invalid"}`, "The provided authorization grant is invalid"},
+		{"только error", `{"error":"insufficient_scope"}`, "insufficient_scope"},
+		{"не JSON", " Bad Gateway \n", "Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorMessage([]byte(tt.body)); got != tt.want {
+				t.Errorf("errorMessage() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAllFollowsNextPage(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.URL.Query().Get("per_page"); got != strconv.Itoa(MaxPerPage) {
+			t.Errorf("per_page = %q", got)
+		}
+		if got := r.URL.Query().Get("state"); got != "opened" {
+			t.Errorf("state = %q", got)
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		fmt.Fprintf(w, `[{"iid":%d},{"iid":%d}]`, page*10+1, page*10+2)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 0).WithToken("Bearer secret")
+	issues, err := ListAll[Issue](context.Background(), client, "/projects/1/issues", map[string][]string{"state": {"opened"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 3 {
+		t.Errorf("запросов = %d, ожидалось 3", requests)
+	}
+	want := []int{11, 12, 21, 22, 31, 32}
+	if len(issues) != len(want) {
+		t.Fatalf("получено %d задач, ожидалось %d", len(issues), len(want))
+	}
+	for i, iid := range want {
+		if issues[i].IID != iid {
+			t.Errorf("задача %d: iid = %d, ожидалось %d", i, issues[i].IID, iid)
+		}
+	}
+}
+
+func TestDoReturnsTypedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"403 Forbidden"}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, 0).Do(context.Background(), http.MethodGet, "/projects/1", nil, nil, nil)
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("ошибка = %v, ожидалась ErrForbidden", err)
+	}
+	if got := Message(err); got != "403 Forbidden" {
+		t.Errorf("Message() = %q", got)
+	}
+}
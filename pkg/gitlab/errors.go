@@ -0,0 +1,86 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Ошибки, соответствующие классам ответов GitLab API
+var (
+	ErrBadRequest   = errors.New("gitlab: неверный запрос")
+	ErrUnauthorized = errors.New("gitlab: требуется авторизация")
+	ErrForbidden    = errors.New("gitlab: доступ запрещен")
+	ErrNotFound     = errors.New("gitlab: объект не найден")
+	ErrConflict     = errors.New("gitlab: конфликт")
+	ErrRateLimited  = errors.New("gitlab: превышен лимит запросов")
+	ErrServer       = errors.New("gitlab: ошибка сервера")
+	ErrUnexpected   = errors.New("gitlab: неожиданный ответ")
+)
+
+// Error описывает неуспешный ответ GitLab API
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gitlab: %s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// Unwrap позволяет сравнивать ошибку с ErrNotFound, ErrForbidden и т.д. через errors.Is
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return ErrUnexpected
+}
+
+// HTTPStatus возвращает HTTP-статус, который следует отдать клиенту плагина
+// при ошибке обращения к GitLab
+func HTTPStatus(err error) int {
+	if errors.Is(err, ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return http.StatusBadGateway
+	}
+
+	switch {
+	case apiErr.StatusCode >= 500:
+		return http.StatusBadGateway
+	case apiErr.StatusCode >= 400:
+		return apiErr.StatusCode
+	}
+	return http.StatusBadGateway
+}
+
+// Message возвращает сообщение об ошибке, пригодное для ответа клиенту
+func Message(err error) string {
+	if errors.Is(err, ErrCircuitOpen) {
+		return "GitLab временно недоступен, повторите запрос позже"
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Message != "" {
+		return apiErr.Message
+	}
+	return "Ошибка запроса к GitLab"
+}
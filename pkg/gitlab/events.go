@@ -0,0 +1,12 @@
+package gitlab
+
+import (
+	"context"
+	"net/url"
+)
+
+// ListProjectEvents получает все события проекта, удовлетворяющие фильтрам query
+// (action, target_type, after, before, sort)
+func (c *Client) ListProjectEvents(ctx context.Context, projectID string, query url.Values) ([]Event, error) {
+	return ListAll[Event](ctx, c, projectPath(projectID)+"/events", query)
+}
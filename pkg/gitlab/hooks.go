@@ -0,0 +1,48 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ListProjectHooks получает вебхуки проекта
+func (c *Client) ListProjectHooks(ctx context.Context, projectID string) ([]Hook, error) {
+	return ListAll[Hook](ctx, c, projectPath(projectID)+"/hooks", nil)
+}
+
+// GetProjectHook получает вебхук проекта по ID
+func (c *Client) GetProjectHook(ctx context.Context, projectID string, hookID int) (*Hook, error) {
+	var hook Hook
+	path := projectPath(projectID) + "/hooks/" + strconv.Itoa(hookID)
+	if _, err := c.Do(ctx, http.MethodGet, path, nil, nil, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// AddProjectHook регистрирует вебхук проекта
+func (c *Client) AddProjectHook(ctx context.Context, projectID string, opts map[string]interface{}) (*Hook, error) {
+	var hook Hook
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/hooks", nil, opts, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// EditProjectHook изменяет вебхук проекта
+func (c *Client) EditProjectHook(ctx context.Context, projectID string, hookID int, opts map[string]interface{}) (*Hook, error) {
+	var hook Hook
+	path := projectPath(projectID) + "/hooks/" + strconv.Itoa(hookID)
+	if _, err := c.Do(ctx, http.MethodPut, path, nil, opts, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// DeleteProjectHook удаляет вебхук проекта
+func (c *Client) DeleteProjectHook(ctx context.Context, projectID string, hookID int) error {
+	path := projectPath(projectID) + "/hooks/" + strconv.Itoa(hookID)
+	_, err := c.Do(ctx, http.MethodDelete, path, nil, nil, nil)
+	return err
+}
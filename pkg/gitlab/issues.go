@@ -0,0 +1,49 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListProjectIssues получает все задачи проекта, удовлетворяющие фильтрам query
+func (c *Client) ListProjectIssues(ctx context.Context, projectID string, query url.Values) ([]Issue, error) {
+	return ListAll[Issue](ctx, c, projectPath(projectID)+"/issues", query)
+}
+
+// GetIssue получает задачу проекта по внутреннему номеру (IID)
+func (c *Client) GetIssue(ctx context.Context, projectID string, issueIID int) (*Issue, error) {
+	var issue Issue
+	path := projectPath(projectID) + "/issues/" + strconv.Itoa(issueIID)
+	if _, err := c.Do(ctx, http.MethodGet, path, nil, nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// CreateIssue создает задачу в проекте
+func (c *Client) CreateIssue(ctx context.Context, projectID string, opts map[string]interface{}) (*Issue, error) {
+	var issue Issue
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/issues", nil, opts, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// UpdateIssue изменяет атрибуты задачи
+func (c *Client) UpdateIssue(ctx context.Context, projectID string, issueIID int, opts map[string]interface{}) (*Issue, error) {
+	var issue Issue
+	path := projectPath(projectID) + "/issues/" + strconv.Itoa(issueIID)
+	if _, err := c.Do(ctx, http.MethodPut, path, nil, opts, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// DeleteIssue удаляет задачу
+func (c *Client) DeleteIssue(ctx context.Context, projectID string, issueIID int) error {
+	path := projectPath(projectID) + "/issues/" + strconv.Itoa(issueIID)
+	_, err := c.Do(ctx, http.MethodDelete, path, nil, nil, nil)
+	return err
+}
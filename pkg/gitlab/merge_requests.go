@@ -0,0 +1,32 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateMergeRequest создает мердж-реквест в проекте
+func (c *Client) CreateMergeRequest(ctx context.Context, projectID string, opts map[string]interface{}) (*MergeRequest, error) {
+	var mr MergeRequest
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/merge_requests", nil, opts, &mr); err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+// ListProjectMergeRequests получает все мердж-реквесты проекта, удовлетворяющие фильтрам query
+func (c *Client) ListProjectMergeRequests(ctx context.Context, projectID string, query url.Values) ([]MergeRequest, error) {
+	return ListAll[MergeRequest](ctx, c, projectPath(projectID)+"/merge_requests", query)
+}
+
+// GetMergeRequest получает мердж-реквест по внутреннему номеру (IID)
+func (c *Client) GetMergeRequest(ctx context.Context, projectID string, mrIID int) (*MergeRequest, error) {
+	var mr MergeRequest
+	path := projectPath(projectID) + "/merge_requests/" + strconv.Itoa(mrIID)
+	if _, err := c.Do(ctx, http.MethodGet, path, nil, nil, &mr); err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
@@ -0,0 +1,42 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListProjectMilestones получает все вехи проекта, удовлетворяющие фильтрам query (state, search)
+func (c *Client) ListProjectMilestones(ctx context.Context, projectID string, query url.Values) ([]Milestone, error) {
+	return ListAll[Milestone](ctx, c, projectPath(projectID)+"/milestones", query)
+}
+
+// CreateMilestone создает веху проекта
+func (c *Client) CreateMilestone(ctx context.Context, projectID string, opts map[string]interface{}) (*Milestone, error) {
+	var milestone Milestone
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/milestones", nil, opts, &milestone); err != nil {
+		return nil, err
+	}
+	return &milestone, nil
+}
+
+// UpdateMilestone изменяет веху проекта (title, description, start_date, due_date, state_event)
+func (c *Client) UpdateMilestone(ctx context.Context, projectID string, milestoneID int, opts map[string]interface{}) (*Milestone, error) {
+	var milestone Milestone
+	path := projectPath(projectID) + "/milestones/" + strconv.Itoa(milestoneID)
+	if _, err := c.Do(ctx, http.MethodPut, path, nil, opts, &milestone); err != nil {
+		return nil, err
+	}
+	return &milestone, nil
+}
+
+// ListMilestoneIssues получает все задачи вехи проекта
+func (c *Client) ListMilestoneIssues(ctx context.Context, projectID string, milestoneID int) ([]Issue, error) {
+	return ListAll[Issue](ctx, c, projectPath(projectID)+"/milestones/"+strconv.Itoa(milestoneID)+"/issues", nil)
+}
+
+// ListGroupIterations получает итерации группы (доступны в GitLab Premium)
+func (c *Client) ListGroupIterations(ctx context.Context, groupID int, query url.Values) ([]Iteration, error) {
+	return ListAll[Iteration](ctx, c, "/groups/"+strconv.Itoa(groupID)+"/iterations", query)
+}
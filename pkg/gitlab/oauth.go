@@ -0,0 +1,61 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Token — ответ OAuth-эндпоинта GitLab
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// ExchangeToken выполняет запрос к /oauth/token с параметрами form
+// (authorization_code или refresh_token)
+func (c *Client) ExchangeToken(ctx context.Context, form url.Values) (*Token, error) {
+	var token Token
+	if err := c.postOAuthForm(ctx, "/oauth/token", form, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// postOAuthForm отправляет форму на OAuth-эндпоинт GitLab (вне /api/v4)
+func (c *Client) postOAuthForm(ctx context.Context, path string, form url.Values, out interface{}) error {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	}
+
+	_, body, err := c.send(ctx, http.MethodPost, path, newRequest)
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("gitlab: ошибка парсинга ответа: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevokeToken отзывает токен доступа или refresh-токен через /oauth/revoke (RFC 7009).
+// form содержит token и данные приложения (client_id, client_secret).
+func (c *Client) RevokeToken(ctx context.Context, form url.Values) error {
+	return c.postOAuthForm(ctx, "/oauth/revoke", form, nil)
+}
@@ -0,0 +1,63 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListProjects получает все проекты, доступные пользователю
+func (c *Client) ListProjects(ctx context.Context, query url.Values) ([]Project, error) {
+	return ListAll[Project](ctx, c, "/projects", query)
+}
+
+// GetProject получает проект по ID или пути
+func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	var project Project
+	if _, err := c.Do(ctx, http.MethodGet, projectPath(projectID), nil, nil, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// CreateProject создает проект; opts передаются в GitLab как есть
+func (c *Client) CreateProject(ctx context.Context, opts map[string]interface{}) (*Project, error) {
+	var project Project
+	if _, err := c.Do(ctx, http.MethodPost, "/projects", nil, opts, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// UpdateProject изменяет атрибуты проекта
+func (c *Client) UpdateProject(ctx context.Context, projectID string, opts map[string]interface{}) (*Project, error) {
+	var project Project
+	if _, err := c.Do(ctx, http.MethodPut, projectPath(projectID), nil, opts, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// DeleteProject удаляет проект
+func (c *Client) DeleteProject(ctx context.Context, projectID string) error {
+	_, err := c.Do(ctx, http.MethodDelete, projectPath(projectID), nil, nil, nil)
+	return err
+}
+
+// ListProjectMembers получает всех участников проекта, включая унаследованных от группы
+func (c *Client) ListProjectMembers(ctx context.Context, projectID string) ([]Member, error) {
+	return ListAll[Member](ctx, c, projectPath(projectID)+"/members/all", nil)
+}
+
+// CreateBranch создает ветку от ref
+func (c *Client) CreateBranch(ctx context.Context, projectID, branch, ref string) (*Branch, error) {
+	query := url.Values{}
+	query.Set("branch", branch)
+	query.Set("ref", ref)
+
+	var created Branch
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/repository/branches", query, nil, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
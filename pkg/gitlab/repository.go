@@ -0,0 +1,46 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListCommits получает коммиты репозитория, удовлетворяющие фильтрам query (ref_name, since, ...)
+func (c *Client) ListCommits(ctx context.Context, projectID string, query url.Values) ([]Commit, error) {
+	items, _, err := ListPage[Commit](ctx, c, projectPath(projectID)+"/repository/commits", query)
+	return items, err
+}
+
+// CompareCommits получает коммиты между from и to
+func (c *Client) CompareCommits(ctx context.Context, projectID, from, to string) ([]Commit, error) {
+	var comparison struct {
+		Commits []Commit `json:"commits"`
+	}
+	query := url.Values{"from": {from}, "to": {to}}
+	if _, err := c.Do(ctx, http.MethodGet, projectPath(projectID)+"/repository/compare", query, nil, &comparison); err != nil {
+		return nil, err
+	}
+	return comparison.Commits, nil
+}
+
+// CommitAction — изменение файла в коммите
+type CommitAction struct {
+	Action   string `json:"action"` // create, update, delete, move
+	FilePath string `json:"file_path"`
+	Content  string `json:"content,omitempty"`
+}
+
+// CreateCommit создает в ветке branch один коммит с изменениями actions
+func (c *Client) CreateCommit(ctx context.Context, projectID, branch, message string, actions []CommitAction) (*Commit, error) {
+	body := map[string]interface{}{
+		"branch":         branch,
+		"commit_message": message,
+		"actions":        actions,
+	}
+	var commit Commit
+	if _, err := c.Do(ctx, http.MethodPost, projectPath(projectID)+"/repository/commits", nil, body, &commit); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
@@ -0,0 +1,117 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy задает повторы запросов при 429 и временных ошибках GitLab
+type RetryPolicy struct {
+	MaxRetries    int           // количество повторов после первой попытки
+	BaseDelay     time.Duration // начальная задержка экспоненциальной паузы
+	MaxDelay      time.Duration // максимальная задержка между попытками
+	MaxRetryAfter time.Duration // Retry-After больше этого значения не ожидается
+}
+
+// DefaultRetryPolicy — политика повторов по умолчанию
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:    3,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      10 * time.Second,
+	MaxRetryAfter: time.Minute,
+}
+
+// retryable сообщает, можно ли повторить запрос с таким ответом.
+// 429 повторяется для любых методов: GitLab не выполнял запрос.
+// 502/503/504 и сетевые ошибки повторяются только для идемпотентных методов,
+// чтобы не создать объект в GitLab дважды.
+func retryable(method string, statusCode int, err error) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodHead ||
+		method == http.MethodPut || method == http.MethodDelete
+	if !idempotent {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// unavailable сообщает, считается ли ответ признаком недоступности GitLab для автомата
+// размыкания: сетевая ошибка (в том числе таймаут клиента) или 5xx. 429 — лимит
+// одного токена, а не отказ GitLab: он не должен размыкать автомат, общий для всех
+// пользователей и фоновой синхронизации.
+func unavailable(statusCode int, err error) bool {
+	return err != nil || statusCode >= 500
+}
+
+// canceled сообщает, что запрос прервала вызывающая сторона (клиент отключился или
+// истек срок контекста запроса), а не GitLab; такие ошибки автомат не учитывает
+func canceled(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled))
+}
+
+// delay вычисляет паузу перед попыткой attempt (начиная с 1): Retry-After, если GitLab
+// его прислал, иначе экспоненциальная пауза со случайным разбросом (full jitter)
+func (p RetryPolicy) delay(attempt int, header http.Header) (time.Duration, bool) {
+	if retryAfter, ok := parseRetryAfter(header); ok {
+		if retryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(backoff) + 1)), true
+}
+
+// parseRetryAfter разбирает заголовок Retry-After (секунды или HTTP-дата)
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleep ожидает d или отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
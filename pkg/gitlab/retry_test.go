@@ -0,0 +1,178 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	netErr := errors.New("connection reset")
+
+	tests := []struct {
+		name   string
+		method string
+		status int
+		err    error
+		want   bool
+	}{
+		{"429 для GET", http.MethodGet, http.StatusTooManyRequests, nil, true},
+		{"429 для POST", http.MethodPost, http.StatusTooManyRequests, nil, true},
+		{"503 для GET", http.MethodGet, http.StatusServiceUnavailable, nil, true},
+		{"502 для PUT", http.MethodPut, http.StatusBadGateway, nil, true},
+		{"504 для DELETE", http.MethodDelete, http.StatusGatewayTimeout, nil, true},
+		{"503 для POST", http.MethodPost, http.StatusServiceUnavailable, nil, false},
+		{"сетевая ошибка для GET", http.MethodGet, 0, netErr, true},
+		{"сетевая ошибка для POST", http.MethodPost, 0, netErr, false},
+		{"500 не повторяется", http.MethodGet, http.StatusInternalServerError, nil, false},
+		{"404 не повторяется", http.MethodGet, http.StatusNotFound, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.method, tt.status, tt.err); got != tt.want {
+				t.Errorf("retryable(%s, %d, %v) = %t, ожидалось %t", tt.method, tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"сетевая ошибка", 0, errors.New("timeout"), true},
+		{"500", http.StatusInternalServerError, nil, true},
+		{"503", http.StatusServiceUnavailable, nil, true},
+		{"429 не размыкает автомат", http.StatusTooManyRequests, nil, false},
+		{"404", http.StatusNotFound, nil, false},
+		{"200", http.StatusOK, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unavailable(tt.status, tt.err); got != tt.want {
+				t.Errorf("unavailable(%d, %v) = %t, ожидалось %t", tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"без ошибки", canceledCtx, nil, false},
+		{"контекст отменен", canceledCtx, errors.New("read: use of closed connection"), true},
+		{"ошибка отмены", context.Background(), fmt.Errorf("Get: %w", context.Canceled), true},
+		{"таймаут клиента GitLab", context.Background(), fmt.Errorf("Get: %w", context.DeadlineExceeded), false},
+		{"сетевая ошибка", context.Background(), errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canceled(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("canceled() = %t, ожидалось %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		approx bool
+		ok     bool
+	}{
+		{"секунды", "30", 30 * time.Second, false, true},
+		{"ноль", "0", 0, false, true},
+		{"HTTP-дата в будущем", future, 90 * time.Second, true, true},
+		{"HTTP-дата в прошлом", past, 0, false, true},
+		{"отрицательное значение", "-5", 0, false, false},
+		{"мусор", "скоро", 0, false, false},
+		{"нет заголовка", "", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+
+			got, ok := parseRetryAfter(header)
+			if ok != tt.ok {
+				t.Fatalf("ok = %t, ожидалось %t", ok, tt.ok)
+			}
+			if tt.approx {
+				if got <= tt.want-5*time.Second || got > tt.want {
+					t.Errorf("parseRetryAfter() = %s, ожидалось около %s", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseRetryAfter() = %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := parseRetryAfter(nil); ok {
+		t.Error("parseRetryAfter(nil) вернул ok")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxRetries:    3,
+		BaseDelay:     100 * time.Millisecond,
+		MaxDelay:      time.Second,
+		MaxRetryAfter: time.Minute,
+	}
+
+	t.Run("Retry-After в пределах лимита", func(t *testing.T) {
+		header := http.Header{"Retry-After": {"20"}}
+		got, ok := policy.delay(1, header)
+		if !ok || got != 20*time.Second {
+			t.Errorf("delay() = %s, %t", got, ok)
+		}
+	})
+
+	t.Run("Retry-After больше лимита", func(t *testing.T) {
+		header := http.Header{"Retry-After": {"120"}}
+		if _, ok := policy.delay(1, header); ok {
+			t.Error("delay() разрешил ожидание дольше MaxRetryAfter")
+		}
+	})
+
+	for _, attempt := range []int{1, 2, 3, 10, 64} {
+		t.Run(fmt.Sprintf("пауза попытки %d", attempt), func(t *testing.T) {
+			limit := policy.MaxDelay
+			if attempt < 10 {
+				limit = min(policy.BaseDelay<<(attempt-1), policy.MaxDelay)
+			}
+			for range 50 {
+				got, ok := policy.delay(attempt, nil)
+				if !ok || got < 0 || got > limit {
+					t.Fatalf("delay(%d) = %s, %t, ожидалось от 0 до %s", attempt, got, ok, limit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package gitlab
+
+import "time"
+
+// User — пользователь GitLab
+type User struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	State     string     `json:"state"`
+	AvatarURL string     `json:"avatar_url"`
+	WebURL    string     `json:"web_url"`
+	IsAdmin   bool       `json:"is_admin"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// Member — участник проекта с уровнем доступа
+type Member struct {
+	User
+	AccessLevel int     `json:"access_level"`
+	ExpiresAt   *string `json:"expires_at"`
+}
+
+// Уровни доступа участников проекта GitLab
+const (
+	GuestAccess      = 10
+	ReporterAccess   = 20
+	DeveloperAccess  = 30
+	MaintainerAccess = 40
+	OwnerAccess      = 50
+)
+
+// Project — проект GitLab
+type Project struct {
+	ID                int        `json:"id"`
+	Name              string     `json:"name"`
+	NameWithNamespace string     `json:"name_with_namespace"`
+	Path              string     `json:"path"`
+	PathWithNamespace string     `json:"path_with_namespace"`
+	Description       string     `json:"description"`
+	DefaultBranch     string     `json:"default_branch"`
+	Visibility        string     `json:"visibility"`
+	WebURL            string     `json:"web_url"`
+	CreatedAt         *time.Time `json:"created_at"`
+	LastActivityAt    *time.Time `json:"last_activity_at"`
+	Namespace         struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Path     string `json:"path"`
+		Kind     string `json:"kind"`
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	Permissions struct {
+		ProjectAccess *struct {
+			AccessLevel int `json:"access_level"`
+		} `json:"project_access"`
+		GroupAccess *struct {
+			AccessLevel int `json:"access_level"`
+		} `json:"group_access"`
+	} `json:"permissions"`
+}
+
+// AccessLevel возвращает уровень доступа текущего пользователя к проекту
+// с учетом доступа через группу
+func (p *Project) AccessLevel() int {
+	level := 0
+	if p.Permissions.ProjectAccess != nil {
+		level = p.Permissions.ProjectAccess.AccessLevel
+	}
+	if p.Permissions.GroupAccess != nil && p.Permissions.GroupAccess.AccessLevel > level {
+		level = p.Permissions.GroupAccess.AccessLevel
+	}
+	return level
+}
+
+// Milestone — веха GitLab
+type Milestone struct {
+	ID          int        `json:"id"`
+	IID         int        `json:"iid"`
+	ProjectID   int        `json:"project_id"`
+	GroupID     int        `json:"group_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	StartDate   string     `json:"start_date"`
+	DueDate     string     `json:"due_date"`
+	WebURL      string     `json:"web_url"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+// Iteration — итерация группы GitLab
+type Iteration struct {
+	ID          int        `json:"id"`
+	IID         int        `json:"iid"`
+	GroupID     int        `json:"group_id"`
+	SequenceID  int        `json:"sequence"`
+	Title       string     `json:"title"` // у итераций из каденции может быть пустым
+	Description string     `json:"description"`
+	State       int        `json:"state"` // 1 — upcoming, 2 — current, 3 — closed
+	StartDate   string     `json:"start_date"`
+	DueDate     string     `json:"due_date"`
+	WebURL      string     `json:"web_url"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+// Состояния итерации
+const (
+	IterationUpcoming = 1
+	IterationCurrent  = 2
+	IterationClosed   = 3
+)
+
+// Label — метка проекта
+type Label struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// Board — доска задач проекта
+type Board struct {
+	ID    int         `json:"id"`
+	Name  string      `json:"name"`
+	Lists []BoardList `json:"lists"`
+}
+
+// BoardList — колонка доски задач
+type BoardList struct {
+	ID       int    `json:"id"`
+	Label    *Label `json:"label"`
+	Position int    `json:"position"`
+}
+
+// Issue — задача GitLab
+type Issue struct {
+	ID          int        `json:"id"`
+	IID         int        `json:"iid"`
+	ProjectID   int        `json:"project_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	Labels      []string   `json:"labels"`
+	Assignees   []User     `json:"assignees"`
+	Author      *User      `json:"author"`
+	Milestone   *Milestone `json:"milestone"`
+	Weight      *int       `json:"weight"`
+	DueDate     *string    `json:"due_date"`
+	WebURL      string     `json:"web_url"`
+	MovedToID   *int       `json:"moved_to_id"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+}
+
+// MergeRequest — мердж-реквест GitLab
+type MergeRequest struct {
+	ID           int        `json:"id"`
+	IID          int        `json:"iid"`
+	ProjectID    int        `json:"project_id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	Draft        bool       `json:"draft"`
+	SourceBranch string     `json:"source_branch"`
+	TargetBranch string     `json:"target_branch"`
+	Author       *User      `json:"author"`
+	Assignees    []User     `json:"assignees"`
+	Reviewers    []User     `json:"reviewers"`
+	WebURL       string     `json:"web_url"`
+	CreatedAt    *time.Time `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+// Event — событие проекта из Events API
+type Event struct {
+	ID          int        `json:"id"`
+	ProjectID   int        `json:"project_id"`
+	ActionName  string     `json:"action_name"`
+	TargetType  string     `json:"target_type"`
+	TargetID    int        `json:"target_id"`
+	TargetIID   int        `json:"target_iid"`
+	TargetTitle string     `json:"target_title"`
+	Author      *User      `json:"author"`
+	PushData    *PushData  `json:"push_data"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+// PushData — сведения о push в событии проекта
+type PushData struct {
+	CommitCount int     `json:"commit_count"`
+	Action      string  `json:"action"` // pushed, created, removed
+	RefType     string  `json:"ref_type"`
+	CommitFrom  *string `json:"commit_from"`
+	CommitTo    *string `json:"commit_to"`
+	Ref         string  `json:"ref"`
+	CommitTitle string  `json:"commit_title"`
+}
+
+// Commit — коммит репозитория
+type Commit struct {
+	ID            string     `json:"id"`
+	ShortID       string     `json:"short_id"`
+	Title         string     `json:"title"`
+	Message       string     `json:"message"`
+	AuthorName    string     `json:"author_name"`
+	AuthorEmail   string     `json:"author_email"`
+	CommittedDate *time.Time `json:"committed_date"`
+	ParentIDs     []string   `json:"parent_ids"`
+	WebURL        string     `json:"web_url"`
+}
+
+// Branch — ветка репозитория
+type Branch struct {
+	Name    string `json:"name"`
+	Merged  bool   `json:"merged"`
+	Default bool   `json:"default"`
+	WebURL  string `json:"web_url"`
+	Commit  struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"commit"`
+}
+
+// Hook — вебхук проекта
+type Hook struct {
+	ID                    int        `json:"id"`
+	URL                   string     `json:"url"`
+	ProjectID             int        `json:"project_id"`
+	PushEvents            bool       `json:"push_events"`
+	IssuesEvents          bool       `json:"issues_events"`
+	MergeRequestsEvents   bool       `json:"merge_requests_events"`
+	NoteEvents            bool       `json:"note_events"`
+	PipelineEvents        bool       `json:"pipeline_events"`
+	EnableSSLVerification bool       `json:"enable_ssl_verification"`
+	AlertStatus           string     `json:"alert_status"` // executable, temporarily_disabled, disabled
+	DisabledUntil         *time.Time `json:"disabled_until"`
+	CreatedAt             *time.Time `json:"created_at"`
+}
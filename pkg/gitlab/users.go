@@ -0,0 +1,21 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CurrentUser получает владельца токена
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if _, err := c.Do(ctx, http.MethodGet, "/user", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers получает всех пользователей GitLab, удовлетворяющих фильтрам query
+func (c *Client) ListUsers(ctx context.Context, query url.Values) ([]User, error) {
+	return ListAll[User](ctx, c, "/users", query)
+}
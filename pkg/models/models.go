@@ -7,27 +7,43 @@ import (
 
 var ErrNoRecord = errors.New("models: подходящей записи не найдено!")
 
+// ErrDuplicate возвращается при нарушении уникальности записи
+var ErrDuplicate = errors.New("models: запись уже существует")
+
 type User struct {
 	UsrID         int    `db:"usr_id"`
 	UsrUsername   string `db:"usr_username"`   // Поле для хранения логина пользователя
 	UsrEmail      string `db:"usr_email"`      // Поле для хранения email
-	UsrPassword   string `db:"usr_password"`   // Поле для хранения пароля
+	UsrPassword   string `db:"usr_password"`   // Хеш пароля (bcrypt)
 	UsrRole       string `db:"usr_role"`       // Роль пользователя
 	UsrName       string `db:"usr_name"`       // Имя пользователя
 	UsrPatronomic string `db:"usr_patronomic"` // Отчество пользователя
 	UsrSurname    string `db:"usr_surname"`    // Фамилия пользователя
+	// Локальный вход
+	UsrGitLabID     *int       `db:"usr_gitlab_id"`     // связанный пользователь GitLab
+	UsrFailedLogins int        `db:"usr_failed_logins"` // неудачные попытки входа подряд
+	UsrLockedUntil  *time.Time `db:"usr_locked_until"`  // вход заблокирован до
 }
 
 type Project struct {
-	PrjID          int       `db:"prj_id"`
-	PrjTitle       string    `db:"prj_title"`
-	PrjDescription string    `db:"prj_description"`
-	PrjStartDate   time.Time `db:"prj_start_date"`
-	PrjEndDate     time.Time `db:"prj_end_date"`
-	PrjStatus      string    `db:"prj_status"`
-	PrjOwner       string    `db:"prj_owner"`
+	PrjID            int       `db:"prj_id" json:"id"`
+	PrjTitle         string    `db:"prj_title" json:"title"`
+	PrjDescription   string    `db:"prj_description" json:"description"`
+	PrjStartDate     time.Time `db:"prj_start_date" json:"start_date"`
+	PrjEndDate       time.Time `db:"prj_end_date" json:"end_date"`
+	PrjStatus        string    `db:"prj_status" json:"status"`
+	PrjOwner         string    `db:"prj_owner" json:"owner"`
+	PrjGitLabID      *int      `db:"prj_gitlab_id" json:"gitlab_id"`
+	PrjGitLabOwnerID *int      `db:"prj_gitlab_owner_id" json:"gitlab_owner_id"`
+	PrjWebURL        string    `db:"prj_web_url" json:"web_url"`
 }
 
+// Статусы локального проекта
+const (
+	ProjectStatusCreating = "creating" // проект создается в GitLab
+	ProjectStatusActive   = "active"
+)
+
 type Tasks struct {
 	TskId          int     `db:"tsk_id"`
 	TskPrjId       int     `db:"tsk_prj_id"`
@@ -40,18 +56,195 @@ type Tasks struct {
 
 // SprintIssue представляет задачу в спринте
 type SprintIssue struct {
-    SprintID    int       `json:"sprint_id"`
-    IssueID     int       `json:"issue_id"`
-    StoryPoints int       `json:"story_points"`
-    Priority    string    `json:"priority"`
-    Title       string    `json:"title"`
-    Description string    `json:"description"`
-    Status      string    `json:"status"`
-    AssignedTo  *int      `json:"assigned_to"`
-    LastCommit  time.Time `json:"last_commit,omitempty"`
-    LastMerge   time.Time `json:"last_merge,omitempty"`
-    BranchName  string    `json:"branch_name,omitempty"`
-    MRID        *int      `json:"mr_id,omitempty"`
+    SprintID     int        `json:"sprint_id"`
+    IssueID      int        `json:"issue_id"`
+    StoryPoints  int        `json:"story_points"`
+    Priority     string     `json:"priority"`
+    Title        string     `json:"title"`
+    Description  string     `json:"description"`
+    Status       string     `json:"status"`
+    AssignedTo   *int       `json:"assigned_to"`
+    LastCommit   time.Time  `json:"last_commit,omitempty"`
+    LastMerge    time.Time  `json:"last_merge,omitempty"`
+    BranchName   string     `json:"branch_name,omitempty"`
+    MRID         *int       `json:"mr_id,omitempty"`
+    Labels       []string   `json:"labels"`
+    AssigneeIDs  []int      `json:"assignee_ids"`
+    Weight       *int       `json:"weight"`
+    DueDate      *time.Time `json:"due_date"`
+    GitLabState  string     `json:"gitlab_state"`
+    MovedToID    *int       `json:"moved_to_id,omitempty"`
+    LastSyncedAt *time.Time `json:"last_synced_at"`
+}
+
+// GitLabIssueSnapshot содержит поля задачи GitLab, переносимые в задачу спринта при сверке
+type GitLabIssueSnapshot struct {
+	Title       string
+	Description string
+	Labels      []string
+	AssigneeIDs []int
+	Weight      *int
+	DueDate     *time.Time
+	State       string
+	MovedToID   *int
+}
+
+// SyncDiscrepancy описывает расхождение задачи спринта с GitLab
+type SyncDiscrepancy struct {
+	ID          int       `json:"id"`
+	SprintID    int       `json:"sprint_id"`
+	IssueID     int       `json:"issue_id"`
+	ProjectID   int       `json:"project_id"`
+	Kind        string    `json:"kind"`
+	Field       string    `json:"field"`
+	LocalValue  string    `json:"local_value"`
+	GitLabValue string    `json:"gitlab_value"`
+	Reason      string    `json:"reason"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// Виды расхождений с GitLab
+const (
+	DiscrepancyChanged = "changed" // поле изменено в GitLab и обновлено локально
+	DiscrepancyStatus  = "status"  // статус задачи изменен из-за состояния в GitLab
+	DiscrepancyDeleted = "deleted" // задача удалена в GitLab
+	DiscrepancyMoved   = "moved"   // задача перенесена в другой проект
+)
+
+// MergeRequest представляет мердж-реквест GitLab, ссылающийся на задачу спринта
+type MergeRequest struct {
+	SprintID       int        `json:"sprint_id"`
+	IssueID        int        `json:"issue_id"`
+	ProjectID      int        `json:"project_id"`
+	MRID           int        `json:"mr_id"`
+	MRIID          int        `json:"mr_iid"`
+	Title          string     `json:"title"`
+	State          string     `json:"state"`
+	AuthorID       int        `json:"author_id"`
+	AuthorUsername string     `json:"author_username"`
+	Reviewers      []string   `json:"reviewers"`
+	ApprovedBy     []string   `json:"approved_by"`
+	SourceBranch   string     `json:"source_branch"`
+	TargetBranch   string     `json:"target_branch"`
+	WebURL         string     `json:"web_url"`
+	CreatedAt      *time.Time `json:"created_at"`
+	MergedAt       *time.Time `json:"merged_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PollCursor хранит позицию опроса GitLab по проекту, чтобы не обрабатывать события повторно
+type PollCursor struct {
+	ProjectID          int       `json:"project_id"`
+	LastEventID        int       `json:"last_event_id"`
+	EventsSince        time.Time `json:"events_since"`
+	MergeRequestsSince time.Time `json:"merge_requests_since"`
+	IssuesSince        time.Time `json:"issues_since"`
+	// Номера MR и задач, уже обработанных с временем обновления, равным курсору
+	MergeRequestsSeen []int `json:"merge_requests_seen"`
+	IssuesSeen        []int `json:"issues_seen"`
+}
+
+// ProjectWebhook — вебхук, зарегистрированный приложением в проекте GitLab
+type ProjectWebhook struct {
+	ProjectID       int       `json:"project_id"`
+	HookID          int       `json:"hook_id"`
+	URL             string    `json:"url"`
+	Secret          string    `json:"-"` // открытый секрет, в базе не хранится
+	SecretEncrypted []byte    `json:"-"` // секрет, зашифрованный ключом сессий
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// BacklogIssue — задача GitLab в бэклоге проекта
+type BacklogIssue struct {
+	ProjectID   int       `json:"project_id"`
+	IssueID     int       `json:"issue_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Labels      []string  `json:"labels"`
+	Weight      *int      `json:"weight"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Session — серверная сессия пользователя. Токены GitLab хранятся в зашифрованном виде.
+type Session struct {
+	ID                    string     `json:"-"` // SHA-256 от значения куки
+	UserID                int        `json:"user_id"`
+	Username              string     `json:"username"`
+	Name                  string     `json:"name"`
+	Email                 string     `json:"email"`
+	AccessTokenEncrypted  []byte     `json:"-"`
+	RefreshTokenEncrypted []byte     `json:"-"`
+	TokenExpiresAt        *time.Time `json:"-"`
+	LocalUserID           *int       `json:"local_user_id"` // пользователь из таблицы users при локальном входе
+	UserAgent             string     `json:"user_agent"`
+	IP                    string     `json:"ip"`
+	CreatedAt             time.Time  `json:"created_at"`
+	LastSeenAt            time.Time  `json:"last_seen_at"`
+	ExpiresAt             time.Time  `json:"expires_at"`
+}
+
+// OAuthState — незавершенный вход через GitLab OAuth
+type OAuthState struct {
+	StateHash    string
+	CodeVerifier string
+	RedirectTo   string
+	ExpiresAt    time.Time
+}
+
+// RefreshToken — refresh-токен собственных JWT плагина
+type RefreshToken struct {
+	ID        string // SHA-256 от значения токена
+	FamilyID  string
+	SessionID string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// ErrTokenReused возвращается при повторном использовании refresh-токена
+var ErrTokenReused = errors.New("models: refresh-токен уже использован")
+
+// Check проверяет, можно ли обменять refresh-токен: отозванный или истекший токен —
+// ErrNoRecord, уже использованный — ErrTokenReused (цепочку нужно отозвать)
+func (t *RefreshToken) Check(now time.Time) error {
+	if t.RevokedAt != nil || !t.ExpiresAt.After(now) {
+		return ErrNoRecord
+	}
+	if t.UsedAt != nil {
+		return ErrTokenReused
+	}
+	return nil
+}
+
+// PersonalToken — персональный токен доступа к API плагина. Хранится только
+// хеш значения; действует от имени владельца в пределах своих областей (scopes).
+type PersonalToken struct {
+	ID         int        `json:"id"`
+	Hash       string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	UserName   string     `json:"-"`
+	UserEmail  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ProjectRole — роль пользователя в отдельном проекте, назначенная вручную
+type ProjectRole struct {
+	ProjectID int       `json:"project_id"`
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type UserSettings struct {
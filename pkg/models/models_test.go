@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenCheck(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+
+	tests := []struct {
+		name  string
+		token RefreshToken
+		want  error
+	}{
+		{"действующий токен", RefreshToken{ExpiresAt: now.Add(time.Hour)}, nil},
+		{"повторное использование", RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier}, ErrTokenReused},
+		{"отозванный токен", RefreshToken{ExpiresAt: now.Add(time.Hour), RevokedAt: &earlier}, ErrNoRecord},
+		{"отозванный после повторного использования", RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier, RevokedAt: &earlier}, ErrNoRecord},
+		{"истекший токен", RefreshToken{ExpiresAt: now}, ErrNoRecord},
+		{"истекший использованный токен", RefreshToken{ExpiresAt: earlier, UsedAt: &earlier}, ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.token.Check(now); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("Check() = %v, ожидалось %v", err, tt.want)
+			}
+		})
+	}
+}
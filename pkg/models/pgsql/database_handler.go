@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "golang.org/x/text/date"
 	"golangify.com/plaginagile/pkg/models"
@@ -17,32 +18,6 @@ type PullIncludes struct {
 	DB *pgxpool.Pool
 }
 
-func (pl *PullIncludes) IsValidUser(username, password string) (*models.User, error) {
-	user := &models.User{}
-	stmt := "SELECT usr_id, usr_username, usr_email, usr_password, usr_role, usr_name, usr_patronomic, usr_surname FROM users WHERE usr_username=$1"
-
-	err := pl.DB.QueryRow(context.Background(), stmt, username).Scan(
-		&user.UsrID,
-		&user.UsrUsername,
-		&user.UsrEmail,
-		&user.UsrPassword,
-		&user.UsrRole,
-		&user.UsrName,
-		&user.UsrPatronomic,
-		&user.UsrSurname,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// Прямое сравнение пароля
-	if user.UsrPassword != password {
-		return nil, fmt.Errorf("invalid credentials")
-	}
-
-	return user, nil
-}
-
 func (pl *PullIncludes) GetUsers() ([]models.User, error) {
 	stmt := "SELECT usr_id ,usr_name, usr_patronomic, usr_surname, usr_role FROM users"
 	rows, err := pl.DB.Query(context.Background(), stmt)
@@ -64,7 +39,8 @@ func (pl *PullIncludes) GetUsers() ([]models.User, error) {
 	return users, nil
 }
 
-func (pl *PullIncludes) CreateProject(name, description, startDate, endDate, prj_status string, ownerID int) (int, error) {
+// CreateProject создает локальный проект; ownerID — пользователь из таблицы users, может отсутствовать
+func (pl *PullIncludes) CreateProject(name, description, startDate, endDate, prj_status string, ownerID *int) (int, error) {
 	var projectID int
 	query := "INSERT INTO projects (prj_title, prj_description, prj_start_date, prj_end_date, prj_status, prj_owner) VALUES ($1, $2, $3, $4, $5, $6) RETURNING prj_id"
 	err := pl.DB.QueryRow(context.Background(), query, name, description, startDate, endDate, prj_status, ownerID).Scan(&projectID)
@@ -107,7 +83,9 @@ func (pl *PullIncludes) GetProject(prj_id int) (models.Project, error) {
 
 // prj_id, prj_title, prj_description, prj_start_date, prj_end_date string, prj_status, prj_owner
 func (pl *PullIncludes) GetProjects() ([]models.Project, error) {
-	stmt := "SELECT prj_id, prj_title, prj_description, prj_start_date, prj_end_date, prj_status, prj_owner FROM projects"
+	stmt := `SELECT prj_id, prj_title, prj_description, prj_start_date, prj_end_date, prj_status,
+		COALESCE(prj_owner::text, ''), prj_gitlab_id, prj_gitlab_owner_id, prj_web_url
+		FROM projects ORDER BY prj_id`
 	rows, err := pl.DB.Query(context.Background(), stmt)
 	if err != nil {
 		log.Println("Ошибка выполнения запроса:", err)
@@ -126,20 +104,24 @@ func (pl *PullIncludes) GetProjects() ([]models.Project, error) {
 			&project.PrjEndDate,
 			&project.PrjStatus,
 			&project.PrjOwner,
+			&project.PrjGitLabID,
+			&project.PrjGitLabOwnerID,
+			&project.PrjWebURL,
 		)
 		if err != nil {
 			log.Println("Ошибка чтения строки:", err)
 			return nil, errors.New("Ошибка обработки данных")
 		}
 
-		fmt.Printf(project.PrjTitle)
-
-		ownerSurnameNamePatronomic, err := pl.GetUser(project.PrjOwner)
-		if err != nil {
-			return nil, errors.New("Ошибка получения пользователя")
+		// Проекты, созданные через GitLab, могут не иметь локального владельца
+		if project.PrjOwner != "" {
+			ownerSurnameNamePatronomic, err := pl.GetUser(project.PrjOwner)
+			if err != nil {
+				return nil, errors.New("Ошибка получения пользователя")
+			}
+			project.PrjOwner = ownerSurnameNamePatronomic
 		}
 
-		project.PrjOwner = ownerSurnameNamePatronomic
 		projects = append(projects, project)
 	}
 
@@ -214,6 +196,74 @@ func (pl *PullIncludes) DeleteProject(prj_id int) error {
 	return err
 }
 
+// AttachGitLabProject связывает локальный проект с созданным проектом GitLab и делает его активным
+func (pl *PullIncludes) AttachGitLabProject(prjID, gitlabID, gitlabOwnerID int, webURL string) error {
+	query := `
+		UPDATE projects
+		SET prj_gitlab_id = $2, prj_gitlab_owner_id = $3, prj_web_url = $4, prj_status = $5
+		WHERE prj_id = $1
+	`
+	result, err := pl.DB.Exec(context.Background(), query, prjID, gitlabID, gitlabOwnerID, webURL, models.ProjectStatusActive)
+	if err != nil {
+		return fmt.Errorf("не удалось связать проект с GitLab: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// GetProjectByGitLabID получает локальный проект по ID проекта GitLab
+func (pl *PullIncludes) GetProjectByGitLabID(gitlabID int) (*models.Project, error) {
+	query := `
+		SELECT prj_id, prj_title, prj_description, prj_start_date, prj_end_date, prj_status,
+			COALESCE(prj_owner::text, ''), prj_gitlab_id, prj_gitlab_owner_id, prj_web_url
+		FROM projects
+		WHERE prj_gitlab_id = $1
+	`
+	var project models.Project
+	err := pl.DB.QueryRow(context.Background(), query, gitlabID).Scan(
+		&project.PrjID,
+		&project.PrjTitle,
+		&project.PrjDescription,
+		&project.PrjStartDate,
+		&project.PrjEndDate,
+		&project.PrjStatus,
+		&project.PrjOwner,
+		&project.PrjGitLabID,
+		&project.PrjGitLabOwnerID,
+		&project.PrjWebURL,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("не удалось получить проект: %w", err)
+	}
+	return &project, nil
+}
+
+// DeleteProjectWithParticipants удаляет локальный проект вместе с участниками
+func (pl *PullIncludes) DeleteProjectWithParticipants(prjID int) error {
+	tx, err := pl.DB.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(context.Background(), "DELETE FROM users_projects WHERE prt_prj_id = $1", prjID); err != nil {
+		return fmt.Errorf("не удалось удалить участников проекта: %w", err)
+	}
+	if _, err := tx.Exec(context.Background(), "DELETE FROM projects WHERE prj_id = $1", prjID); err != nil {
+		return fmt.Errorf("не удалось удалить проект: %w", err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return nil
+}
+
 func (pl *PullIncludes) DeleteTask(tsk_id int) error {
 	query := "DELETE FROM tasks WHERE tsk_id = $1"
 	_, err := pl.DB.Exec(context.Background(), query, tsk_id)
@@ -224,15 +274,17 @@ func (pl *PullIncludes) DeleteTask(tsk_id int) error {
 }
 
 type Sprint struct {
-	SptID        int       `json:"spt_id"`
-	SptTitle     string    `json:"spt_title"`
-	SptStartDate time.Time `json:"spt_start_date"`
-	SptEndDate   time.Time `json:"spt_end_date"`
-	SptGoals     string    `json:"spt_goals"`
-	SptProjectID int       `json:"spt_project_id"`
-	SptStatus    string    `json:"spt_status"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	SptID          int       `json:"spt_id"`
+	SptTitle       string    `json:"spt_title"`
+	SptStartDate   time.Time `json:"spt_start_date"`
+	SptEndDate     time.Time `json:"spt_end_date"`
+	SptGoals       string    `json:"spt_goals"`
+	SptProjectID   int       `json:"spt_project_id"`
+	SptStatus      string    `json:"spt_status"`
+	SptMilestoneID *int      `json:"spt_milestone_id"` // веха GitLab, связанная со спринтом
+	SptIterationID *int      `json:"spt_iteration_id"` // итерация GitLab, из которой импортирован спринт
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 func (pl *PullIncludes) CreateSprint(title string, startDate, endDate time.Time, goals string, projectID int) (int, error) {
@@ -263,7 +315,8 @@ func (pl *PullIncludes) CreateSprint(title string, startDate, endDate time.Time,
 // GetSprints получает список спринтов для проекта
 func (pl *PullIncludes) GetSprints(projectID int) ([]Sprint, error) {
 	query := `
-		SELECT spt_id, spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id, created_at, updated_at, spt_status
+		SELECT spt_id, spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id, created_at, updated_at, spt_status,
+			spt_milestone_id, spt_iteration_id
 		FROM sprint
 		WHERE spt_project_id = $1
 		ORDER BY spt_start_date DESC
@@ -288,6 +341,8 @@ func (pl *PullIncludes) GetSprints(projectID int) ([]Sprint, error) {
 			&sprint.CreatedAt,
 			&sprint.UpdatedAt,
 			&sprint.SptStatus,
+			&sprint.SptMilestoneID,
+			&sprint.SptIterationID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка при сканировании спринта: %v", err)
@@ -332,7 +387,8 @@ func (pl *PullIncludes) AddIssueToSprint(sprintID, issueID int, storyPoints int,
 func (pl *PullIncludes) GetSprint(sprintID int) (Sprint, error) {
 	var sprint Sprint
 	query := `
-		SELECT spt_id, spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id, created_at, updated_at
+		SELECT spt_id, spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id, created_at, updated_at,
+			COALESCE(spt_status, ''), spt_milestone_id, spt_iteration_id
 		FROM sprint
 		WHERE spt_id = $1
 	`
@@ -346,6 +402,9 @@ func (pl *PullIncludes) GetSprint(sprintID int) (Sprint, error) {
 		&sprint.SptProjectID,
 		&sprint.CreatedAt,
 		&sprint.UpdatedAt,
+		&sprint.SptStatus,
+		&sprint.SptMilestoneID,
+		&sprint.SptIterationID,
 	)
 
 	if err != nil {
@@ -377,7 +436,15 @@ func (pl *PullIncludes) GetSprintIssues(sprintID int) ([]models.SprintIssue, err
             si_name_issues,
             si_description_issue,
             COALESCE(si_agile_status, 'To Do') as si_status,
-            si_assigned_to
+            si_assigned_to,
+            si_branch_name,
+            si_labels,
+            si_assignee_ids,
+            si_weight,
+            si_due_date,
+            si_gitlab_state,
+            si_moved_to_id,
+            si_last_synced_at
         FROM sprint_issues
         WHERE si_sprint_id = $1
     `
@@ -392,6 +459,7 @@ func (pl *PullIncludes) GetSprintIssues(sprintID int) ([]models.SprintIssue, err
     for rows.Next() {
         var issue models.SprintIssue
         var assignedTo *int
+        var branchName *string
         err := rows.Scan(
             &issue.SprintID,
             &issue.IssueID,
@@ -401,11 +469,22 @@ func (pl *PullIncludes) GetSprintIssues(sprintID int) ([]models.SprintIssue, err
             &issue.Description,
             &issue.Status,
             &assignedTo,
+            &branchName,
+            &issue.Labels,
+            &issue.AssigneeIDs,
+            &issue.Weight,
+            &issue.DueDate,
+            &issue.GitLabState,
+            &issue.MovedToID,
+            &issue.LastSyncedAt,
         )
         if err != nil {
             return nil, fmt.Errorf("ошибка при сканировании задачи: %w", err)
         }
         issue.AssignedTo = assignedTo
+        if branchName != nil {
+            issue.BranchName = *branchName
+        }
         issues = append(issues, issue)
     }
 
@@ -416,52 +495,27 @@ func (pl *PullIncludes) GetSprintIssues(sprintID int) ([]models.SprintIssue, err
     return issues, nil
 }
 
-// UpdateSprintIssueStatus обновляет статус задачи на основе GitLab событий
+// UpdateSprintIssueStatus обновляет статус задачи на основе GitLab событий.
+// Статус вычисляется вызывающей стороной по правилам models.NextIssueStatus,
+// временные метки и данные ветки/MR сохраняются, только если переданы.
 func (pl *PullIncludes) UpdateSprintIssueStatus(sprintID, issueID int, status string, lastCommit, lastMerge *time.Time, branchName string, mrID *int) error {
-    // Получаем текущую информацию о задаче
-    var currentStatus string
-    var currentAssignee *int
-    var currentLastCommit, currentLastMerge *time.Time
-    
-    err := pl.DB.QueryRow(context.Background(), 
-        `SELECT si_agile_status, si_assigned_to, si_last_commit, si_last_merge 
-         FROM sprint_issues 
-         WHERE si_sprint_id = $1 AND si_issue_id = $2`,
-        sprintID, issueID).Scan(&currentStatus, &currentAssignee, &currentLastCommit, &currentLastMerge)
-    
-    if err != nil {
-        return fmt.Errorf("ошибка при получении текущего статуса задачи: %w", err)
-    }
-
-    // Определяем новый статус на основе условий
-    var newStatus string
-    if lastMerge != nil {
-        newStatus = "Готово"
-    } else if lastCommit != nil {
-        newStatus = "На проверке"
-    } else if currentAssignee != nil {
-        newStatus = "В работе"
-    } else {
-        newStatus = "К выполнению"
-    }
-
     query := `
         UPDATE sprint_issues 
         SET 
             si_agile_status = $3,
             si_last_commit = COALESCE($4, si_last_commit),
             si_last_merge = COALESCE($5, si_last_merge),
-            si_branch_name = COALESCE($6, si_branch_name),
+            si_branch_name = COALESCE(NULLIF($6, ''), si_branch_name),
             si_mr_id = COALESCE($7, si_mr_id)
         WHERE si_sprint_id = $1 AND si_issue_id = $2
     `
 
-    _, err = pl.DB.Exec(
+    result, err := pl.DB.Exec(
         context.Background(),
         query,
         sprintID,
         issueID,
-        newStatus,
+        status,
         lastCommit,
         lastMerge,
         branchName,
@@ -472,6 +526,10 @@ func (pl *PullIncludes) UpdateSprintIssueStatus(sprintID, issueID int, status st
         return fmt.Errorf("не удалось обновить статус задачи: %w", err)
     }
 
+    if result.RowsAffected() == 0 {
+        return models.ErrNoRecord
+    }
+
     return nil
 }
 
@@ -535,29 +593,27 @@ func (pl *PullIncludes) UpdateSprintIssueAssignee(sprintID, issueID, assigneeID
     }
     defer tx.Rollback(context.Background())
 
-    // Получаем текущую информацию о задаче
-    var lastCommit, lastMerge *time.Time
+    // Получаем текущий статус задачи
+    var currentStatus string
     err = tx.QueryRow(context.Background(),
-        `SELECT si_last_commit, si_last_merge 
+        `SELECT COALESCE(si_agile_status, '') 
          FROM sprint_issues 
          WHERE si_sprint_id = $1 AND si_issue_id = $2`,
-        sprintID, issueID).Scan(&lastCommit, &lastMerge)
+        sprintID, issueID).Scan(&currentStatus)
     
     if err != nil {
         return fmt.Errorf("ошибка при получении информации о задаче: %w", err)
     }
 
-    // Определяем новый статус на основе условий
-    var newStatus string
-    if lastMerge != nil {
-        newStatus = "Готово"
-    } else if lastCommit != nil {
-        newStatus = "На проверке"
-    } else if assigneeID != 0 {
-        newStatus = "В работе"
-    } else {
-        newStatus = "К выполнению"
+    // Определяем новый статус по правилам переходов
+    event := models.EventAssigned
+    if assigneeID == 0 {
+        event = models.EventUnassigned
     }
+    newStatus := models.NextIssueStatus(models.IssueState{
+        Status:   currentStatus,
+        Assigned: assigneeID != 0,
+    }, event)
 
     // Обновляем участника и статус
     query := `
@@ -589,12 +645,27 @@ func (pl *PullIncludes) UpdateSprintIssueAssignee(sprintID, issueID, assigneeID
     return nil
 }
 
-func (pl *PullIncludes) GetSprintIDByIssueID(issueID int) (int, error) {
+// GetProjectSprintIDByIssueID возвращает спринт проекта GitLab, в который входит задача
+// с номером issueID. Номера задач уникальны только внутри проекта, поэтому спринт
+// ищется среди спринтов проекта; незавершенный спринт предпочтительнее завершенного.
+// Если задача не входит ни в один спринт проекта, возвращается models.ErrNoRecord.
+func (pl *PullIncludes) GetProjectSprintIDByIssueID(projectID, issueID int) (int, error) {
+	query := `
+		SELECT si.si_sprint_id
+		FROM sprint_issues si
+		JOIN sprint s ON s.spt_id = si.si_sprint_id
+		WHERE s.spt_project_id = $1 AND si.si_issue_id = $2
+		ORDER BY COALESCE(s.spt_status, '') = 'completed', s.spt_start_date DESC NULLS LAST, s.spt_id DESC
+		LIMIT 1
+	`
+
 	var sprintID int
-	query := "SELECT si_sprint_id FROM sprint_issues WHERE si_issue_id = $1"
-	err := pl.DB.QueryRow(context.Background(), query, issueID).Scan(&sprintID)
+	err := pl.DB.QueryRow(context.Background(), query, projectID, issueID).Scan(&sprintID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get sprint ID for issue %d: %w", issueID, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, fmt.Errorf("ошибка при получении спринта задачи %d проекта %d: %w", issueID, projectID, err)
 	}
 	return sprintID, nil
 }
@@ -614,7 +685,14 @@ func (pl *PullIncludes) GetSprintIssue(sprintID, issueID int) (*models.SprintIss
             si_last_commit,
             si_last_merge,
             si_branch_name,
-            si_mr_id
+            si_mr_id,
+            si_labels,
+            si_assignee_ids,
+            si_weight,
+            si_due_date,
+            si_gitlab_state,
+            si_moved_to_id,
+            si_last_synced_at
         FROM sprint_issues
         WHERE si_sprint_id = $1 AND si_issue_id = $2
     `
@@ -642,6 +720,13 @@ func (pl *PullIncludes) GetSprintIssue(sprintID, issueID int) (*models.SprintIss
         &lastMerge,
         &branchName,
         &mrID,
+        &issue.Labels,
+        &issue.AssigneeIDs,
+        &issue.Weight,
+        &issue.DueDate,
+        &issue.GitLabState,
+        &issue.MovedToID,
+        &issue.LastSyncedAt,
     )
 
     if err != nil {
@@ -761,3 +846,1627 @@ func (pl *PullIncludes) DeleteSprint(sprintID int) error {
 
 	return nil
 }
+
+// UpsertMergeRequest сохраняет или обновляет сведения о мердж-реквесте задачи
+func (pl *PullIncludes) UpsertMergeRequest(mr *models.MergeRequest) error {
+	query := `
+		INSERT INTO sprint_issue_merge_requests (
+			simr_sprint_id, simr_issue_id, simr_project_id, simr_mr_id, simr_mr_iid,
+			simr_title, simr_state, simr_author_id, simr_author_username, simr_reviewers,
+			simr_source_branch, simr_target_branch, simr_web_url, simr_created_at, simr_merged_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (simr_project_id, simr_mr_iid, simr_sprint_id, simr_issue_id) DO UPDATE SET
+			simr_mr_id = EXCLUDED.simr_mr_id,
+			simr_title = EXCLUDED.simr_title,
+			simr_state = EXCLUDED.simr_state,
+			simr_author_id = EXCLUDED.simr_author_id,
+			simr_author_username = COALESCE(NULLIF(EXCLUDED.simr_author_username, ''), sprint_issue_merge_requests.simr_author_username),
+			simr_reviewers = EXCLUDED.simr_reviewers,
+			simr_source_branch = EXCLUDED.simr_source_branch,
+			simr_target_branch = EXCLUDED.simr_target_branch,
+			simr_web_url = EXCLUDED.simr_web_url,
+			simr_created_at = COALESCE(EXCLUDED.simr_created_at, sprint_issue_merge_requests.simr_created_at),
+			simr_merged_at = COALESCE(EXCLUDED.simr_merged_at, sprint_issue_merge_requests.simr_merged_at),
+			simr_updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := pl.DB.Exec(
+		context.Background(),
+		query,
+		mr.SprintID,
+		mr.IssueID,
+		mr.ProjectID,
+		mr.MRID,
+		mr.MRIID,
+		mr.Title,
+		mr.State,
+		mr.AuthorID,
+		mr.AuthorUsername,
+		mr.Reviewers,
+		mr.SourceBranch,
+		mr.TargetBranch,
+		mr.WebURL,
+		mr.CreatedAt,
+		mr.MergedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить мердж-реквест: %w", err)
+	}
+
+	return nil
+}
+
+// AddMergeRequestApproval отмечает одобрение мердж-реквеста пользователем
+func (pl *PullIncludes) AddMergeRequestApproval(projectID, mrIID int, username string) error {
+	query := `
+		UPDATE sprint_issue_merge_requests
+		SET simr_approved_by = array_append(array_remove(simr_approved_by, $3), $3),
+			simr_updated_at = CURRENT_TIMESTAMP
+		WHERE simr_project_id = $1 AND simr_mr_iid = $2
+	`
+	_, err := pl.DB.Exec(context.Background(), query, projectID, mrIID, username)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить одобрение мердж-реквеста: %w", err)
+	}
+	return nil
+}
+
+// RemoveMergeRequestApproval снимает одобрение мердж-реквеста пользователем
+func (pl *PullIncludes) RemoveMergeRequestApproval(projectID, mrIID int, username string) error {
+	query := `
+		UPDATE sprint_issue_merge_requests
+		SET simr_approved_by = array_remove(simr_approved_by, $3),
+			simr_updated_at = CURRENT_TIMESTAMP
+		WHERE simr_project_id = $1 AND simr_mr_iid = $2
+	`
+	_, err := pl.DB.Exec(context.Background(), query, projectID, mrIID, username)
+	if err != nil {
+		return fmt.Errorf("не удалось снять одобрение мердж-реквеста: %w", err)
+	}
+	return nil
+}
+
+// GetSprintIssueMergeRequests получает все мердж-реквесты, ссылающиеся на задачу спринта
+func (pl *PullIncludes) GetSprintIssueMergeRequests(sprintID, issueID int) ([]models.MergeRequest, error) {
+	query := `
+		SELECT
+			simr_sprint_id,
+			simr_issue_id,
+			simr_project_id,
+			simr_mr_id,
+			simr_mr_iid,
+			simr_title,
+			simr_state,
+			simr_author_id,
+			simr_author_username,
+			simr_reviewers,
+			simr_approved_by,
+			simr_source_branch,
+			simr_target_branch,
+			simr_web_url,
+			simr_created_at,
+			simr_merged_at,
+			simr_updated_at
+		FROM sprint_issue_merge_requests
+		WHERE simr_sprint_id = $1 AND simr_issue_id = $2
+		ORDER BY simr_created_at NULLS LAST, simr_mr_iid
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query, sprintID, issueID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении мердж-реквестов задачи: %w", err)
+	}
+	defer rows.Close()
+
+	mergeRequests := []models.MergeRequest{}
+	for rows.Next() {
+		var mr models.MergeRequest
+		err := rows.Scan(
+			&mr.SprintID,
+			&mr.IssueID,
+			&mr.ProjectID,
+			&mr.MRID,
+			&mr.MRIID,
+			&mr.Title,
+			&mr.State,
+			&mr.AuthorID,
+			&mr.AuthorUsername,
+			&mr.Reviewers,
+			&mr.ApprovedBy,
+			&mr.SourceBranch,
+			&mr.TargetBranch,
+			&mr.WebURL,
+			&mr.CreatedAt,
+			&mr.MergedAt,
+			&mr.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании мердж-реквеста: %w", err)
+		}
+		mergeRequests = append(mergeRequests, mr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по мердж-реквестам: %w", err)
+	}
+
+	return mergeRequests, nil
+}
+
+// CountOpenMergeRequests возвращает количество открытых мердж-реквестов задачи,
+// не считая мердж-реквест exceptMRIID проекта exceptProjectID (IID уникален только
+// внутри проекта)
+func (pl *PullIncludes) CountOpenMergeRequests(sprintID, issueID, exceptProjectID, exceptMRIID int) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM sprint_issue_merge_requests
+		WHERE simr_sprint_id = $1 AND simr_issue_id = $2
+			AND simr_state IN ('opened', 'reopened', 'locked')
+			AND NOT (simr_project_id = $3 AND simr_mr_iid = $4)
+	`
+
+	var count int
+	err := pl.DB.QueryRow(context.Background(), query, sprintID, issueID, exceptProjectID, exceptMRIID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при подсчете открытых мердж-реквестов: %w", err)
+	}
+	return count, nil
+}
+
+// GetMergeRequestIssue находит задачу спринта, на которую ссылается мердж-реквест проекта
+func (pl *PullIncludes) GetMergeRequestIssue(projectID, mrIID int) (int, int, error) {
+	query := `
+		SELECT simr_sprint_id, simr_issue_id
+		FROM sprint_issue_merge_requests
+		WHERE simr_project_id = $1 AND simr_mr_iid = $2
+		ORDER BY simr_updated_at DESC
+		LIMIT 1
+	`
+
+	var sprintID, issueID int
+	err := pl.DB.QueryRow(context.Background(), query, projectID, mrIID).Scan(&sprintID, &issueID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, 0, models.ErrNoRecord
+		}
+		return 0, 0, fmt.Errorf("ошибка при поиске задачи мердж-реквеста: %w", err)
+	}
+	return sprintID, issueID, nil
+}
+
+// GetUserRoles получает роли пользователей из user_settings.
+// Пользователи без сохраненных настроек в результат не попадают.
+func (pl *PullIncludes) GetUserRoles(userIDs []int) (map[int]string, error) {
+	query := `
+		SELECT us_user_id, us_role
+		FROM user_settings
+		WHERE us_user_id = ANY($1)
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек пользователей: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make(map[int]string, len(userIDs))
+	for rows.Next() {
+		var userID int
+		var role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			return nil, fmt.Errorf("ошибка чтения настроек пользователя: %w", err)
+		}
+		roles[userID] = role
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по настройкам пользователей: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GetActiveSprints получает все незавершенные спринты
+func (pl *PullIncludes) GetActiveSprints() ([]Sprint, error) {
+	query := `
+		SELECT spt_id, spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id, created_at, updated_at, COALESCE(spt_status, ''),
+			spt_milestone_id, spt_iteration_id
+		FROM sprint
+		WHERE COALESCE(spt_status, '') <> 'completed'
+		ORDER BY spt_id
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении активных спринтов: %w", err)
+	}
+	defer rows.Close()
+
+	var sprints []Sprint
+	for rows.Next() {
+		var sprint Sprint
+		err := rows.Scan(
+			&sprint.SptID,
+			&sprint.SptTitle,
+			&sprint.SptStartDate,
+			&sprint.SptEndDate,
+			&sprint.SptGoals,
+			&sprint.SptProjectID,
+			&sprint.CreatedAt,
+			&sprint.UpdatedAt,
+			&sprint.SptStatus,
+			&sprint.SptMilestoneID,
+			&sprint.SptIterationID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании спринта: %w", err)
+		}
+		sprints = append(sprints, sprint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по спринтам: %w", err)
+	}
+
+	return sprints, nil
+}
+
+// MarkSprintIssueSynced сохраняет время последней синхронизации задачи с GitLab
+func (pl *PullIncludes) MarkSprintIssueSynced(sprintID, issueID int, syncedAt time.Time) error {
+	query := `
+		UPDATE sprint_issues
+		SET si_last_synced_at = $3
+		WHERE si_sprint_id = $1 AND si_issue_id = $2
+	`
+	_, err := pl.DB.Exec(context.Background(), query, sprintID, issueID, syncedAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить время синхронизации задачи: %w", err)
+	}
+	return nil
+}
+
+// ApplyGitLabIssueSnapshot переносит в задачу спринта поля задачи GitLab
+// и сохраняет ее состояние в GitLab на момент сверки
+func (pl *PullIncludes) ApplyGitLabIssueSnapshot(sprintID, issueID int, snapshot models.GitLabIssueSnapshot) error {
+	labels := snapshot.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	assigneeIDs := snapshot.AssigneeIDs
+	if assigneeIDs == nil {
+		assigneeIDs = []int{}
+	}
+
+	query := `
+		UPDATE sprint_issues
+		SET si_name_issues = $3,
+			si_description_issue = $4,
+			si_labels = $5,
+			si_assignee_ids = $6,
+			si_assigned_to = CASE WHEN cardinality($6::integer[]) > 0 THEN ($6::integer[])[1] ELSE si_assigned_to END,
+			si_weight = $7,
+			si_due_date = $8,
+			si_gitlab_state = $9,
+			si_moved_to_id = $10
+		WHERE si_sprint_id = $1 AND si_issue_id = $2
+	`
+	result, err := pl.DB.Exec(context.Background(), query,
+		sprintID, issueID,
+		snapshot.Title, snapshot.Description, labels, assigneeIDs,
+		snapshot.Weight, snapshot.DueDate, snapshot.State, snapshot.MovedToID)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить задачу по данным GitLab: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// SetSprintIssueGitLabState сохраняет состояние задачи в GitLab (deleted, moved),
+// не трогая остальные поля
+func (pl *PullIncludes) SetSprintIssueGitLabState(sprintID, issueID int, state string, movedToID *int) error {
+	query := `
+		UPDATE sprint_issues
+		SET si_gitlab_state = $3, si_moved_to_id = $4
+		WHERE si_sprint_id = $1 AND si_issue_id = $2
+	`
+	_, err := pl.DB.Exec(context.Background(), query, sprintID, issueID, state, movedToID)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить состояние задачи в GitLab: %w", err)
+	}
+	return nil
+}
+
+// AddSyncDiscrepancies сохраняет расхождения, найденные при сверке задачи
+func (pl *PullIncludes) AddSyncDiscrepancies(discrepancies []models.SyncDiscrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, d := range discrepancies {
+		batch.Queue(`
+			INSERT INTO sync_discrepancies (
+				sd_sprint_id, sd_issue_id, sd_project_id, sd_kind,
+				sd_field, sd_local_value, sd_gitlab_value, sd_reason
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, d.SprintID, d.IssueID, d.ProjectID, d.Kind, d.Field, d.LocalValue, d.GitLabValue, d.Reason)
+	}
+
+	results := pl.DB.SendBatch(context.Background(), batch)
+	defer results.Close()
+	for range discrepancies {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("не удалось сохранить расхождение синхронизации: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetSyncDiscrepancies возвращает расхождения задач спринта с GitLab, начиная с since
+func (pl *PullIncludes) GetSyncDiscrepancies(sprintID int, since time.Time) ([]models.SyncDiscrepancy, error) {
+	query := `
+		SELECT sd_id, sd_sprint_id, sd_issue_id, sd_project_id, sd_kind,
+			sd_field, sd_local_value, sd_gitlab_value, sd_reason, sd_detected_at
+		FROM sync_discrepancies
+		WHERE sd_sprint_id = $1 AND sd_detected_at >= $2
+		ORDER BY sd_detected_at DESC, sd_id DESC
+	`
+	rows, err := pl.DB.Query(context.Background(), query, sprintID, since)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении расхождений синхронизации: %w", err)
+	}
+	defer rows.Close()
+
+	discrepancies := []models.SyncDiscrepancy{}
+	for rows.Next() {
+		var d models.SyncDiscrepancy
+		if err := rows.Scan(&d.ID, &d.SprintID, &d.IssueID, &d.ProjectID, &d.Kind,
+			&d.Field, &d.LocalValue, &d.GitLabValue, &d.Reason, &d.DetectedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании расхождения: %w", err)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по расхождениям: %w", err)
+	}
+
+	return discrepancies, nil
+}
+
+// GetPolledProjectIDs возвращает проекты GitLab для опроса: подключенные проекты
+// и проекты, в которых есть спринты. Проект опрашивается и без незавершенных
+// спринтов, чтобы его курсор не отставал к началу следующего спринта.
+func (pl *PullIncludes) GetPolledProjectIDs() ([]int, error) {
+	query := `
+		SELECT prj_gitlab_id FROM projects WHERE prj_gitlab_id IS NOT NULL
+		UNION
+		SELECT spt_project_id FROM sprint WHERE spt_project_id IS NOT NULL
+		ORDER BY 1
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении проектов для опроса: %w", err)
+	}
+	defer rows.Close()
+
+	var projectIDs []int
+	for rows.Next() {
+		var projectID int
+		if err := rows.Scan(&projectID); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании проекта: %w", err)
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по проектам: %w", err)
+	}
+
+	return projectIDs, nil
+}
+
+// GetPollCursor получает курсор опроса проекта GitLab
+func (pl *PullIncludes) GetPollCursor(projectID int) (*models.PollCursor, error) {
+	query := `
+		SELECT gpc_project_id, gpc_last_event_id, gpc_events_since, gpc_merge_requests_since, gpc_issues_since,
+			gpc_merge_requests_seen, gpc_issues_seen
+		FROM gitlab_poll_cursors
+		WHERE gpc_project_id = $1
+	`
+
+	var cursor models.PollCursor
+	err := pl.DB.QueryRow(context.Background(), query, projectID).Scan(
+		&cursor.ProjectID,
+		&cursor.LastEventID,
+		&cursor.EventsSince,
+		&cursor.MergeRequestsSince,
+		&cursor.IssuesSince,
+		&cursor.MergeRequestsSeen,
+		&cursor.IssuesSeen,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении курсора опроса: %w", err)
+	}
+
+	return &cursor, nil
+}
+
+// SavePollCursor сохраняет курсор опроса проекта GitLab
+func (pl *PullIncludes) SavePollCursor(cursor *models.PollCursor) error {
+	query := `
+		INSERT INTO gitlab_poll_cursors (
+			gpc_project_id, gpc_last_event_id, gpc_events_since, gpc_merge_requests_since, gpc_issues_since,
+			gpc_merge_requests_seen, gpc_issues_seen
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (gpc_project_id) DO UPDATE SET
+			gpc_last_event_id = EXCLUDED.gpc_last_event_id,
+			gpc_events_since = EXCLUDED.gpc_events_since,
+			gpc_merge_requests_since = EXCLUDED.gpc_merge_requests_since,
+			gpc_issues_since = EXCLUDED.gpc_issues_since,
+			gpc_merge_requests_seen = EXCLUDED.gpc_merge_requests_seen,
+			gpc_issues_seen = EXCLUDED.gpc_issues_seen,
+			gpc_updated_at = CURRENT_TIMESTAMP
+	`
+
+	mergeRequestsSeen, issuesSeen := cursor.MergeRequestsSeen, cursor.IssuesSeen
+	if mergeRequestsSeen == nil {
+		mergeRequestsSeen = []int{}
+	}
+	if issuesSeen == nil {
+		issuesSeen = []int{}
+	}
+
+	_, err := pl.DB.Exec(context.Background(), query,
+		cursor.ProjectID, cursor.LastEventID, cursor.EventsSince, cursor.MergeRequestsSince, cursor.IssuesSince,
+		mergeRequestsSeen, issuesSeen)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить курсор опроса: %w", err)
+	}
+	return nil
+}
+
+// GetMergeRequestState возвращает последнее сохраненное состояние мердж-реквеста
+// или пустую строку, если мердж-реквест еще не встречался
+func (pl *PullIncludes) GetMergeRequestState(projectID, mrIID int) (string, error) {
+	query := `
+		SELECT simr_state
+		FROM sprint_issue_merge_requests
+		WHERE simr_project_id = $1 AND simr_mr_iid = $2
+		ORDER BY simr_updated_at DESC
+		LIMIT 1
+	`
+
+	var state string
+	err := pl.DB.QueryRow(context.Background(), query, projectID, mrIID).Scan(&state)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("ошибка при получении состояния мердж-реквеста: %w", err)
+	}
+	return state, nil
+}
+
+// SaveProjectWebhook сохраняет вебхук проекта GitLab
+func (pl *PullIncludes) SaveProjectWebhook(webhook *models.ProjectWebhook) error {
+	query := `
+		INSERT INTO project_webhooks (pwh_project_id, pwh_hook_id, pwh_url, pwh_secret, pwh_secret_encrypted)
+		VALUES ($1, $2, $3, NULL, $4)
+		ON CONFLICT (pwh_project_id) DO UPDATE SET
+			pwh_hook_id = EXCLUDED.pwh_hook_id,
+			pwh_url = EXCLUDED.pwh_url,
+			pwh_secret = NULL,
+			pwh_secret_encrypted = EXCLUDED.pwh_secret_encrypted,
+			pwh_updated_at = CURRENT_TIMESTAMP
+		RETURNING pwh_created_at, pwh_updated_at
+	`
+
+	err := pl.DB.QueryRow(context.Background(), query,
+		webhook.ProjectID, webhook.HookID, webhook.URL, webhook.SecretEncrypted,
+	).Scan(&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить вебхук проекта: %w", err)
+	}
+	return nil
+}
+
+// GetProjectWebhook получает вебхук проекта GitLab
+func (pl *PullIncludes) GetProjectWebhook(projectID int) (*models.ProjectWebhook, error) {
+	query := `
+		SELECT pwh_project_id, pwh_hook_id, pwh_url, pwh_secret_encrypted, pwh_created_at, pwh_updated_at
+		FROM project_webhooks
+		WHERE pwh_project_id = $1
+	`
+
+	var webhook models.ProjectWebhook
+	err := pl.DB.QueryRow(context.Background(), query, projectID).Scan(
+		&webhook.ProjectID,
+		&webhook.HookID,
+		&webhook.URL,
+		&webhook.SecretEncrypted,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении вебхука проекта: %w", err)
+	}
+	return &webhook, nil
+}
+
+// GetPlaintextWebhookSecrets возвращает секреты вебхуков, которые еще хранятся
+// в открытом виде, по ID проекта
+func (pl *PullIncludes) GetPlaintextWebhookSecrets() (map[int]string, error) {
+	rows, err := pl.DB.Query(context.Background(), `
+		SELECT pwh_project_id, pwh_secret FROM project_webhooks
+		WHERE pwh_secret IS NOT NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения секретов вебхуков: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := map[int]string{}
+	for rows.Next() {
+		var projectID int
+		var secret string
+		if err := rows.Scan(&projectID, &secret); err != nil {
+			return nil, fmt.Errorf("ошибка чтения секрета вебхука: %w", err)
+		}
+		secrets[projectID] = secret
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по секретам вебхуков: %w", err)
+	}
+	return secrets, nil
+}
+
+// ReplacePlaintextWebhookSecret заменяет открытый секрет вебхука зашифрованным,
+// если секрет за это время не изменился
+func (pl *PullIncludes) ReplacePlaintextWebhookSecret(projectID int, plaintext string, encrypted []byte) error {
+	_, err := pl.DB.Exec(context.Background(), `
+		UPDATE project_webhooks SET pwh_secret = NULL, pwh_secret_encrypted = $3
+		WHERE pwh_project_id = $1 AND pwh_secret = $2
+	`, projectID, plaintext, encrypted)
+	if err != nil {
+		return fmt.Errorf("не удалось зашифровать секрет вебхука: %w", err)
+	}
+	return nil
+}
+
+// DeleteProjectWebhook удаляет сведения о вебхуке проекта GitLab
+func (pl *PullIncludes) DeleteProjectWebhook(projectID int) error {
+	_, err := pl.DB.Exec(context.Background(), "DELETE FROM project_webhooks WHERE pwh_project_id = $1", projectID)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить вебхук проекта: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation сообщает, нарушено ли ограничение уникальности
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+const projectTemplateColumns = `pt_id, pt_name, pt_description, pt_config, pt_created_at, pt_updated_at`
+
+func scanProjectTemplate(row pgx.Row) (*models.ProjectTemplate, error) {
+	var template models.ProjectTemplate
+	err := row.Scan(
+		&template.ID,
+		&template.Name,
+		&template.Description,
+		&template.Config,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateProjectTemplate создает шаблон проекта
+func (pl *PullIncludes) CreateProjectTemplate(template *models.ProjectTemplate) error {
+	query := `
+		INSERT INTO project_templates (pt_name, pt_description, pt_config)
+		VALUES ($1, $2, $3)
+		RETURNING pt_id, pt_created_at, pt_updated_at
+	`
+	err := pl.DB.QueryRow(context.Background(), query, template.Name, template.Description, template.Config).
+		Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrDuplicate
+		}
+		return fmt.Errorf("не удалось создать шаблон проекта: %w", err)
+	}
+	return nil
+}
+
+// GetProjectTemplates получает все шаблоны проектов
+func (pl *PullIncludes) GetProjectTemplates() ([]models.ProjectTemplate, error) {
+	rows, err := pl.DB.Query(context.Background(),
+		"SELECT "+projectTemplateColumns+" FROM project_templates ORDER BY pt_name")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении шаблонов проектов: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.ProjectTemplate{}
+	for rows.Next() {
+		template, err := scanProjectTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании шаблона проекта: %w", err)
+		}
+		templates = append(templates, *template)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по шаблонам проектов: %w", err)
+	}
+	return templates, nil
+}
+
+// GetProjectTemplate получает шаблон проекта по ID
+func (pl *PullIncludes) GetProjectTemplate(templateID int) (*models.ProjectTemplate, error) {
+	row := pl.DB.QueryRow(context.Background(),
+		"SELECT "+projectTemplateColumns+" FROM project_templates WHERE pt_id = $1", templateID)
+	template, err := scanProjectTemplate(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении шаблона проекта: %w", err)
+	}
+	return template, nil
+}
+
+// UpdateProjectTemplate изменяет шаблон проекта
+func (pl *PullIncludes) UpdateProjectTemplate(template *models.ProjectTemplate) error {
+	query := `
+		UPDATE project_templates
+		SET pt_name = $2, pt_description = $3, pt_config = $4, pt_updated_at = CURRENT_TIMESTAMP
+		WHERE pt_id = $1
+		RETURNING pt_created_at, pt_updated_at
+	`
+	err := pl.DB.QueryRow(context.Background(), query, template.ID, template.Name, template.Description, template.Config).
+		Scan(&template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		if isUniqueViolation(err) {
+			return models.ErrDuplicate
+		}
+		return fmt.Errorf("не удалось изменить шаблон проекта: %w", err)
+	}
+	return nil
+}
+
+// DeleteProjectTemplate удаляет шаблон проекта
+func (pl *PullIncludes) DeleteProjectTemplate(templateID int) error {
+	result, err := pl.DB.Exec(context.Background(), "DELETE FROM project_templates WHERE pt_id = $1", templateID)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить шаблон проекта: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// AddBacklogIssues добавляет задачи в бэклог проекта, пропуская уже добавленные.
+// Возвращает количество добавленных задач.
+func (pl *PullIncludes) AddBacklogIssues(issues []models.BacklogIssue) (int, error) {
+	if len(issues) == 0 {
+		return 0, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, issue := range issues {
+		labels := issue.Labels
+		if labels == nil {
+			labels = []string{}
+		}
+		batch.Queue(`
+			INSERT INTO project_backlog (pb_project_id, pb_issue_id, pb_title, pb_description, pb_labels, pb_weight)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (pb_project_id, pb_issue_id) DO NOTHING
+		`, issue.ProjectID, issue.IssueID, issue.Title, issue.Description, labels, issue.Weight)
+	}
+
+	results := pl.DB.SendBatch(context.Background(), batch)
+	defer results.Close()
+
+	added := 0
+	for range issues {
+		tag, err := results.Exec()
+		if err != nil {
+			return added, fmt.Errorf("не удалось добавить задачу в бэклог: %w", err)
+		}
+		added += int(tag.RowsAffected())
+	}
+	return added, nil
+}
+
+// GetBacklogIssues получает бэклог проекта GitLab
+func (pl *PullIncludes) GetBacklogIssues(projectID int) ([]models.BacklogIssue, error) {
+	query := `
+		SELECT pb_project_id, pb_issue_id, pb_title, pb_description, pb_labels, pb_weight, pb_created_at
+		FROM project_backlog
+		WHERE pb_project_id = $1
+		ORDER BY pb_issue_id
+	`
+	rows, err := pl.DB.Query(context.Background(), query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении бэклога: %w", err)
+	}
+	defer rows.Close()
+
+	issues := []models.BacklogIssue{}
+	for rows.Next() {
+		var issue models.BacklogIssue
+		if err := rows.Scan(&issue.ProjectID, &issue.IssueID, &issue.Title, &issue.Description,
+			&issue.Labels, &issue.Weight, &issue.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании задачи бэклога: %w", err)
+		}
+		issues = append(issues, issue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по бэклогу: %w", err)
+	}
+	return issues, nil
+}
+
+// UnlinkProject удаляет все данные плагина о проекте GitLab: спринты и их задачи,
+// бэклог, курсор опроса, вебхук, отчеты синхронизации, роли в проекте и локальную запись проекта
+func (pl *PullIncludes) UnlinkProject(gitlabID int) error {
+	ctx := context.Background()
+	tx, err := pl.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var prjID int
+	err = tx.QueryRow(ctx, "SELECT prj_id FROM projects WHERE prj_gitlab_id = $1", gitlabID).Scan(&prjID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("не удалось получить проект: %w", err)
+	}
+
+	statements := []string{
+		"DELETE FROM sprint_issues WHERE si_sprint_id IN (SELECT spt_id FROM sprint WHERE spt_project_id = $1)",
+		"DELETE FROM sprint WHERE spt_project_id = $1",
+		"DELETE FROM sync_discrepancies WHERE sd_project_id = $1",
+		"DELETE FROM project_backlog WHERE pb_project_id = $1",
+		"DELETE FROM gitlab_poll_cursors WHERE gpc_project_id = $1",
+		"DELETE FROM project_webhooks WHERE pwh_project_id = $1",
+		"DELETE FROM project_roles WHERE pr_project_id = $1",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt, gitlabID); err != nil {
+			return fmt.Errorf("не удалось удалить данные проекта: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM users_projects WHERE prt_prj_id = $1", prjID); err != nil {
+		return fmt.Errorf("не удалось удалить участников проекта: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM projects WHERE prj_id = $1", prjID); err != nil {
+		return fmt.Errorf("не удалось удалить проект: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return nil
+}
+
+// GetSprintByGitLabSource находит спринт проекта, созданный из вехи (source = "milestone")
+// или итерации (source = "iteration") GitLab
+func (pl *PullIncludes) GetSprintByGitLabSource(projectID int, source string, sourceID int) (*Sprint, error) {
+	column := "spt_milestone_id"
+	if source == "iteration" {
+		column = "spt_iteration_id"
+	}
+
+	var sprintID int
+	err := pl.DB.QueryRow(context.Background(),
+		"SELECT spt_id FROM sprint WHERE spt_project_id = $1 AND "+column+" = $2", projectID, sourceID,
+	).Scan(&sprintID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при поиске спринта: %w", err)
+	}
+
+	sprint, err := pl.GetSprint(sprintID)
+	if err != nil {
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+// CreateGitLabSprint создает спринт, уже связанный с вехой или итерацией GitLab,
+// одним запросом: спринт без связи не нашелся бы при повторном импорте.
+// Если спринт для этой вехи или итерации уже есть, возвращается models.ErrDuplicate.
+func (pl *PullIncludes) CreateGitLabSprint(title string, startDate, endDate time.Time, goals string, projectID int, milestoneID, iterationID *int, completed bool) (int, error) {
+	query := `
+		INSERT INTO sprint (spt_title, spt_start_date, spt_end_date, spt_goals, spt_project_id,
+			spt_milestone_id, spt_iteration_id, spt_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $8 THEN 'completed' END)
+		RETURNING spt_id
+	`
+
+	var sprintID int
+	err := pl.DB.QueryRow(context.Background(), query,
+		title, startDate, endDate, goals, projectID, milestoneID, iterationID, completed,
+	).Scan(&sprintID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, models.ErrDuplicate
+		}
+		return 0, fmt.Errorf("не удалось создать спринт: %w", err)
+	}
+	return sprintID, nil
+}
+
+// SetSprintGitLabSource связывает спринт с вехой или итерацией GitLab
+func (pl *PullIncludes) SetSprintGitLabSource(sprintID int, milestoneID, iterationID *int) error {
+	query := `
+		UPDATE sprint
+		SET spt_milestone_id = $2, spt_iteration_id = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE spt_id = $1
+	`
+	_, err := pl.DB.Exec(context.Background(), query, sprintID, milestoneID, iterationID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrDuplicate
+		}
+		return fmt.Errorf("не удалось связать спринт с GitLab: %w", err)
+	}
+	return nil
+}
+
+// RemoveBacklogIssues убирает из бэклога задачи, распределенные по спринтам
+func (pl *PullIncludes) RemoveBacklogIssues(projectID int, issueIDs []int) error {
+	_, err := pl.DB.Exec(context.Background(),
+		"DELETE FROM project_backlog WHERE pb_project_id = $1 AND pb_issue_id = ANY($2)", projectID, issueIDs)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить бэклог: %w", err)
+	}
+	return nil
+}
+
+const sessionColumns = `
+	ses_id, ses_user_id, ses_username, ses_name, ses_email,
+	ses_access_token, ses_refresh_token, ses_token_expires_at, ses_local_user_id,
+	ses_user_agent, ses_ip, ses_created_at, ses_last_seen_at, ses_expires_at`
+
+// CreateSession сохраняет новую сессию пользователя
+func (pl *PullIncludes) CreateSession(session *models.Session) error {
+	query := `
+		INSERT INTO sessions (
+			ses_id, ses_user_id, ses_username, ses_name, ses_email,
+			ses_access_token, ses_refresh_token, ses_token_expires_at, ses_local_user_id,
+			ses_user_agent, ses_ip, ses_expires_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ses_created_at, ses_last_seen_at
+	`
+	err := pl.DB.QueryRow(context.Background(), query,
+		session.ID, session.UserID, session.Username, session.Name, session.Email,
+		session.AccessTokenEncrypted, session.RefreshTokenEncrypted, session.TokenExpiresAt, session.LocalUserID,
+		session.UserAgent, session.IP, session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("не удалось создать сессию: %w", err)
+	}
+	return nil
+}
+
+// GetSession возвращает действующую (не истекшую) сессию
+func (pl *PullIncludes) GetSession(id string) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE ses_id = $1 AND ses_expires_at > CURRENT_TIMESTAMP`
+
+	session, err := scanSession(pl.DB.QueryRow(context.Background(), query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении сессии: %w", err)
+	}
+	return session, nil
+}
+
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Username,
+		&session.Name,
+		&session.Email,
+		&session.AccessTokenEncrypted,
+		&session.RefreshTokenEncrypted,
+		&session.TokenExpiresAt,
+		&session.LocalUserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetUserSessions возвращает действующие сессии пользователя
+func (pl *PullIncludes) GetUserSessions(userID int) ([]models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE ses_user_id = $1 AND ses_expires_at > CURRENT_TIMESTAMP`
+
+	rows, err := pl.DB.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сессий пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения сессии: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по сессиям: %w", err)
+	}
+	return sessions, nil
+}
+
+// DeleteUserSessions удаляет все сессии пользователя вместе с выданными по ним
+// refresh-токенами и возвращает количество удаленных сессий
+func (pl *PullIncludes) DeleteUserSessions(userID int) (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), "DELETE FROM sessions WHERE ses_user_id = $1", userID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить сессии пользователя: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// TouchSession отмечает использование сессии
+func (pl *PullIncludes) TouchSession(id string) error {
+	_, err := pl.DB.Exec(context.Background(),
+		"UPDATE sessions SET ses_last_seen_at = CURRENT_TIMESTAMP WHERE ses_id = $1", id)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession удаляет сессию
+func (pl *PullIncludes) DeleteSession(id string) error {
+	_, err := pl.DB.Exec(context.Background(), "DELETE FROM sessions WHERE ses_id = $1", id)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить сессию: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions удаляет истекшие сессии и возвращает их количество
+func (pl *PullIncludes) DeleteExpiredSessions() (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), "DELETE FROM sessions WHERE ses_expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить истекшие сессии: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UpdateSessionTokens сохраняет обновленные токены GitLab сессии
+func (pl *PullIncludes) UpdateSessionTokens(id string, accessToken, refreshToken []byte, expiresAt *time.Time) error {
+	query := `
+		UPDATE sessions
+		SET ses_access_token = $2, ses_refresh_token = $3, ses_token_expires_at = $4
+		WHERE ses_id = $1
+	`
+	_, err := pl.DB.Exec(context.Background(), query, id, accessToken, refreshToken, expiresAt)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить токены сессии: %w", err)
+	}
+	return nil
+}
+
+// CreateOAuthState сохраняет state и PKCE code_verifier начатого входа
+func (pl *PullIncludes) CreateOAuthState(state *models.OAuthState) error {
+	query := `
+		INSERT INTO oauth_states (oas_state_hash, oas_code_verifier, oas_redirect_to, oas_expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := pl.DB.Exec(context.Background(), query, state.StateHash, state.CodeVerifier, state.RedirectTo, state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить state входа: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState возвращает и удаляет действующую запись входа, чтобы state
+// нельзя было использовать повторно
+func (pl *PullIncludes) ConsumeOAuthState(stateHash string) (*models.OAuthState, error) {
+	query := `
+		DELETE FROM oauth_states
+		WHERE oas_state_hash = $1
+		RETURNING oas_state_hash, oas_code_verifier, oas_redirect_to, oas_expires_at
+	`
+	var state models.OAuthState
+	err := pl.DB.QueryRow(context.Background(), query, stateHash).Scan(
+		&state.StateHash,
+		&state.CodeVerifier,
+		&state.RedirectTo,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении state входа: %w", err)
+	}
+	if !state.ExpiresAt.After(time.Now()) {
+		return nil, models.ErrNoRecord
+	}
+	return &state, nil
+}
+
+// DeleteExpiredOAuthStates удаляет незавершенные входы с истекшим сроком
+func (pl *PullIncludes) DeleteExpiredOAuthStates() (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), "DELETE FROM oauth_states WHERE oas_expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить истекшие state входа: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CreateRefreshToken сохраняет новый refresh-токен
+func (pl *PullIncludes) CreateRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (rt_id, rt_family_id, rt_session_id, rt_user_id, rt_expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING rt_created_at
+	`
+	err := pl.DB.QueryRow(context.Background(), query,
+		token.ID, token.FamilyID, token.SessionID, token.UserID, token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить refresh-токен: %w", err)
+	}
+	return nil
+}
+
+// UseRefreshToken отмечает refresh-токен использованным и возвращает его.
+// Если токен уже был использован, все семейство отзывается и возвращается
+// models.ErrTokenReused; отозванный или истекший токен — models.ErrNoRecord.
+func (pl *PullIncludes) UseRefreshToken(id string) (*models.RefreshToken, error) {
+	ctx := context.Background()
+	tx, err := pl.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT rt_id, rt_family_id, rt_session_id, rt_user_id, rt_created_at,
+			rt_expires_at, rt_used_at, rt_revoked_at
+		FROM refresh_tokens
+		WHERE rt_id = $1
+		FOR UPDATE
+	`
+	var token models.RefreshToken
+	err = tx.QueryRow(ctx, query, id).Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.SessionID,
+		&token.UserID,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении refresh-токена: %w", err)
+	}
+
+	err = token.Check(time.Now())
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, err
+	}
+	if errors.Is(err, models.ErrTokenReused) {
+		_, err = tx.Exec(ctx, `
+			UPDATE refresh_tokens SET rt_revoked_at = CURRENT_TIMESTAMP
+			WHERE rt_family_id = $1 AND rt_revoked_at IS NULL
+		`, token.FamilyID)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось отозвать refresh-токены: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("ошибка при подтверждении транзакции: %w", err)
+		}
+		return &token, models.ErrTokenReused
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE refresh_tokens SET rt_used_at = CURRENT_TIMESTAMP WHERE rt_id = $1", id); err != nil {
+		return nil, fmt.Errorf("не удалось обновить refresh-токен: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка при подтверждении транзакции: %w", err)
+	}
+	return &token, nil
+}
+
+// DeleteExpiredRefreshTokens удаляет истекшие refresh-токены
+func (pl *PullIncludes) DeleteExpiredRefreshTokens() (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), "DELETE FROM refresh_tokens WHERE rt_expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить истекшие refresh-токены: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetProjectRoles получает роли, назначенные пользователям в проекте
+func (pl *PullIncludes) GetProjectRoles(projectID int) ([]models.ProjectRole, error) {
+	query := `
+		SELECT pr_project_id, pr_user_id, pr_role, pr_created_at, pr_updated_at
+		FROM project_roles
+		WHERE pr_project_id = $1
+		ORDER BY pr_user_id
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ролей в проекте: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []models.ProjectRole{}
+	for rows.Next() {
+		var role models.ProjectRole
+		if err := rows.Scan(&role.ProjectID, &role.UserID, &role.Role, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения роли в проекте: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по ролям в проекте: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GetProjectRole получает роль пользователя в проекте. Если роль не назначена,
+// возвращает models.ErrNoRecord.
+func (pl *PullIncludes) GetProjectRole(projectID, userID int) (string, error) {
+	var role string
+	err := pl.DB.QueryRow(context.Background(),
+		"SELECT pr_role FROM project_roles WHERE pr_project_id = $1 AND pr_user_id = $2",
+		projectID, userID,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", models.ErrNoRecord
+		}
+		return "", fmt.Errorf("ошибка получения роли в проекте: %w", err)
+	}
+	return role, nil
+}
+
+// SetProjectRole назначает пользователю роль в проекте
+func (pl *PullIncludes) SetProjectRole(role *models.ProjectRole) error {
+	query := `
+		INSERT INTO project_roles (pr_project_id, pr_user_id, pr_role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (pr_project_id, pr_user_id)
+		DO UPDATE SET
+			pr_role = EXCLUDED.pr_role,
+			pr_updated_at = CURRENT_TIMESTAMP
+		RETURNING pr_created_at, pr_updated_at
+	`
+
+	err := pl.DB.QueryRow(context.Background(), query, role.ProjectID, role.UserID, role.Role).
+		Scan(&role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить роль в проекте: %w", err)
+	}
+	return nil
+}
+
+// DeleteProjectRole снимает назначенную роль пользователя в проекте. Если роль
+// не была назначена, возвращает models.ErrNoRecord.
+func (pl *PullIncludes) DeleteProjectRole(projectID, userID int) error {
+	tag, err := pl.DB.Exec(context.Background(),
+		"DELETE FROM project_roles WHERE pr_project_id = $1 AND pr_user_id = $2",
+		projectID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить роль в проекте: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+const personalTokenColumns = `pat_id, pat_hash, pat_prefix, pat_name, pat_scopes, pat_user_id, pat_username,
+	pat_user_name, pat_user_email, pat_created_at, pat_expires_at, pat_last_used_at, pat_last_used_ip, pat_revoked_at`
+
+func scanPersonalToken(row pgx.Row) (*models.PersonalToken, error) {
+	var token models.PersonalToken
+	err := row.Scan(
+		&token.ID,
+		&token.Hash,
+		&token.Prefix,
+		&token.Name,
+		&token.Scopes,
+		&token.UserID,
+		&token.Username,
+		&token.UserName,
+		&token.UserEmail,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.LastUsedIP,
+		&token.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreatePersonalToken сохраняет персональный токен доступа
+func (pl *PullIncludes) CreatePersonalToken(token *models.PersonalToken) error {
+	query := `
+		INSERT INTO personal_tokens (pat_hash, pat_prefix, pat_name, pat_scopes, pat_user_id,
+			pat_username, pat_user_name, pat_user_email, pat_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING pat_id, pat_created_at
+	`
+
+	err := pl.DB.QueryRow(context.Background(), query,
+		token.Hash, token.Prefix, token.Name, token.Scopes, token.UserID,
+		token.Username, token.UserName, token.UserEmail, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить персональный токен: %w", err)
+	}
+	return nil
+}
+
+// GetActivePersonalToken находит неотозванный и неистекший персональный токен
+// по хешу значения. Иначе возвращает models.ErrNoRecord.
+func (pl *PullIncludes) GetActivePersonalToken(hash string) (*models.PersonalToken, error) {
+	query := `SELECT ` + personalTokenColumns + `
+		FROM personal_tokens
+		WHERE pat_hash = $1 AND pat_revoked_at IS NULL AND pat_expires_at > CURRENT_TIMESTAMP
+	`
+
+	token, err := scanPersonalToken(pl.DB.QueryRow(context.Background(), query, hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении персонального токена: %w", err)
+	}
+	return token, nil
+}
+
+// GetPersonalTokens получает персональные токены пользователя, новые первыми
+func (pl *PullIncludes) GetPersonalTokens(userID int) ([]models.PersonalToken, error) {
+	query := `SELECT ` + personalTokenColumns + `
+		FROM personal_tokens
+		WHERE pat_user_id = $1
+		ORDER BY pat_created_at DESC
+	`
+
+	rows, err := pl.DB.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения персональных токенов: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalToken{}
+	for rows.Next() {
+		token, err := scanPersonalToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения персонального токена: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по персональным токенам: %w", err)
+	}
+	return tokens, nil
+}
+
+// TouchPersonalToken запоминает время и адрес последнего использования токена.
+// Чтобы не писать в БД на каждый запрос, время обновляется не чаще раза в минуту.
+func (pl *PullIncludes) TouchPersonalToken(id int, ip string) error {
+	query := `
+		UPDATE personal_tokens
+		SET pat_last_used_at = CURRENT_TIMESTAMP, pat_last_used_ip = $2
+		WHERE pat_id = $1
+			AND (pat_last_used_at IS NULL OR pat_last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute'
+				OR pat_last_used_ip IS DISTINCT FROM $2)
+	`
+
+	if _, err := pl.DB.Exec(context.Background(), query, id, ip); err != nil {
+		return fmt.Errorf("не удалось обновить время использования токена: %w", err)
+	}
+	return nil
+}
+
+// RevokePersonalToken отзывает персональный токен пользователя. Если токен не найден
+// или уже отозван, возвращает models.ErrNoRecord.
+func (pl *PullIncludes) RevokePersonalToken(id, userID int) error {
+	tag, err := pl.DB.Exec(context.Background(), `
+		UPDATE personal_tokens SET pat_revoked_at = CURRENT_TIMESTAMP
+		WHERE pat_id = $1 AND pat_user_id = $2 AND pat_revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось отозвать персональный токен: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// RevokeUserPersonalTokens отзывает все действующие персональные токены пользователя
+// и возвращает их количество
+func (pl *PullIncludes) RevokeUserPersonalTokens(userID int) (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), `
+		UPDATE personal_tokens SET pat_revoked_at = CURRENT_TIMESTAMP
+		WHERE pat_user_id = $1 AND pat_revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось отозвать персональные токены пользователя: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+const localUserColumns = `usr_id, usr_username, usr_email, usr_password, usr_role, usr_name, usr_patronomic,
+	usr_surname, usr_gitlab_id, usr_failed_logins, usr_locked_until`
+
+// getLocalUser находит пользователя таблицы users для локального входа
+func (pl *PullIncludes) getLocalUser(where string, arg interface{}) (*models.User, error) {
+	user := &models.User{}
+	err := pl.DB.QueryRow(context.Background(), `SELECT `+localUserColumns+` FROM users WHERE `+where, arg).Scan(
+		&user.UsrID,
+		&user.UsrUsername,
+		&user.UsrEmail,
+		&user.UsrPassword,
+		&user.UsrRole,
+		&user.UsrName,
+		&user.UsrPatronomic,
+		&user.UsrSurname,
+		&user.UsrGitLabID,
+		&user.UsrFailedLogins,
+		&user.UsrLockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("ошибка при получении пользователя: %w", err)
+	}
+	return user, nil
+}
+
+// GetLocalUser находит пользователя по логину; пароль проверяет вызывающий
+func (pl *PullIncludes) GetLocalUser(username string) (*models.User, error) {
+	return pl.getLocalUser("usr_username = $1", username)
+}
+
+// GetLocalUserByID находит пользователя по ID
+func (pl *PullIncludes) GetLocalUserByID(id int) (*models.User, error) {
+	return pl.getLocalUser("usr_id = $1", id)
+}
+
+// GetLocalUserByGitLabID находит пользователя, связанного с пользователем GitLab
+func (pl *PullIncludes) GetLocalUserByGitLabID(gitlabID int) (*models.User, error) {
+	return pl.getLocalUser("usr_gitlab_id = $1", gitlabID)
+}
+
+// RecordLoginFailure засчитывает неудачную попытку входа. После maxFailures попыток
+// подряд вход блокируется на lockFor, а счетчик начинается заново. Возвращает
+// время окончания блокировки, если она наступила.
+func (pl *PullIncludes) RecordLoginFailure(userID, maxFailures int, lockFor time.Duration) (*time.Time, error) {
+	query := `
+		UPDATE users SET
+			usr_locked_until = CASE WHEN usr_failed_logins + 1 >= $2
+				THEN CURRENT_TIMESTAMP + make_interval(secs => $3) ELSE usr_locked_until END,
+			usr_failed_logins = CASE WHEN usr_failed_logins + 1 >= $2 THEN 0 ELSE usr_failed_logins + 1 END
+		WHERE usr_id = $1
+		RETURNING usr_locked_until
+	`
+
+	var lockedUntil *time.Time
+	err := pl.DB.QueryRow(context.Background(), query, userID, maxFailures, lockFor.Seconds()).Scan(&lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить неудачную попытку входа: %w", err)
+	}
+	if lockedUntil != nil && lockedUntil.Before(time.Now()) {
+		return nil, nil
+	}
+	return lockedUntil, nil
+}
+
+// ResetLoginFailures сбрасывает счетчик неудачных попыток и блокировку после успешного входа
+func (pl *PullIncludes) ResetLoginFailures(userID int) error {
+	_, err := pl.DB.Exec(context.Background(),
+		"UPDATE users SET usr_failed_logins = 0, usr_locked_until = NULL WHERE usr_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("не удалось сбросить попытки входа: %w", err)
+	}
+	return nil
+}
+
+// SetUserPassword сохраняет хеш пароля пользователя и снимает блокировку входа
+func (pl *PullIncludes) SetUserPassword(userID int, hash string) error {
+	tag, err := pl.DB.Exec(context.Background(), `
+		UPDATE users SET usr_password = $2, usr_password_changed_at = CURRENT_TIMESTAMP,
+			usr_failed_logins = 0, usr_locked_until = NULL
+		WHERE usr_id = $1
+	`, userID, hash)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить пароль: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// GetPlaintextPasswords возвращает пароли, которые еще хранятся в открытом виде
+// (не хеши bcrypt), по ID пользователя
+func (pl *PullIncludes) GetPlaintextPasswords() (map[int]string, error) {
+	rows, err := pl.DB.Query(context.Background(), `
+		SELECT usr_id, usr_password FROM users
+		WHERE usr_password <> '' AND usr_password NOT LIKE '$2_$%'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения паролей: %w", err)
+	}
+	defer rows.Close()
+
+	passwords := map[int]string{}
+	for rows.Next() {
+		var id int
+		var password string
+		if err := rows.Scan(&id, &password); err != nil {
+			return nil, fmt.Errorf("ошибка чтения пароля: %w", err)
+		}
+		passwords[id] = password
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по паролям: %w", err)
+	}
+	return passwords, nil
+}
+
+// ReplacePlaintextPassword заменяет открытый пароль хешем, если пароль за это
+// время не изменился
+func (pl *PullIncludes) ReplacePlaintextPassword(userID int, plaintext, hash string) error {
+	_, err := pl.DB.Exec(context.Background(),
+		"UPDATE users SET usr_password = $3 WHERE usr_id = $1 AND usr_password = $2",
+		userID, plaintext, hash)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить хеш пароля: %w", err)
+	}
+	return nil
+}
+
+// LinkUserGitLab связывает пользователя таблицы users с пользователем GitLab
+// (nil — снять связь). Если пользователь GitLab уже связан с другим, возвращает
+// models.ErrDuplicate.
+func (pl *PullIncludes) LinkUserGitLab(userID int, gitlabID *int) error {
+	tag, err := pl.DB.Exec(context.Background(),
+		"UPDATE users SET usr_gitlab_id = $2 WHERE usr_id = $1", userID, gitlabID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrDuplicate
+		}
+		return fmt.Errorf("не удалось связать пользователя с GitLab: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteLocalUserSessions удаляет сессии локального пользователя, кроме exceptID
+// (текущей сессии; пустая строка — удалить все)
+func (pl *PullIncludes) DeleteLocalUserSessions(userID int, exceptID string) (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(),
+		"DELETE FROM sessions WHERE ses_local_user_id = $1 AND ses_id <> $2", userID, exceptID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить сессии пользователя: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CreatePasswordReset сохраняет одноразовый токен сброса пароля. Прежние токены
+// пользователя перестают действовать.
+func (pl *PullIncludes) CreatePasswordReset(id string, userID int, expiresAt time.Time) error {
+	ctx := context.Background()
+	tx, err := pl.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM password_resets WHERE pwr_user_id = $1", userID); err != nil {
+		return fmt.Errorf("не удалось удалить прежние токены сброса пароля: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO password_resets (pwr_id, pwr_user_id, pwr_expires_at) VALUES ($1, $2, $3)",
+		id, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить токен сброса пароля: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("не удалось завершить транзакцию: %w", err)
+	}
+	return nil
+}
+
+// ConsumePasswordReset использует токен сброса пароля и возвращает ID пользователя.
+// Неизвестный или истекший токен — models.ErrNoRecord.
+func (pl *PullIncludes) ConsumePasswordReset(id string) (int, error) {
+	var userID int
+	var expiresAt time.Time
+	err := pl.DB.QueryRow(context.Background(),
+		"DELETE FROM password_resets WHERE pwr_id = $1 RETURNING pwr_user_id, pwr_expires_at", id,
+	).Scan(&userID, &expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, fmt.Errorf("ошибка при использовании токена сброса пароля: %w", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		return 0, models.ErrNoRecord
+	}
+	return userID, nil
+}
+
+// DeleteExpiredPasswordResets удаляет истекшие токены сброса пароля
+func (pl *PullIncludes) DeleteExpiredPasswordResets() (int64, error) {
+	tag, err := pl.DB.Exec(context.Background(), "DELETE FROM password_resets WHERE pwr_expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить истекшие токены сброса пароля: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,93 @@
+package models
+
+// Статусы задачи на доске спринта
+const (
+	StatusToDo       = "К выполнению"
+	StatusInProgress = "В работе"
+	StatusReview     = "На проверке"
+	StatusDone       = "Готово"
+	StatusBlocked    = "Заблокировано"
+)
+
+// IssueStatuses перечисляет допустимые статусы задачи в порядке колонок доски
+var IssueStatuses = []string{StatusToDo, StatusInProgress, StatusReview, StatusDone, StatusBlocked}
+
+// IssueEvent описывает событие, которое может изменить статус задачи
+type IssueEvent string
+
+const (
+	EventAssigned      IssueEvent = "assigned"       // назначен исполнитель
+	EventUnassigned    IssueEvent = "unassigned"     // исполнитель снят
+	EventWorkStarted   IssueEvent = "work_started"   // создана ветка или черновик MR
+	EventCommit        IssueEvent = "commit"         // коммит со ссылкой на задачу
+	EventRevert        IssueEvent = "revert"         // revert-коммит со ссылкой на задачу
+	EventMROpened      IssueEvent = "mr_opened"      // MR открыт или переоткрыт
+	EventMRMerged      IssueEvent = "mr_merged"      // MR слит
+	EventMRClosed      IssueEvent = "mr_closed"      // MR закрыт без слияния
+	EventIssueClosed   IssueEvent = "issue_closed"   // задача закрыта в GitLab
+	EventIssueReopened IssueEvent = "issue_reopened" // задача переоткрыта в GitLab
+)
+
+// IssueState содержит сведения о задаче, от которых зависит переход
+type IssueState struct {
+	Status   string // текущий статус
+	Assigned bool   // назначен ли исполнитель
+	OpenMRs  int    // количество открытых MR, ссылающихся на задачу (без учета текущего события)
+}
+
+// NextIssueStatus возвращает статус задачи после события.
+//
+// Правила переходов:
+//   - "Заблокировано" меняется только вручную или слиянием MR;
+//   - назначение исполнителя переводит "К выполнению" в "В работе", снятие — обратно;
+//   - создание ветки или черновика MR переводит "К выполнению" в "В работе";
+//   - коммит и открытие MR переводят задачу "На проверке", кроме уже готовых задач для коммитов;
+//   - слияние MR и закрытие задачи в GitLab переводят задачу в "Готово";
+//   - закрытие MR без слияния возвращает задачу "В работе", если нет других открытых MR
+//     и задача еще не готова;
+//   - revert-коммит и переоткрытие задачи в GitLab возвращают задачу "В работе"
+//     (или "К выполнению", если исполнитель не назначен).
+func NextIssueStatus(state IssueState, event IssueEvent) string {
+	current := state.Status
+	if current == "" {
+		current = StatusToDo
+	}
+
+	reopened := StatusToDo
+	if state.Assigned {
+		reopened = StatusInProgress
+	}
+
+	if current == StatusBlocked && event != EventMRMerged {
+		return current
+	}
+
+	switch event {
+	case EventAssigned, EventWorkStarted:
+		if current == StatusToDo {
+			return StatusInProgress
+		}
+	case EventUnassigned:
+		if current == StatusInProgress {
+			return StatusToDo
+		}
+	case EventCommit:
+		if current != StatusDone {
+			return StatusReview
+		}
+	case EventMROpened:
+		return StatusReview
+	case EventMRMerged, EventIssueClosed:
+		return StatusDone
+	case EventMRClosed:
+		if current != StatusDone && state.OpenMRs == 0 {
+			return reopened
+		}
+	case EventRevert, EventIssueReopened:
+		if current == StatusDone || current == StatusReview {
+			return reopened
+		}
+	}
+
+	return current
+}
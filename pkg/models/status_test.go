@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestNextIssueStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		state IssueState
+		event IssueEvent
+		want  string
+	}{
+		{"пустой статус считается К выполнению", IssueState{}, EventCommit, StatusReview},
+		{"назначение начинает работу", IssueState{Status: StatusToDo}, EventAssigned, StatusInProgress},
+		{"назначение не трогает проверку", IssueState{Status: StatusReview}, EventAssigned, StatusReview},
+		{"снятие исполнителя возвращает в очередь", IssueState{Status: StatusInProgress}, EventUnassigned, StatusToDo},
+		{"снятие исполнителя не трогает проверку", IssueState{Status: StatusReview}, EventUnassigned, StatusReview},
+		{"ветка начинает работу", IssueState{Status: StatusToDo}, EventWorkStarted, StatusInProgress},
+		{"ветка не возвращает с проверки", IssueState{Status: StatusReview}, EventWorkStarted, StatusReview},
+		{"коммит отправляет на проверку", IssueState{Status: StatusInProgress}, EventCommit, StatusReview},
+		{"коммит не трогает готовую задачу", IssueState{Status: StatusDone}, EventCommit, StatusDone},
+		{"открытие MR отправляет на проверку", IssueState{Status: StatusToDo}, EventMROpened, StatusReview},
+		{"слияние MR завершает задачу", IssueState{Status: StatusReview}, EventMRMerged, StatusDone},
+		{"слияние MR снимает блокировку", IssueState{Status: StatusBlocked}, EventMRMerged, StatusDone},
+		{"закрытие задачи в GitLab завершает ее", IssueState{Status: StatusInProgress}, EventIssueClosed, StatusDone},
+		{"блокировку не снимает коммит", IssueState{Status: StatusBlocked}, EventCommit, StatusBlocked},
+		{"блокировку не снимает закрытие задачи", IssueState{Status: StatusBlocked}, EventIssueClosed, StatusBlocked},
+		{"закрытый MR возвращает в работу", IssueState{Status: StatusReview, Assigned: true}, EventMRClosed, StatusInProgress},
+		{"закрытый MR без исполнителя возвращает в очередь", IssueState{Status: StatusReview}, EventMRClosed, StatusToDo},
+		{"закрытый MR при других открытых MR", IssueState{Status: StatusReview, Assigned: true, OpenMRs: 1}, EventMRClosed, StatusReview},
+		{"закрытый MR не трогает готовую задачу", IssueState{Status: StatusDone, Assigned: true}, EventMRClosed, StatusDone},
+		{"revert возвращает готовую задачу в работу", IssueState{Status: StatusDone, Assigned: true}, EventRevert, StatusInProgress},
+		{"revert без исполнителя возвращает в очередь", IssueState{Status: StatusDone}, EventRevert, StatusToDo},
+		{"revert возвращает с проверки", IssueState{Status: StatusReview, Assigned: true}, EventRevert, StatusInProgress},
+		{"revert не трогает задачу в работе", IssueState{Status: StatusInProgress, Assigned: true}, EventRevert, StatusInProgress},
+		{"переоткрытие задачи возвращает в работу", IssueState{Status: StatusDone, Assigned: true}, EventIssueReopened, StatusInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextIssueStatus(tt.state, tt.event); got != tt.want {
+				t.Errorf("NextIssueStatus(%+v, %s) = %q, ожидалось %q", tt.state, tt.event, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ProjectTemplate — шаблон начальной настройки нового проекта GitLab
+type ProjectTemplate struct {
+	ID          int                   `json:"id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Config      ProjectTemplateConfig `json:"config"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// ProjectTemplateConfig описывает, что создается в проекте по шаблону
+type ProjectTemplateConfig struct {
+	Labels     []TemplateLabel     `json:"labels"`
+	Board      *TemplateBoard      `json:"board,omitempty"`
+	Milestones *TemplateMilestones `json:"milestones,omitempty"`
+	Files      []TemplateFile      `json:"files"`
+}
+
+// TemplateLabel — метка проекта
+type TemplateLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"` // #RRGGBB
+	Description string `json:"description"`
+}
+
+// TemplateBoard — доска задач с колонками по меткам
+type TemplateBoard struct {
+	Name  string   `json:"name"`
+	Lists []string `json:"lists"` // названия меток в порядке колонок
+}
+
+// TemplateMilestones описывает нарезку вех по датам начала и окончания проекта.
+// Если DurationDays не задан, создается одна веха на весь срок проекта.
+type TemplateMilestones struct {
+	TitlePrefix  string `json:"title_prefix"`
+	DurationDays int    `json:"duration_days"`
+}
+
+// TemplateFile — файл, добавляемый в репозиторий (например, шаблоны задач и MR в .gitlab/)
+type TemplateFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}